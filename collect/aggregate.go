@@ -0,0 +1,181 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"github.com/COSAE-FR/ripugw/inform"
+	"github.com/COSAE-FR/ripugw/pfconf"
+	"sort"
+	"sync"
+)
+
+const (
+	AggregateLagg   = "lagg"
+	AggregateBridge = "bridge"
+)
+
+type AggregateMember struct {
+	Name       string `json:"name"`
+	Up         bool   `json:"up"`
+	Speed      uint64 `json:"speed"`
+	FullDuplex bool   `json:"full_duplex"`
+}
+
+// Aggregate is a lagg (or Linux bond) or a bridge, reported as one port.
+type Aggregate struct {
+	Name    string            `json:"name"`
+	Kind    string            `json:"kind"`
+	Proto   string            `json:"proto,omitempty"`
+	Members []AggregateMember `json:"members"`
+}
+
+// failover tells if only one member carries the traffic.
+func (a Aggregate) failover() bool {
+	return a.Proto == "failover" || a.Proto == "active-backup"
+}
+
+// AggregateTable keeps the aggregates seen by the last inform, for the
+// local status.
+type AggregateTable struct {
+	lock       sync.Mutex
+	aggregates []Aggregate
+}
+
+func NewAggregateTable() *AggregateTable {
+	return &AggregateTable{}
+}
+
+func (t *AggregateTable) set(aggregates []Aggregate) {
+	if t == nil {
+		return
+	}
+	sort.Slice(aggregates, func(i, j int) bool {
+		return aggregates[i].Name < aggregates[j].Name
+	})
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.aggregates = aggregates
+}
+
+func (t *AggregateTable) All() []Aggregate {
+	if t == nil {
+		return nil
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return append([]Aggregate{}, t.aggregates...)
+}
+
+// SystemAggregate returns the members of an aggregate device as known by
+// the system.
+func SystemAggregate(device string) (Aggregate, bool) {
+	return getAggregate(device)
+}
+
+// pfSenseAggregate returns the members of an aggregate device from the
+// pfSense configuration, the system ones otherwise.
+func pfSenseAggregate(device string, pfsense pfconf.Configuration) (Aggregate, bool) {
+	if lagg, ok := pfsense.Lagg(device); ok {
+		aggregate := Aggregate{Name: device, Kind: AggregateLagg, Proto: lagg.Proto}
+		for _, member := range lagg.Members() {
+			aggregate.Members = append(aggregate.Members, AggregateMember{Name: member})
+		}
+		return aggregate, true
+	}
+	if bridge, ok := pfsense.Bridge(device); ok {
+		aggregate := Aggregate{Name: device, Kind: AggregateBridge}
+		for _, member := range bridge.Members() {
+			for _, iface := range pfsense.Interfaces.List {
+				if iface.XMLName.Local == member {
+					aggregate.Members = append(aggregate.Members, AggregateMember{Name: iface.If})
+				}
+			}
+		}
+		return aggregate, true
+	}
+	return SystemAggregate(device)
+}
+
+// applyAggregate fills the member status from ifaces and presents the
+// aggregate as one port: up while a member is up, at the summed speed of
+// the active members of a lagg, or at the fastest member speed for a
+// bridge or a failover lagg. Members without a known speed count for the
+// link state only; when no active member reports one, the aggregate keeps
+// the speed and duplex of its own device.
+func applyAggregate(iface *inform.Interface, aggregate *Aggregate, ifaces []inform.Interface) {
+	var speed uint64
+	up := false
+	fullDuplex := true
+	for i, member := range aggregate.Members {
+		for _, candidate := range ifaces {
+			if candidate.Name != member.Name {
+				continue
+			}
+			aggregate.Members[i].Up = candidate.Up
+			aggregate.Members[i].Speed = candidate.Speed
+			aggregate.Members[i].FullDuplex = candidate.FullDuplex
+		}
+		member = aggregate.Members[i]
+		if !member.Up {
+			continue
+		}
+		up = true
+		if member.Speed == 0 {
+			continue
+		}
+		fullDuplex = fullDuplex && member.FullDuplex
+		switch {
+		case aggregate.Kind == AggregateLagg && !aggregate.failover():
+			speed += member.Speed
+		case member.Speed > speed:
+			speed = member.Speed
+		}
+	}
+	if len(aggregate.Members) == 0 {
+		return
+	}
+	iface.Up = iface.Up && up
+	if speed > 0 {
+		iface.Speed = speed
+		iface.FullDuplex = fullDuplex
+	}
+}
+
+// isAggregateMember tells if name is a member of one of the aggregates.
+func isAggregateMember(name string, aggregates []Aggregate) bool {
+	for _, aggregate := range aggregates {
+		for _, member := range aggregate.Members {
+			if member.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// applyPfSenseAggregates presents the mapped laggs and bridges as one port.
+func applyPfSenseAggregates(table *PfSenseTranslation, ifaces []inform.Interface, pfsense pfconf.Configuration) []Aggregate {
+	var aggregates []Aggregate
+	translations := []*TranslatedInterface{&table.Wan, &table.Wan2}
+	for i := range table.Lans {
+		translations = append(translations, &table.Lans[i])
+	}
+	for _, translated := range translations {
+		if len(translated.Pfsense.If) == 0 {
+			continue
+		}
+		aggregate, ok := pfSenseAggregate(translated.Pfsense.If, pfsense)
+		if !ok {
+			continue
+		}
+		applyAggregate(&translated.Physical, &aggregate, ifaces)
+		aggregates = append(aggregates, aggregate)
+	}
+	return aggregates
+}
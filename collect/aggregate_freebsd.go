@@ -0,0 +1,35 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+// getAggregate reads the lagg ports or the bridge members from ifconfig.
+func getAggregate(device string) (Aggregate, bool) {
+	interfaces, err := ifconfig(device)
+	if err != nil || len(interfaces) == 0 {
+		return Aggregate{}, false
+	}
+	iface := interfaces[0]
+	aggregate := Aggregate{Name: device}
+	switch {
+	case len(iface.LaggProto) > 0:
+		aggregate.Kind = AggregateLagg
+		aggregate.Proto = iface.LaggProto
+		for _, port := range iface.LaggPorts {
+			aggregate.Members = append(aggregate.Members, AggregateMember{Name: port})
+		}
+	case len(iface.Members) > 0 || iface.HasGroup("bridge"):
+		aggregate.Kind = AggregateBridge
+		for _, member := range iface.Members {
+			aggregate.Members = append(aggregate.Members, AggregateMember{Name: member})
+		}
+	default:
+		return Aggregate{}, false
+	}
+	return aggregate, true
+}
@@ -0,0 +1,41 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"io/ioutil"
+	"path"
+	"strings"
+)
+
+// getAggregate reads the bonding slaves or the bridge ports from sysfs.
+func getAggregate(device string) (Aggregate, bool) {
+	base := path.Join(SysfsRoot, "class/net", device)
+	if slaves, err := readSysfsValue(path.Join(base, "bonding/slaves")); err == nil {
+		aggregate := Aggregate{Name: device, Kind: AggregateLagg}
+		// "802.3ad 4", "active-backup 1"
+		if mode, err := readSysfsValue(path.Join(base, "bonding/mode")); err == nil {
+			if fields := strings.Fields(mode); len(fields) > 0 {
+				aggregate.Proto = fields[0]
+			}
+		}
+		for _, slave := range strings.Fields(slaves) {
+			aggregate.Members = append(aggregate.Members, AggregateMember{Name: slave})
+		}
+		return aggregate, true
+	}
+	if ports, err := ioutil.ReadDir(path.Join(base, "brif")); err == nil {
+		aggregate := Aggregate{Name: device, Kind: AggregateBridge}
+		for _, port := range ports {
+			aggregate.Members = append(aggregate.Members, AggregateMember{Name: port.Name()})
+		}
+		return aggregate, true
+	}
+	return Aggregate{}, false
+}
@@ -0,0 +1,120 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/COSAE-FR/ripugw/inform"
+)
+
+func TestPfSenseAggregate(t *testing.T) {
+	pfsense := loadPfSenseFixture(t, "config.xml")
+	tests := []struct {
+		device  string
+		kind    string
+		proto   string
+		members []string
+	}{
+		{"lagg0", AggregateLagg, "lacp", []string{"em4", "em5"}},
+		{"lagg1", AggregateLagg, "failover", []string{"em6", "em7"}},
+		// Bridge members are pfSense names, resolved to their devices
+		{"bridge0", AggregateBridge, "", []string{"em1.20", "em1.30"}},
+	}
+	for _, test := range tests {
+		aggregate, ok := pfSenseAggregate(test.device, pfsense)
+		if !ok {
+			t.Errorf("pfSenseAggregate(%s) not found", test.device)
+			continue
+		}
+		var members []string
+		for _, member := range aggregate.Members {
+			members = append(members, member.Name)
+		}
+		if aggregate.Name != test.device || aggregate.Kind != test.kind || aggregate.Proto != test.proto || !reflect.DeepEqual(members, test.members) {
+			t.Errorf("pfSenseAggregate(%s) = %+v, want %s %s %v", test.device, aggregate, test.kind, test.proto, test.members)
+		}
+	}
+}
+
+func TestApplyAggregate(t *testing.T) {
+	device := inform.Interface{Name: "agg0", Up: true, Speed: 10000, FullDuplex: true}
+	tests := []struct {
+		name       string
+		kind       string
+		proto      string
+		members    []inform.Interface
+		up         bool
+		speed      uint64
+		fullDuplex bool
+	}{
+		{"lacp sums the active members", AggregateLagg, "lacp", []inform.Interface{
+			{Name: "m0", Up: true, Speed: 1000, FullDuplex: true},
+			{Name: "m1", Up: true, Speed: 1000, FullDuplex: true},
+		}, true, 2000, true},
+		{"lacp skips the down members", AggregateLagg, "lacp", []inform.Interface{
+			{Name: "m0", Up: true, Speed: 1000, FullDuplex: true},
+			{Name: "m1", Speed: 1000, FullDuplex: true},
+		}, true, 1000, true},
+		{"failover takes the fastest", AggregateLagg, "failover", []inform.Interface{
+			{Name: "m0", Up: true, Speed: 100, FullDuplex: true},
+			{Name: "m1", Up: true, Speed: 1000, FullDuplex: true},
+		}, true, 1000, true},
+		{"active-backup takes the fastest", AggregateLagg, "active-backup", []inform.Interface{
+			{Name: "m0", Up: true, Speed: 1000, FullDuplex: true},
+			{Name: "m1", Up: true, Speed: 1000, FullDuplex: true},
+		}, true, 1000, true},
+		{"bridge takes the fastest", AggregateBridge, "", []inform.Interface{
+			{Name: "m0", Up: true, Speed: 2500, FullDuplex: true},
+			{Name: "m1", Up: true, Speed: 1000, FullDuplex: true},
+		}, true, 2500, true},
+		{"half duplex member", AggregateLagg, "lacp", []inform.Interface{
+			{Name: "m0", Up: true, Speed: 100},
+			{Name: "m1", Up: true, Speed: 100, FullDuplex: true},
+		}, true, 200, false},
+		{"all members down", AggregateLagg, "lacp", []inform.Interface{
+			{Name: "m0", Speed: 1000, FullDuplex: true},
+			{Name: "m1", Speed: 1000, FullDuplex: true},
+		}, false, 10000, true},
+		{"missing members are down", AggregateBridge, "", nil, false, 10000, true},
+		{"member without speed", AggregateLagg, "lacp", []inform.Interface{
+			{Name: "m0", Up: true},
+			{Name: "m1", Up: true, Speed: 1000, FullDuplex: true},
+		}, true, 1000, true},
+		{"no member speed keeps the device one", AggregateBridge, "", []inform.Interface{
+			{Name: "m0", Up: true},
+			{Name: "m1", Up: true},
+		}, true, 10000, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			iface := device
+			aggregate := Aggregate{Name: "agg0", Kind: test.kind, Proto: test.proto, Members: []AggregateMember{{Name: "m0"}, {Name: "m1"}}}
+			applyAggregate(&iface, &aggregate, test.members)
+			if iface.Up != test.up || iface.Speed != test.speed || iface.FullDuplex != test.fullDuplex {
+				t.Errorf("applyAggregate() = up %v speed %d full duplex %v, want %v %d %v", iface.Up, iface.Speed, iface.FullDuplex, test.up, test.speed, test.fullDuplex)
+			}
+			for i, member := range test.members {
+				if got := aggregate.Members[i]; got.Up != member.Up || got.Speed != member.Speed || got.FullDuplex != member.FullDuplex {
+					t.Errorf("member %s = %+v, want %+v", member.Name, got, member)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyAggregateWithoutMembers(t *testing.T) {
+	iface := inform.Interface{Name: "lagg9", Up: true, Speed: 1000, FullDuplex: true}
+	aggregate := Aggregate{Name: "lagg9", Kind: AggregateLagg, Proto: "lacp"}
+	applyAggregate(&iface, &aggregate, nil)
+	if !iface.Up || iface.Speed != 1000 {
+		t.Errorf("applyAggregate() = %+v, want the device left alone", iface)
+	}
+}
@@ -23,6 +23,17 @@ import (
 	"time"
 )
 
+// Options tunes the optional collectors used to build an inform.
+type Options struct {
+	DisableClients bool
+	LeaseFiles     []string
+	Uplinks        *UplinkRegistry
+	DpingerDir     string
+	Rates          *RateTracker
+	Links          *LinkTracker
+	Aggregates     *AggregateTable
+}
+
 func Network() ([]inform.Interface, error) {
 	counters, err := netstats.IOCounters(true)
 	if err != nil {
@@ -50,6 +61,11 @@ func Network() ([]inform.Interface, error) {
 			unifiCounter.Ip = ipAddress.IP.String()
 			unifiCounter.Netmask = FormatMask(ipAddress.Mask)
 		}
+		if addresses, err := GetIPv6ForInterface(counter.Name); err == nil {
+			for _, address := range addresses {
+				unifiCounter.Ipv6 = append(unifiCounter.Ipv6, address.String())
+			}
+		}
 
 		iface, err := net.InterfaceByName(counter.Name)
 		if err == nil {
@@ -58,6 +74,7 @@ func Network() ([]inform.Interface, error) {
 			}
 			unifiCounter.Mac = inform.HardwareAddr(iface.HardwareAddr)
 			unifiCounter.NumPort = iface.Index
+			unifiCounter.Mtu = iface.MTU
 			if iface.Flags&net.FlagUp != 0 {
 				unifiCounter.Up = true
 				unifiCounter.Enabled = true
@@ -66,6 +83,9 @@ func Network() ([]inform.Interface, error) {
 
 				unifiCounter.FullDuplex = media.FullDuplex
 				unifiCounter.Speed = media.Speed
+				if media.Mtu > 0 {
+					unifiCounter.Mtu = media.Mtu
+				}
 			}
 		}
 		unifiCounter.Gateways = []string{}
@@ -158,7 +178,7 @@ func getIpFromURL(address string) string {
 	return ""
 }
 
-func Request(address string, version string) (inform.Inform, error) {
+func Request(address string, version string, options Options) (inform.Inform, error) {
 	now := time.Now()
 	request := inform.Inform{
 		ConfigNetworkWan:     inform.NetworkConfig{Type: inform.NetworkConfigDhcp},
@@ -209,11 +229,45 @@ func Request(address string, version string) (inform.Inform, error) {
 		request.BootRomVersion = host.PlatformVersion
 	}
 
+	var hosts map[string][]inform.Host
+	if !options.DisableClients {
+		hosts, _ = HostTables(options.LeaseFiles)
+	}
+
+	routes, _ := Routes()
+	request.RouteTable = RouteTable(routes)
+
+	globalDns, linksDns := ReadDnsConfig(DefaultDnsPaths)
+
 	ifaces, err := Network()
 	if err == nil {
+		options.Rates.ApplyAll(ifaces)
+		options.Links.ApplyAll(ifaces)
+		var aggregates []Aggregate
+		for i := range ifaces {
+			if aggregate, ok := SystemAggregate(ifaces[i].Name); ok {
+				applyAggregate(&ifaces[i], &aggregate, ifaces)
+				aggregates = append(aggregates, aggregate)
+			}
+		}
+		options.Aggregates.set(aggregates)
+		// Members are presented through their aggregate
+		ports := ifaces[:0]
+		for _, iface := range ifaces {
+			if !isAggregateMember(iface.Name, aggregates) {
+				ports = append(ports, iface)
+			}
+		}
+		ifaces = ports
+		for i := range ifaces {
+			if config, ok := linksDns[ifaces[i].Name]; ok {
+				ifaces[i].Nameservers = append(ifaces[i].Nameservers, config.Nameservers...)
+				ifaces[i].SearchDomains = config.Search
+			}
+		}
 		request.IntfTable = ifaces
 		request.EthernetTable = make([]inform.EthernetTableEntry, len(ifaces))
-		for _, iface := range ifaces {
+		for i, iface := range ifaces {
 			request.EthernetTable = append(request.EthernetTable, inform.EthernetTableEntry{
 				Name:    iface.Name,
 				Mac:     iface.Mac.String(),
@@ -231,6 +285,39 @@ func Request(address string, version string) (inform.Inform, error) {
 						Name:   "wan",
 					})
 					request.ConfigNetworkWan.IfName = iface.Name
+					if lease, err := ReadWanLease(iface.Name); err == nil {
+						request.ConfigNetworkWan.Lease = lease.Inform()
+					}
+					gateways := []string{}
+					if gateway := InterfaceGateway(routes, iface.Name, false); gateway != nil {
+						gateways = append(gateways, gateway.String())
+					} else if gateway, err := Gateway(); err == nil {
+						gateways = append(gateways, gateway.String())
+					}
+					gateway6 := ""
+					if gateway := InterfaceGateway(routes, iface.Name, true); gateway != nil {
+						gateway6 = gateway.String()
+					} else if gateway, err := Gateway6(); err == nil {
+						gateway6 = gateway.String()
+					}
+					if len(gateway6) > 0 {
+						gateways = append(gateways, gateway6)
+					}
+					request.IntfTable[i].Gateways = gateways
+					if len(request.IntfTable[i].Nameservers) == 0 {
+						request.IntfTable[i].Nameservers = append(request.IntfTable[i].Nameservers, globalDns.Nameservers...)
+						request.IntfTable[i].SearchDomains = globalDns.Search
+					}
+					for n, nameserver := range request.IntfTable[i].Nameservers {
+						switch n {
+						case 0:
+							request.ConfigNetworkWan.Dns1 = nameserver
+						case 1:
+							request.ConfigNetworkWan.Dns2 = nameserver
+						}
+					}
+					applyIpv6Config(&request.ConfigNetworkWan, iface, Ipv6ConfigType(iface.Name), gateway6)
+					applyUplink(&request, &request.IntfTable[i], iface.Name, "WAN", options.Uplinks)
 				} else {
 					ifNumber := len(request.PortTable)
 					ifString := fmt.Sprintf("%d", ifNumber)
@@ -241,6 +328,9 @@ func Request(address string, version string) (inform.Inform, error) {
 						IfName: iface.Name,
 						Name:   fmt.Sprintf("lan%s", ifString),
 					})
+					if hosts != nil {
+						request.NetworkTable = append(request.NetworkTable, networkEntry(iface.Name, iface, hosts))
+					}
 				}
 			}
 		}
@@ -0,0 +1,84 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"github.com/COSAE-FR/ripugw/inform"
+	"github.com/COSAE-FR/ripugw/pfconf"
+	"strconv"
+)
+
+// pfSense uses a 2 hours lease when none is configured
+const defaultDhcpLeaseTime = 7200
+
+// applyStaticMaps names the clients declared in pfSense DHCP static mappings.
+func applyStaticMaps(hosts map[string][]inform.Host, maps []pfconf.StaticMap) {
+	byMac := make(map[string]pfconf.StaticMap, len(maps))
+	for _, staticMap := range maps {
+		mac, err := staticMap.HardwareAddr()
+		if err == nil {
+			byMac[mac.String()] = staticMap
+		}
+	}
+	for _, table := range hosts {
+		for i := range table {
+			staticMap, ok := byMac[table[i].Mac.String()]
+			if !ok {
+				continue
+			}
+			if len(staticMap.Hostname) > 0 {
+				table[i].Hostname = staticMap.Hostname
+				table[i].Name = staticMap.Hostname
+			}
+			if len(staticMap.Description) > 0 {
+				table[i].Note = staticMap.Description
+				if len(table[i].Name) == 0 {
+					table[i].Name = staticMap.Description
+				}
+			}
+			if len(staticMap.Ip) > 0 {
+				table[i].UseFixedIp = true
+				table[i].FixedIp = staticMap.Ip
+			}
+		}
+	}
+}
+
+// applyDhcpServer describes the pfSense DHCP scope of a network. The
+// pfSense interface description is only used when the network has none.
+func applyDhcpServer(network *inform.Network, pfInterface pfconf.Interface, pfsense pfconf.Configuration) {
+	if len(network.Description) == 0 {
+		network.Description = pfInterface.Description
+	}
+	server, ok := pfsense.Dhcpd.Get(pfInterface.XMLName.Local)
+	if !ok {
+		return
+	}
+	network.DhcpdEnabled = bool(server.Enable)
+	network.DhcpdStart = server.RangeFrom
+	network.DhcpdStop = server.RangeTo
+	network.DhcpdLeaseTime = defaultDhcpLeaseTime
+	if leaseTime, err := strconv.Atoi(server.DefaultLeaseTime); err == nil && leaseTime > 0 {
+		network.DhcpdLeaseTime = leaseTime
+	}
+	network.DhcpdGateway = server.Gateway
+	if len(network.DhcpdGateway) == 0 {
+		network.DhcpdGateway = network.Ip
+	}
+	network.DhcpdDns = server.DnsServers
+	if len(network.DhcpdDns) == 0 && len(network.Ip) > 0 {
+		// pfSense hands out the interface address when the DNS resolver is used
+		network.DhcpdDns = []string{network.Ip}
+	}
+	network.DhcpdStatic = len(server.StaticMaps)
+	network.DomainName = server.Domain
+	if len(network.DomainName) == 0 {
+		network.DomainName = pfsense.System.Domain
+	}
+}
@@ -0,0 +1,74 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/COSAE-FR/ripugw/inform"
+)
+
+func testHost(t *testing.T, mac string, ip string, hostname string) inform.Host {
+	t.Helper()
+	hardwareAddr, err := net.ParseMAC(mac)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return inform.Host{Mac: inform.HardwareAddr(hardwareAddr), Ip: ip, Hostname: hostname, Name: hostname}
+}
+
+func TestApplyStaticMaps(t *testing.T) {
+	pfsense := loadPfSenseFixture(t, "config.xml")
+	hosts := map[string][]inform.Host{
+		"eth1": {
+			testHost(t, "00:11:22:aa:bb:01", "192.168.1.10", "dhcp-name"),
+			// Matched regardless of the MAC case in the configuration
+			testHost(t, "00:11:22:aa:bb:02", "192.168.1.150", ""),
+			testHost(t, "00:11:22:aa:bb:04", "192.168.1.151", "laptop"),
+		},
+		"eth1.10": {
+			testHost(t, "00:11:22:aa:bb:02", "192.168.10.20", "printer-guest"),
+		},
+	}
+	applyStaticMaps(hosts, pfsense.Dhcpd.StaticMaps())
+
+	nas := testHost(t, "00:11:22:aa:bb:01", "192.168.1.10", "nas")
+	nas.Note = "Storage"
+	nas.UseFixedIp = true
+	nas.FixedIp = "192.168.1.10"
+	printer := testHost(t, "00:11:22:aa:bb:02", "192.168.1.150", "")
+	printer.Name = "Printer"
+	printer.Note = "Printer"
+	// The host name learnt from the client wins over the description
+	guestPrinter := testHost(t, "00:11:22:aa:bb:02", "192.168.10.20", "printer-guest")
+	guestPrinter.Note = "Printer"
+	want := map[string][]inform.Host{
+		"eth1": {
+			nas,
+			printer,
+			testHost(t, "00:11:22:aa:bb:04", "192.168.1.151", "laptop"),
+		},
+		// Static maps whose client is not in the neighbour table add no host
+		"eth1.10": {guestPrinter},
+	}
+	if !reflect.DeepEqual(hosts, want) {
+		t.Errorf("applyStaticMaps() =\n%+v\nwant\n%+v", hosts, want)
+	}
+}
+
+func TestApplyStaticMapsWithoutMaps(t *testing.T) {
+	host := testHost(t, "00:11:22:aa:bb:01", "192.168.1.10", "dhcp-name")
+	hosts := map[string][]inform.Host{"eth1": {host}}
+	applyStaticMaps(hosts, nil)
+	if !reflect.DeepEqual(hosts["eth1"], []inform.Host{host}) {
+		t.Errorf("applyStaticMaps(nil) = %+v, want the host unchanged", hosts["eth1"])
+	}
+}
@@ -0,0 +1,247 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// DnsPaths locates the resolver state of the system.
+type DnsPaths struct {
+	// ResolvConf files, the first one without a local stub resolver is used
+	ResolvConf []string
+	// systemd-resolved and systemd-networkd per link state files,
+	// named after the interface index
+	ResolvedLinks string
+	NetworkdLinks string
+	// Nmcli is the NetworkManager client, asked for the runtime DNS of
+	// the devices, DHCP provided ones included. Empty to skip it.
+	Nmcli string
+}
+
+var DefaultDnsPaths = DnsPaths{
+	ResolvConf: []string{
+		"/etc/resolv.conf",
+		"/run/systemd/resolve/resolv.conf",
+		"/run/NetworkManager/no-stub-resolv.conf",
+	},
+	ResolvedLinks: "/run/systemd/resolve/netif",
+	NetworkdLinks: "/run/systemd/netif/links",
+	Nmcli:         "nmcli",
+}
+
+// nmcliDnsArgs lists the DNS settings of all the devices in terse mode.
+var nmcliDnsArgs = []string{"-t", "-f", "GENERAL.DEVICE,IP4.DNS,IP4.DOMAIN,IP6.DNS,IP6.DOMAIN", "device", "show"}
+
+type DnsConfig struct {
+	Nameservers []string
+	Search      []string
+}
+
+func (d DnsConfig) IsEmpty() bool {
+	return len(d.Nameservers) == 0 && len(d.Search) == 0
+}
+
+// ParseResolvConf parses the resolv.conf format.
+func ParseResolvConf(r io.Reader) (DnsConfig, error) {
+	var config DnsConfig
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], ";") {
+			continue
+		}
+		switch fields[0] {
+		case "nameserver":
+			config.Nameservers = append(config.Nameservers, fields[1])
+		case "search":
+			config.Search = fields[1:]
+		case "domain":
+			if len(config.Search) == 0 {
+				config.Search = fields[1:2]
+			}
+		}
+	}
+	return config, scanner.Err()
+}
+
+// isStubResolver tells if nameservers only point to a local resolver, like
+// the systemd-resolved stub at 127.0.0.53.
+func isStubResolver(nameservers []string) bool {
+	if len(nameservers) == 0 {
+		return false
+	}
+	for _, nameserver := range nameservers {
+		ip := net.ParseIP(nameserver)
+		if ip == nil || !ip.IsLoopback() {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseSystemdLinkState parses the KEY=value state files of
+// systemd-resolved (SERVERS, DOMAINS) and systemd-networkd (DNS, DOMAINS).
+func ParseSystemdLinkState(r io.Reader) (DnsConfig, error) {
+	var config DnsConfig
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		switch parts[0] {
+		case "SERVERS", "DNS":
+			for _, server := range strings.Fields(parts[1]) {
+				// Servers may carry a port and a name: 1.1.1.1:53#one.one
+				if i := strings.Index(server, "#"); i > 0 {
+					server = server[:i]
+				}
+				if host, _, err := net.SplitHostPort(server); err == nil {
+					server = host
+				}
+				config.Nameservers = append(config.Nameservers, server)
+			}
+		case "DOMAINS":
+			for _, domain := range strings.Fields(parts[1]) {
+				// Routing only domains are prefixed with ~
+				if !strings.HasPrefix(domain, "~") {
+					config.Search = append(config.Search, domain)
+				}
+			}
+		}
+	}
+	return config, scanner.Err()
+}
+
+// ParseNmcliDevices parses the terse output of "nmcli -t -f
+// GENERAL.DEVICE,IP4.DNS,IP4.DOMAIN,IP6.DNS,IP6.DOMAIN device show" and
+// returns the DNS settings by device.
+func ParseNmcliDevices(r io.Reader) (map[string]DnsConfig, error) {
+	devices := make(map[string]DnsConfig)
+	var device string
+	unescape := strings.NewReplacer(`\:`, ":", `\\`, `\`)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key, value := parts[0], unescape.Replace(strings.TrimSpace(parts[1]))
+		// Lists are numbered: IP4.DNS[1], IP4.DNS[2]...
+		if i := strings.IndexByte(key, '['); i > 0 {
+			key = key[:i]
+		}
+		if key == "GENERAL.DEVICE" {
+			device = value
+			continue
+		}
+		if len(device) == 0 || len(value) == 0 {
+			continue
+		}
+		config := devices[device]
+		switch key {
+		case "IP4.DNS", "IP6.DNS":
+			config.Nameservers = append(config.Nameservers, value)
+		case "IP4.DOMAIN", "IP6.DOMAIN":
+			config.Search = append(config.Search, value)
+		default:
+			continue
+		}
+		devices[device] = config
+	}
+	return devices, scanner.Err()
+}
+
+// readNmcliDevices asks NetworkManager for the DNS settings of its devices.
+func readNmcliDevices(nmcli string, links map[string]DnsConfig) {
+	if len(nmcli) == 0 {
+		return
+	}
+	cmd := exec.Command(nmcli, nmcliDnsArgs...)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return
+	}
+	devices, err := ParseNmcliDevices(&out)
+	if err != nil {
+		return
+	}
+	for name, config := range devices {
+		if _, found := links[name]; !found && !config.IsEmpty() {
+			links[name] = config
+		}
+	}
+}
+
+func readDnsFile(file string, parser func(io.Reader) (DnsConfig, error)) (DnsConfig, error) {
+	handle, err := os.Open(file)
+	if err != nil {
+		return DnsConfig{}, err
+	}
+	defer handle.Close()
+	return parser(handle)
+}
+
+// readLinkStates reads a directory of systemd state files named after
+// the interface indexes.
+func readLinkStates(dir string, links map[string]DnsConfig) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, file := range files {
+		index, err := strconv.Atoi(file.Name())
+		if err != nil {
+			continue
+		}
+		name := interfaceName(index)
+		if _, found := links[name]; found || len(name) == 0 {
+			continue
+		}
+		config, err := readDnsFile(path.Join(dir, file.Name()), ParseSystemdLinkState)
+		if err == nil && !config.IsEmpty() {
+			links[name] = config
+		}
+	}
+}
+
+// ReadDnsConfig returns the system wide resolver configuration and the per
+// interface one, from systemd-resolved, systemd-networkd then
+// NetworkManager.
+func ReadDnsConfig(paths DnsPaths) (DnsConfig, map[string]DnsConfig) {
+	var global DnsConfig
+	for _, file := range paths.ResolvConf {
+		config, err := readDnsFile(file, ParseResolvConf)
+		if err != nil || isStubResolver(config.Nameservers) {
+			continue
+		}
+		global = config
+		break
+	}
+	links := make(map[string]DnsConfig)
+	readLinkStates(paths.ResolvedLinks, links)
+	readLinkStates(paths.NetworkdLinks, links)
+	readNmcliDevices(paths.Nmcli, links)
+	return global, links
+}
@@ -0,0 +1,125 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseResolvConf(t *testing.T) {
+	input := `# Generated by NetworkManager
+domain home.arpa
+search home.arpa example.org
+nameserver 192.168.1.1
+; nameserver 10.0.0.1
+nameserver 2001:db8::53
+options edns0 trust-ad
+nameserver
+`
+	config, err := ParseResolvConf(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := DnsConfig{Nameservers: []string{"192.168.1.1", "2001:db8::53"}, Search: []string{"home.arpa", "example.org"}}
+	if !reflect.DeepEqual(config, want) {
+		t.Errorf("config = %+v, want %+v", config, want)
+	}
+	if config, _ := ParseResolvConf(strings.NewReader("domain lan\nnameserver 127.0.0.53\n")); !reflect.DeepEqual(config.Search, []string{"lan"}) || !isStubResolver(config.Nameservers) {
+		t.Errorf("stub config = %+v", config)
+	}
+	if isStubResolver(nil) || isStubResolver([]string{"127.0.0.53", "192.168.1.1"}) {
+		t.Error("non stub resolver detected as stub")
+	}
+}
+
+func TestParseSystemdLinkState(t *testing.T) {
+	input := `# This is private data. Do not parse.
+LLMNR=yes
+MDNS=no
+SERVERS=192.168.1.1 1.1.1.1:53#one.one.one.one [2001:db8::53]:853
+DOMAINS=home.arpa ~example.org
+NTP=
+garbage
+`
+	config, err := ParseSystemdLinkState(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := DnsConfig{Nameservers: []string{"192.168.1.1", "1.1.1.1", "2001:db8::53"}, Search: []string{"home.arpa"}}
+	if !reflect.DeepEqual(config, want) {
+		t.Errorf("config = %+v, want %+v", config, want)
+	}
+}
+
+func TestParseNmcliDevices(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "nmcli-device-show.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	devices, err := ParseNmcliDevices(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]DnsConfig{
+		"enp1s0": {
+			Nameservers: []string{"192.168.1.1", "9.9.9.9", "fe80::1", "2001:db8::53"},
+			Search:      []string{"home.arpa", "home.arpa"},
+		},
+		"wlp2s0":  {Nameservers: []string{"10.0.0.1"}},
+		"docker0": {Search: []string{`corp\lab`}},
+	}
+	if !reflect.DeepEqual(devices, want) {
+		t.Errorf("devices = %+v, want %+v", devices, want)
+	}
+}
+
+func TestReadDnsConfig(t *testing.T) {
+	loopback, err := net.InterfaceByName("lo")
+	if err != nil {
+		t.Skipf("no loopback interface: %v", err)
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"resolv.conf":         "nameserver 127.0.0.53\nsearch lan\n",
+		"resolve/resolv.conf": "nameserver 192.168.1.1\nsearch home.arpa\n",
+		"resolved/" + strconv.Itoa(loopback.Index): "SERVERS=192.168.1.1\nDOMAINS=home.arpa\n",
+		"networkd/" + strconv.Itoa(loopback.Index): "DNS=10.0.0.1\n",
+		"networkd/999999":                          "DNS=10.0.0.2\n",
+	}
+	for name, content := range files {
+		file := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	global, links := ReadDnsConfig(DnsPaths{
+		ResolvConf:    []string{filepath.Join(dir, "resolv.conf"), filepath.Join(dir, "resolve/resolv.conf")},
+		ResolvedLinks: filepath.Join(dir, "resolved"),
+		NetworkdLinks: filepath.Join(dir, "networkd"),
+	})
+	if want := (DnsConfig{Nameservers: []string{"192.168.1.1"}, Search: []string{"home.arpa"}}); !reflect.DeepEqual(global, want) {
+		t.Errorf("global = %+v, want %+v", global, want)
+	}
+	// systemd-resolved wins over systemd-networkd, unknown indexes are skipped
+	want := map[string]DnsConfig{"lo": {Nameservers: []string{"192.168.1.1"}, Search: []string{"home.arpa"}}}
+	if !reflect.DeepEqual(links, want) {
+		t.Errorf("links = %+v, want %+v", links, want)
+	}
+}
@@ -0,0 +1,213 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/COSAE-FR/ripugw/inform"
+	"github.com/COSAE-FR/ripugw/pfconf"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultDpingerDir = "/var/run"
+
+	GatewayOnline = "online"
+	GatewayDelay  = "delay"
+	GatewayLoss   = "loss"
+	GatewayDown   = "down"
+
+	// pfSense defaults for gateway monitoring thresholds
+	defaultLatencyLow  = 200
+	defaultLatencyHigh = 500
+	defaultLossLow     = 10
+	defaultLossHigh    = 20
+
+	dpingerTimeout = time.Second
+)
+
+// GatewayStatus is the state of a pfSense gateway as reported by dpinger.
+type GatewayStatus struct {
+	Name   string
+	Delay  time.Duration
+	StdDev time.Duration
+	Loss   float64
+	Status string
+}
+
+// ParseDpingerStatus parses the "name latency_us stddev_us loss" line
+// written by dpinger on its status socket.
+func ParseDpingerStatus(line string) (GatewayStatus, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 {
+		return GatewayStatus{}, fmt.Errorf("invalid dpinger status: %q", line)
+	}
+	delay, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return GatewayStatus{}, fmt.Errorf("invalid dpinger latency: %v", err)
+	}
+	stddev, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return GatewayStatus{}, fmt.Errorf("invalid dpinger deviation: %v", err)
+	}
+	loss, err := strconv.ParseFloat(fields[3], 64)
+	if err != nil {
+		return GatewayStatus{}, fmt.Errorf("invalid dpinger loss: %v", err)
+	}
+	return GatewayStatus{
+		Name:   fields[0],
+		Delay:  time.Duration(delay) * time.Microsecond,
+		StdDev: time.Duration(stddev) * time.Microsecond,
+		Loss:   loss,
+	}, nil
+}
+
+// ReadDpingerSocket reads the current status from a dpinger unix socket.
+func ReadDpingerSocket(path string) (GatewayStatus, error) {
+	conn, err := net.DialTimeout("unix", path, dpingerTimeout)
+	if err != nil {
+		return GatewayStatus{}, err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(dpingerTimeout)); err != nil {
+		return GatewayStatus{}, err
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil && len(line) == 0 {
+		return GatewayStatus{}, err
+	}
+	return ParseDpingerStatus(line)
+}
+
+// DpingerSockets lists the dpinger sockets of dir by gateway name. pfSense
+// names them dpinger_<gateway>~<source>~<monitor>.sock.
+func DpingerSockets(dir string) map[string]string {
+	sockets := make(map[string]string)
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return sockets
+	}
+	for _, file := range files {
+		name := file.Name()
+		if !strings.HasPrefix(name, "dpinger_") || !strings.HasSuffix(name, ".sock") || file.Mode()&os.ModeSocket == 0 {
+			continue
+		}
+		gateway := strings.TrimSuffix(strings.TrimPrefix(name, "dpinger_"), ".sock")
+		if i := strings.IndexByte(gateway, '~'); i >= 0 {
+			gateway = gateway[:i]
+		}
+		sockets[gateway] = filepath.Join(dir, name)
+	}
+	return sockets
+}
+
+// gatewayName returns the name of the gateway of a pfSense interface: a
+// configured gateway item or the dynamic <IF>_DHCP / <IF>_PPPOE gateway.
+func gatewayName(pfInterface pfconf.Interface, gateways []pfconf.Gateway, sockets map[string]string) (string, pfconf.Gateway) {
+	ifName := pfInterface.XMLName.Local
+	for _, gateway := range gateways {
+		if gateway.Interface == ifName && gateway.Protocol != "inet6" && (len(pfInterface.Gateway) == 0 || gateway.Name == pfInterface.Gateway) {
+			return gateway.Name, gateway
+		}
+	}
+	prefix := strings.ToUpper(ifName) + "_"
+	for name := range sockets {
+		if strings.HasPrefix(name, prefix) && !strings.HasSuffix(name, "V6") {
+			return name, pfconf.Gateway{Name: name, Interface: ifName}
+		}
+	}
+	switch pfInterface.Ip {
+	case "dhcp", "pppoe":
+		name := prefix + strings.ToUpper(pfInterface.Ip)
+		return name, pfconf.Gateway{Name: name, Interface: ifName}
+	}
+	return "", pfconf.Gateway{}
+}
+
+func thresholdOrDefault(value string, fallback float64) float64 {
+	threshold, err := strconv.ParseFloat(value, 64)
+	if err != nil || threshold <= 0 {
+		return fallback
+	}
+	return threshold
+}
+
+// evaluate applies the pfSense gateway thresholds to the dpinger figures.
+func (s *GatewayStatus) evaluate(gateway pfconf.Gateway) {
+	delay := float64(s.Delay) / float64(time.Millisecond)
+	switch {
+	case s.Loss >= thresholdOrDefault(gateway.LossHigh, defaultLossHigh),
+		delay >= thresholdOrDefault(gateway.LatencyHigh, defaultLatencyHigh):
+		s.Status = GatewayDown
+	case s.Loss >= thresholdOrDefault(gateway.LossLow, defaultLossLow):
+		s.Status = GatewayLoss
+	case delay >= thresholdOrDefault(gateway.LatencyLow, defaultLatencyLow):
+		s.Status = GatewayDelay
+	default:
+		s.Status = GatewayOnline
+	}
+}
+
+// InterfaceGatewayStatus reads the dpinger status of the gateway of a
+// pfSense interface.
+func InterfaceGatewayStatus(dir string, pfInterface pfconf.Interface, gateways []pfconf.Gateway) (GatewayStatus, error) {
+	if len(dir) == 0 {
+		return GatewayStatus{}, fmt.Errorf("dpinger disabled")
+	}
+	sockets := DpingerSockets(dir)
+	name, gateway := gatewayName(pfInterface, gateways, sockets)
+	if len(name) == 0 {
+		return GatewayStatus{}, fmt.Errorf("no gateway for interface %s", pfInterface.XMLName.Local)
+	}
+	if gateway.MonitorDisable {
+		return GatewayStatus{}, fmt.Errorf("monitoring disabled for gateway %s", name)
+	}
+	path, ok := sockets[name]
+	if !ok {
+		return GatewayStatus{}, fmt.Errorf("no dpinger socket for gateway %s", name)
+	}
+	status, err := ReadDpingerSocket(path)
+	if err != nil {
+		return status, err
+	}
+	status.evaluate(gateway)
+	return status, nil
+}
+
+// applyGatewayStatus reports the dpinger status of a WAN like applyUplink.
+func applyGatewayStatus(request *inform.Inform, wan *inform.Interface, label string, status GatewayStatus) {
+	wan.Up = wan.Up && status.Status != GatewayDown
+	wan.Latency = 0
+	if wan.Up {
+		wan.Latency = uint64(status.Delay / time.Millisecond)
+		if wan.Latency == 0 {
+			wan.Latency = 1
+		}
+	}
+	if request.UptimeStats == nil {
+		request.UptimeStats = make(map[string]inform.UptimeStat)
+	}
+	request.UptimeStats[label] = inform.UptimeStat{
+		Availability:   100 - status.Loss,
+		LatencyAverage: float64(status.Delay) / float64(time.Millisecond),
+		Monitors: []inform.UptimeMonitor{{
+			Availability:   100 - status.Loss,
+			LatencyAverage: float64(status.Delay) / float64(time.Millisecond),
+			Target:         status.Name,
+			Type:           "dpinger",
+		}},
+	}
+}
@@ -0,0 +1,120 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"encoding/xml"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/COSAE-FR/ripugw/pfconf"
+)
+
+// fakeDpinger serves a fixed status line on a unix socket like dpinger.
+type fakeDpinger struct {
+	line     string
+	listener net.Listener
+}
+
+func newFakeDpinger(t *testing.T, path string, line string) *fakeDpinger {
+	t.Helper()
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := &fakeDpinger{line: line, listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_, _ = conn.Write([]byte(fake.line + "\n"))
+			_ = conn.Close()
+		}
+	}()
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+	return fake
+}
+
+func TestParseDpingerStatus(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    GatewayStatus
+		wantErr bool
+	}{
+		{
+			line: "WAN_DHCP 12345 678 0\n",
+			want: GatewayStatus{Name: "WAN_DHCP", Delay: 12345 * time.Microsecond, StdDev: 678 * time.Microsecond},
+		},
+		{
+			line: "GW_WAN2 250000 1000 12.5",
+			want: GatewayStatus{Name: "GW_WAN2", Delay: 250 * time.Millisecond, StdDev: time.Millisecond, Loss: 12.5},
+		},
+		{line: "WAN_DHCP 12345 678", wantErr: true},
+		{line: "WAN_DHCP fast 678 0", wantErr: true},
+		{line: "WAN_DHCP 12345 - 0", wantErr: true},
+		{line: "WAN_DHCP 12345 678 none", wantErr: true},
+		{line: "", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := ParseDpingerStatus(test.line)
+		if (err != nil) != test.wantErr {
+			t.Errorf("ParseDpingerStatus(%q) error = %v, want error %v", test.line, err, test.wantErr)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("ParseDpingerStatus(%q) = %+v, want %+v", test.line, got, test.want)
+		}
+	}
+}
+
+func TestInterfaceGatewayStatus(t *testing.T) {
+	dir := t.TempDir()
+	newFakeDpinger(t, filepath.Join(dir, "dpinger_WAN_DHCP~198.51.100.2~1.1.1.1.sock"), "WAN_DHCP 20000 500 0")
+	newFakeDpinger(t, filepath.Join(dir, "dpinger_GW_WAN2~203.0.113.2~9.9.9.9.sock"), "GW_WAN2 80000 500 15")
+	newFakeDpinger(t, filepath.Join(dir, "dpinger_GW_SLOW~192.0.2.2~9.9.9.9.sock"), "GW_SLOW 600000 500 0")
+	gateways := []pfconf.Gateway{
+		{Interface: "opt1", Name: "GW_WAN2", Protocol: "inet"},
+		{Interface: "opt2", Name: "GW_SLOW", Protocol: "inet", LatencyHigh: "1000"},
+		{Interface: "opt3", Name: "GW_QUIET", Protocol: "inet", MonitorDisable: true},
+	}
+	pfInterface := func(name string, ip string) pfconf.Interface {
+		return pfconf.Interface{XMLName: xml.Name{Local: name}, Ip: ip}
+	}
+	tests := []struct {
+		iface   pfconf.Interface
+		name    string
+		status  string
+		wantErr bool
+	}{
+		{pfInterface("wan", "dhcp"), "WAN_DHCP", GatewayOnline, false},
+		{pfInterface("opt1", "203.0.113.1"), "GW_WAN2", GatewayLoss, false},
+		{pfInterface("opt2", "192.0.2.1"), "GW_SLOW", GatewayDelay, false},
+		{pfInterface("opt3", "192.0.2.9"), "", "", true},
+		{pfInterface("opt4", "pppoe"), "", "", true},
+	}
+	for _, test := range tests {
+		status, err := InterfaceGatewayStatus(dir, test.iface, gateways)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: error = %v, want error %v", test.iface.XMLName.Local, err, test.wantErr)
+			continue
+		}
+		if status.Name != test.name || status.Status != test.status {
+			t.Errorf("%s: status = %+v, want %s %s", test.iface.XMLName.Local, status, test.name, test.status)
+		}
+	}
+	if _, err := InterfaceGatewayStatus("", pfInterface("wan", "dhcp"), gateways); err == nil {
+		t.Error("status read with dpinger disabled")
+	}
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"fmt"
+	"github.com/COSAE-FR/ripugw/inform"
+	"net"
+	"time"
+)
+
+// HostTables returns the LAN clients seen in the neighbour tables, grouped
+// by physical interface name.
+func HostTables(leaseFiles []string) (map[string][]inform.Host, error) {
+	neighbors, err := Neighbors()
+	if err != nil {
+		return nil, err
+	}
+	if len(leaseFiles) == 0 {
+		leaseFiles = DefaultLeaseFiles
+	}
+	return BuildHostTables(neighbors, ReadLeases(leaseFiles), time.Now()), nil
+}
+
+// BuildHostTables merges neighbour entries sharing a MAC address and
+// enriches them with the hostname and lease start of their DHCP lease.
+func BuildHostTables(neighbors []Neighbor, leases []Lease, now time.Time) map[string][]inform.Host {
+	byMac := make(map[string]Lease)
+	byIp := make(map[string]Lease)
+	for _, lease := range leases {
+		if lease.Mac != nil {
+			if previous, ok := byMac[lease.Mac.String()]; !ok || !previous.Active || lease.Active {
+				byMac[lease.Mac.String()] = lease
+			}
+		}
+		if lease.Ip != nil {
+			byIp[lease.Ip.String()] = lease
+		}
+	}
+
+	tables := make(map[string][]inform.Host)
+	seen := make(map[string]int)
+	for _, neighbor := range neighbors {
+		key := neighbor.Interface + "/" + neighbor.Mac.String()
+		i, ok := seen[key]
+		if !ok {
+			i = len(tables[neighbor.Interface])
+			seen[key] = i
+			tables[neighbor.Interface] = append(tables[neighbor.Interface], inform.Host{
+				Mac:        inform.HardwareAddr(neighbor.Mac),
+				Vendor:     inform.HardwareAddr(neighbor.Mac).Vendor(),
+				Authorized: true,
+			})
+		}
+		host := &tables[neighbor.Interface][i]
+		if neighbor.Ip.To4() != nil || len(host.Ip) == 0 {
+			host.Ip = neighbor.Ip.String()
+		}
+		lease, ok := byMac[neighbor.Mac.String()]
+		if !ok {
+			lease, ok = byIp[neighbor.Ip.String()]
+		}
+		if ok {
+			if len(lease.Hostname) > 0 {
+				host.Hostname = lease.Hostname
+			}
+			if !lease.Start.IsZero() && lease.Start.Before(now) {
+				host.Uptime = uint64(now.Sub(lease.Start).Seconds())
+			}
+		}
+	}
+	return tables
+}
+
+// networkEntry describes a LAN interface and its clients.
+func networkEntry(name string, physical inform.Interface, hosts map[string][]inform.Host) inform.Network {
+	network := inform.Network{
+		Name:      name,
+		Mac:       physical.Mac,
+		Ip:        physical.Ip,
+		Netmask:   physical.Netmask,
+		Up:        physical.Up,
+		HostTable: hosts[physical.Name],
+		Ipv6:      physical.Ipv6,
+	}
+	network.Ipv6Prefix = ipv6Prefix(physical.Ipv6)
+	if network.HostTable == nil {
+		network.HostTable = []inform.Host{}
+	}
+	network.NumSta = len(network.HostTable)
+	if ip := net.ParseIP(physical.Ip); ip != nil {
+		mask := net.IPMask(net.ParseIP(physical.Netmask).To4())
+		if ones, bits := mask.Size(); bits > 0 {
+			network.Address = fmt.Sprintf("%s/%d", physical.Ip, ones)
+		}
+	}
+	return network
+}
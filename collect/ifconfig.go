@@ -0,0 +1,204 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// IfconfigInterface is an interface block of the FreeBSD ifconfig output.
+type IfconfigInterface struct {
+	Name    string
+	Flags   []string
+	Options []string
+	Mtu     int
+	Ether   string
+	// Media is the full media line, Subtype the active media
+	// ("1000baseT") and MediaOptions its options ("full-duplex").
+	Media        string
+	Subtype      string
+	MediaOptions []string
+	// Status is "active", "no carrier"... when reported
+	Status     string
+	VlanTag    int
+	VlanParent string
+	LaggProto  string
+	LaggPorts  []string
+	Members    []string
+	Groups     []string
+}
+
+var (
+	ifconfigHeader = regexp.MustCompile(`^([^\s:]+): flags=[0-9a-f]+<([^>]*)>(.*)$`)
+	ifconfigList   = regexp.MustCompile(`^[0-9a-f]+<([^>]*)>`)
+	mediaSubtype   = regexp.MustCompile(`^([^<\s]+)(?:\s+<([^>]*)>)?`)
+	mediaSpeed     = regexp.MustCompile(`^(\d+)([gm]?)base`)
+	vlanLine       = regexp.MustCompile(`vlan: (\d+).*parent interface: (\S+)`)
+)
+
+func ifconfigFlags(value string) []string {
+	if len(value) == 0 {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// ParseIfconfig parses the output of "ifconfig" or "ifconfig -a".
+func ParseIfconfig(r io.Reader) ([]IfconfigInterface, error) {
+	var interfaces []IfconfigInterface
+	var current *IfconfigInterface
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) == 0 {
+			continue
+		}
+		if line[0] != ' ' && line[0] != '\t' {
+			match := ifconfigHeader.FindStringSubmatch(line)
+			if match == nil {
+				current = nil
+				continue
+			}
+			interfaces = append(interfaces, IfconfigInterface{
+				Name:  match[1],
+				Flags: ifconfigFlags(match[2]),
+			})
+			current = &interfaces[len(interfaces)-1]
+			fields := strings.Fields(match[3])
+			for i := 0; i+1 < len(fields); i++ {
+				if fields[i] == "mtu" {
+					current.Mtu, _ = strconv.Atoi(fields[i+1])
+				}
+			}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		line = strings.TrimSpace(line)
+		key, value := line, ""
+		if i := strings.IndexAny(line, ": =\t"); i > 0 {
+			key = line[:i]
+			value = strings.TrimLeft(line[i:], ": =\t")
+		}
+		switch key {
+		case "options":
+			if match := ifconfigList.FindStringSubmatch(value); match != nil {
+				current.Options = ifconfigFlags(match[1])
+			}
+		case "ether":
+			current.Ether = value
+		case "media":
+			current.parseMedia(value)
+		case "status":
+			current.Status = value
+		case "vlan":
+			if match := vlanLine.FindStringSubmatch(line); match != nil {
+				current.VlanTag, _ = strconv.Atoi(match[1])
+				current.VlanParent = match[2]
+			}
+		case "laggproto":
+			if fields := strings.Fields(value); len(fields) > 0 {
+				current.LaggProto = fields[0]
+			}
+		case "laggport":
+			if fields := strings.Fields(value); len(fields) > 0 {
+				current.LaggPorts = append(current.LaggPorts, fields[0])
+			}
+		case "member":
+			if fields := strings.Fields(value); len(fields) > 0 {
+				current.Members = append(current.Members, fields[0])
+			}
+		case "groups":
+			current.Groups = strings.Fields(value)
+		}
+	}
+	return interfaces, scanner.Err()
+}
+
+// parseMedia reads "Ethernet autoselect (1000baseT <full-duplex>)" or
+// "Ethernet 10Gbase-SR <full-duplex,rxpause>".
+func (i *IfconfigInterface) parseMedia(value string) {
+	i.Media = value
+	active := value
+	if start := strings.Index(value, "("); start >= 0 {
+		active = strings.TrimSuffix(value[start+1:], ")")
+	} else if fields := strings.Fields(value); len(fields) > 1 {
+		active = strings.Join(fields[1:], " ")
+	}
+	match := mediaSubtype.FindStringSubmatch(strings.TrimSpace(active))
+	if match == nil {
+		return
+	}
+	i.Subtype = match[1]
+	i.MediaOptions = ifconfigFlags(match[2])
+}
+
+func (i IfconfigInterface) HasFlag(flag string) bool {
+	for _, f := range i.Flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+func (i IfconfigInterface) HasGroup(group string) bool {
+	for _, g := range i.Groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+// Up tells if the link is usable: administratively up and, when the
+// driver reports it, with a carrier.
+func (i IfconfigInterface) Up() bool {
+	if !i.HasFlag("UP") {
+		return false
+	}
+	if len(i.Status) > 0 {
+		return i.Status == "active" || i.Status == "associated"
+	}
+	return i.HasFlag("RUNNING")
+}
+
+// InterfaceMedia returns the speed and duplex of the active media, the
+// default media when unknown.
+func (i IfconfigInterface) InterfaceMedia() InterfaceMedia {
+	media := defaultMedia()
+	media.Mtu = i.Mtu
+	match := mediaSpeed.FindStringSubmatch(strings.ToLower(i.Subtype))
+	if match == nil {
+		media.Virtual = len(i.Media) == 0
+		return media
+	}
+	speed, err := strconv.ParseUint(match[1], 10, 64)
+	if err != nil {
+		return media
+	}
+	if match[2] == "g" {
+		speed *= 1000
+	}
+	media.Speed = speed
+	for _, option := range i.MediaOptions {
+		switch option {
+		case "full-duplex":
+			media.FullDuplex = true
+		case "half-duplex":
+			media.FullDuplex = false
+		}
+	}
+	return media
+}
@@ -0,0 +1,125 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseIfconfig(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "ifconfig-a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	interfaces, err := ParseIfconfig(f)
+	if err != nil {
+		t.Fatalf("ParseIfconfig() = %v", err)
+	}
+	byName := make(map[string]IfconfigInterface)
+	var names []string
+	for _, iface := range interfaces {
+		byName[iface.Name] = iface
+		names = append(names, iface.Name)
+	}
+	wantNames := []string{"em0", "em1", "igb0", "ix0", "ix1", "enc0", "lo0", "em1.10", "lagg0", "bridge0", "ovpns1", "pppoe0"}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Fatalf("interfaces = %v, want %v", names, wantNames)
+	}
+
+	tests := []struct {
+		name   string
+		mtu    int
+		ether  string
+		up     bool
+		status string
+		media  InterfaceMedia
+		groups []string
+	}{
+		{"em0", 1500, "00:0d:b9:4a:11:20", true, "active", InterfaceMedia{Speed: 1000, FullDuplex: true, Mtu: 1500}, nil},
+		{"em1", 9000, "00:0d:b9:4a:11:21", true, "active", InterfaceMedia{Speed: 100, Mtu: 9000}, nil},
+		{"igb0", 1500, "00:0d:b9:4a:11:22", false, "no carrier", InterfaceMedia{Speed: 1000, FullDuplex: true, Mtu: 1500}, nil},
+		{"ix0", 1500, "00:0d:b9:4a:11:30", true, "active", InterfaceMedia{Speed: 10000, FullDuplex: true, Mtu: 1500}, nil},
+		{"enc0", 1536, "", false, "", InterfaceMedia{Speed: 1000, FullDuplex: true, Virtual: true, Mtu: 1536}, []string{"enc"}},
+		{"lo0", 16384, "", true, "", InterfaceMedia{Speed: 1000, FullDuplex: true, Virtual: true, Mtu: 16384}, []string{"lo"}},
+		{"em1.10", 1500, "00:0d:b9:4a:11:21", true, "active", InterfaceMedia{Speed: 100, Mtu: 1500}, []string{"vlan"}},
+		{"lagg0", 1500, "00:0d:b9:4a:11:30", true, "active", InterfaceMedia{Speed: 1000, FullDuplex: true, Mtu: 1500}, []string{"lagg"}},
+		{"bridge0", 1500, "58:9c:fc:10:ff:e2", true, "", InterfaceMedia{Speed: 1000, FullDuplex: true, Virtual: true, Mtu: 1500}, []string{"bridge"}},
+		{"ovpns1", 1500, "", true, "", InterfaceMedia{Speed: 1000, FullDuplex: true, Virtual: true, Mtu: 1500}, []string{"tun", "openvpn"}},
+		{"pppoe0", 1492, "", true, "", InterfaceMedia{Speed: 1000, FullDuplex: true, Virtual: true, Mtu: 1492}, nil},
+	}
+	for _, test := range tests {
+		iface := byName[test.name]
+		if iface.Mtu != test.mtu || iface.Ether != test.ether || iface.Status != test.status {
+			t.Errorf("%s: mtu %d ether %q status %q, want %d %q %q", test.name, iface.Mtu, iface.Ether, iface.Status, test.mtu, test.ether, test.status)
+		}
+		if iface.Up() != test.up {
+			t.Errorf("%s: Up() = %v, want %v", test.name, iface.Up(), test.up)
+		}
+		if media := iface.InterfaceMedia(); media != test.media {
+			t.Errorf("%s: InterfaceMedia() = %+v, want %+v", test.name, media, test.media)
+		}
+		if !reflect.DeepEqual(iface.Groups, test.groups) {
+			t.Errorf("%s: groups = %v, want %v", test.name, iface.Groups, test.groups)
+		}
+	}
+
+	ix0 := byName["ix0"]
+	if ix0.Subtype != "10Gbase-SR" || !reflect.DeepEqual(ix0.MediaOptions, []string{"full-duplex", "rxpause", "txpause"}) {
+		t.Errorf("ix0 media = %q %v", ix0.Subtype, ix0.MediaOptions)
+	}
+	if em0 := byName["em0"]; !em0.HasFlag("RUNNING") || len(em0.Options) != 8 || em0.Options[0] != "RXCSUM" {
+		t.Errorf("em0 flags = %v, options = %v", em0.Flags, em0.Options)
+	}
+	if enc0 := byName["enc0"]; len(enc0.Flags) != 0 {
+		t.Errorf("enc0 flags = %v, want none", enc0.Flags)
+	}
+	if vlan := byName["em1.10"]; vlan.VlanTag != 10 || vlan.VlanParent != "em1" {
+		t.Errorf("em1.10 vlan = %d on %q, want 10 on em1", vlan.VlanTag, vlan.VlanParent)
+	}
+	if lagg := byName["lagg0"]; lagg.LaggProto != "lacp" || !reflect.DeepEqual(lagg.LaggPorts, []string{"ix0", "ix1"}) {
+		t.Errorf("lagg0 = %s %v, want lacp [ix0 ix1]", lagg.LaggProto, lagg.LaggPorts)
+	}
+	if bridge := byName["bridge0"]; !reflect.DeepEqual(bridge.Members, []string{"igb0", "em1.10"}) {
+		t.Errorf("bridge0 members = %v, want [igb0 em1.10]", bridge.Members)
+	}
+	if tun := byName["ovpns1"]; !tun.HasGroup("tun") || !tun.HasFlag("POINTOPOINT") {
+		t.Errorf("ovpns1 = %+v", tun)
+	}
+}
+
+func TestParseIfconfigMalformed(t *testing.T) {
+	input := strings.Join([]string{
+		"\tinet 192.0.2.1 netmask 0xffffff00",
+		"garbage without flags",
+		"\tstatus: active",
+		"em0: flags=8843<UP,BROADCAST,RUNNING,SIMPLEX,MULTICAST> metric 0 mtu lots",
+		"\tmedia: Ethernet autoselect (unknown)",
+		"\tvlan: none",
+		"",
+	}, "\n")
+	interfaces, err := ParseIfconfig(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseIfconfig() = %v", err)
+	}
+	if len(interfaces) != 1 || interfaces[0].Name != "em0" {
+		t.Fatalf("interfaces = %+v, want em0 only", interfaces)
+	}
+	em0 := interfaces[0]
+	if em0.Mtu != 0 || em0.Status != "" || em0.VlanTag != 0 {
+		t.Errorf("em0 = %+v", em0)
+	}
+	if media := em0.InterfaceMedia(); media != defaultMedia() {
+		t.Errorf("InterfaceMedia() = %+v, want the default", media)
+	}
+}
@@ -0,0 +1,124 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"fmt"
+	"github.com/COSAE-FR/ripugw/inform"
+	"github.com/COSAE-FR/ripugw/pfconf"
+	"net"
+)
+
+// GetIPv6ForInterface returns the IPv6 addresses of an interface, link-local
+// addresses excepted.
+func GetIPv6ForInterface(interfaceName string) ([]*net.IPNet, error) {
+	iface, err := net.InterfaceByName(interfaceName)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	var result []*net.IPNet
+	for _, addr := range addrs {
+		ip, ok := addr.(*net.IPNet)
+		if !ok || ip.IP.To4() != nil || ip.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		result = append(result, ip)
+	}
+	return result, nil
+}
+
+// Ipv6ConfigType guesses how the IPv6 addresses of an interface were
+// configured, from the kernel address flags. It returns an empty string
+// when unknown.
+func Ipv6ConfigType(interfaceName string) string {
+	return getIpv6ConfigType(interfaceName)
+}
+
+// ipv6Prefix returns the network of the first global address of an
+// interface, the prefix delegated to a LAN.
+func ipv6Prefix(addresses []string) string {
+	for _, address := range addresses {
+		ip, network, err := net.ParseCIDR(address)
+		if err != nil || !ip.IsGlobalUnicast() {
+			continue
+		}
+		return network.String()
+	}
+	return ""
+}
+
+// pfSenseIpv6Type maps the pfSense ipaddrv6 setting to a network config type.
+func pfSenseIpv6Type(pfInterface pfconf.Interface) string {
+	switch pfInterface.Ipv6 {
+	case "":
+		return inform.NetworkConfigDisabled
+	case "dhcp6":
+		return inform.NetworkConfigDhcpv6
+	case "slaac":
+		return inform.NetworkConfigSlaac
+	case "track6":
+		return inform.NetworkConfigTrack
+	case "6rd", "6to4":
+		return pfInterface.Ipv6
+	}
+	if net.ParseIP(pfInterface.Ipv6) != nil {
+		return inform.NetworkConfigStatic
+	}
+	return ""
+}
+
+// pfSenseGateway6 resolves the static IPv6 gateway of a pfSense interface.
+// Dynamic gateways are not in the configuration.
+func pfSenseGateway6(pfInterface pfconf.Interface, gateways []pfconf.Gateway) string {
+	for _, gateway := range gateways {
+		if gateway.Name == pfInterface.Gatewayv6 && net.ParseIP(gateway.Gateway) != nil {
+			return gateway.Gateway
+		}
+	}
+	return ""
+}
+
+// applyIpv6Config fills the IPv6 part of a WAN network config.
+func applyIpv6Config(config *inform.NetworkConfig, physical inform.Interface, ipv6Type string, gateway string) {
+	if len(ipv6Type) == 0 && len(physical.Ipv6) > 0 {
+		ipv6Type = inform.NetworkConfigSlaac
+	}
+	config.Type6 = ipv6Type
+	if len(physical.Ipv6) > 0 {
+		config.Ip6 = physical.Ipv6[0]
+	}
+	if ipv6Type != inform.NetworkConfigDisabled {
+		config.Gateway6 = gateway
+	}
+}
+
+// applyPfSenseIpv6Network fills the IPv6 settings of a pfSense LAN network.
+func applyPfSenseIpv6Network(network *inform.Network, pfInterface pfconf.Interface, translation PfSenseTranslationTable) {
+	switch pfSenseIpv6Type(pfInterface) {
+	case inform.NetworkConfigTrack:
+		network.Ipv6Type = "pd"
+		network.Ipv6PdIf = pfInterface.Track6If
+		if role := translation.Role(pfInterface.Track6If); len(role) > 0 {
+			network.Ipv6PdIf = role
+		}
+		network.Ipv6PdPrefixId = pfInterface.Track6PrefixId
+	case inform.NetworkConfigStatic:
+		network.Ipv6Type = "static"
+		if len(network.Ipv6) == 0 {
+			network.Ipv6 = []string{fmt.Sprintf("%s/%d", pfInterface.Ipv6, pfInterface.Subnetv6)}
+			network.Ipv6Prefix = ipv6Prefix(network.Ipv6)
+		}
+	default:
+		network.Ipv6Type = "none"
+	}
+}
@@ -0,0 +1,15 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+// getIpv6ConfigType is unknown on FreeBSD: pfSense mode reads it from the
+// configuration.
+func getIpv6ConfigType(interfaceName string) string {
+	return ""
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"github.com/COSAE-FR/ripugw/inform"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	procIfInet6 = "/proc/net/if_inet6"
+
+	ifaFlagPermanent = 0x80
+)
+
+// Ipv6Address is a line of /proc/net/if_inet6.
+type Ipv6Address struct {
+	Address   *net.IPNet
+	Interface string
+	Flags     uint64
+}
+
+func parseHexIp(value string) (net.IP, error) {
+	ip, err := hex.DecodeString(value)
+	if err != nil || len(ip) != net.IPv6len {
+		return nil, fmt.Errorf("invalid IPv6 address %s", value)
+	}
+	return ip, nil
+}
+
+// ParseIfInet6 parses the /proc/net/if_inet6 format.
+func ParseIfInet6(reader io.Reader) ([]Ipv6Address, error) {
+	var addresses []Ipv6Address
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 {
+			continue
+		}
+		ip, err := parseHexIp(fields[0])
+		if err != nil {
+			continue
+		}
+		length, err := strconv.ParseUint(fields[2], 16, 8)
+		if err != nil {
+			continue
+		}
+		flags, _ := strconv.ParseUint(fields[4], 16, 32)
+		addresses = append(addresses, Ipv6Address{
+			Address:   &net.IPNet{IP: ip, Mask: net.CIDRMask(int(length), 128)},
+			Interface: fields[5],
+			Flags:     flags,
+		})
+	}
+	return addresses, scanner.Err()
+}
+
+// getIpv6ConfigType reads the address flags of an interface from
+// /proc/net/if_inet6.
+func getIpv6ConfigType(interfaceName string) string {
+	file, err := os.Open(procIfInet6)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	addresses, err := ParseIfInet6(file)
+	if err != nil {
+		return ""
+	}
+	return ipv6ConfigType(addresses, interfaceName)
+}
+
+// ipv6ConfigType tells permanent addresses (static) from dynamic ones:
+// DHCPv6 assigns single /128 addresses, SLAAC derives from a prefix.
+func ipv6ConfigType(addresses []Ipv6Address, interfaceName string) string {
+	result := inform.NetworkConfigDisabled
+	for _, address := range addresses {
+		if address.Interface != interfaceName || !address.Address.IP.IsGlobalUnicast() {
+			continue
+		}
+		if address.Flags&ifaFlagPermanent != 0 {
+			return inform.NetworkConfigStatic
+		}
+		if ones, _ := address.Address.Mask.Size(); ones == 128 {
+			result = inform.NetworkConfigDhcpv6
+		} else if result != inform.NetworkConfigDhcpv6 {
+			result = inform.NetworkConfigSlaac
+		}
+	}
+	return result
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"github.com/COSAE-FR/ripugw/inform"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func loadIfInet6(t *testing.T) []Ipv6Address {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", "if_inet6"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	addresses, err := ParseIfInet6(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return addresses
+}
+
+func TestParseIfInet6(t *testing.T) {
+	addresses := loadIfInet6(t)
+	if len(addresses) != 8 {
+		t.Fatalf("got %d addresses, want 8", len(addresses))
+	}
+	first := addresses[0]
+	if first.Address.String() != "2001:db8:0:1::2/64" || first.Interface != "eth0" || first.Flags != ifaFlagPermanent {
+		t.Errorf("first address = %s on %s flags %#x", first.Address, first.Interface, first.Flags)
+	}
+	dhcp := addresses[4]
+	if ones, _ := dhcp.Address.Mask.Size(); !dhcp.Address.IP.Equal(net.ParseIP("2001:db8:0:3::abcd")) || ones != 128 {
+		t.Errorf("DHCPv6 address = %s", dhcp.Address)
+	}
+	if last := addresses[7]; !last.Address.IP.IsLoopback() || last.Interface != "lo" {
+		t.Errorf("last address = %s on %s", last.Address, last.Interface)
+	}
+}
+
+func TestParseIfInet6SkipsMalformedLines(t *testing.T) {
+	addresses, err := ParseIfInet6(strings.NewReader("zz 02 40 00 80 eth0\n20010db8000000010000000000000002 02 40\n"))
+	if err != nil || len(addresses) != 0 {
+		t.Errorf("ParseIfInet6() = %v, %v, want nothing", addresses, err)
+	}
+}
+
+func TestIpv6ConfigType(t *testing.T) {
+	addresses := loadIfInet6(t)
+	tests := []struct {
+		iface string
+		want  string
+	}{
+		// Permanent global address
+		{"eth0", inform.NetworkConfigStatic},
+		// Dynamic address from a /64 prefix
+		{"eth1", inform.NetworkConfigSlaac},
+		// A dynamic /128 wins over the SLAAC address
+		{"eth2", inform.NetworkConfigDhcpv6},
+		// Link-local only
+		{"eth3", inform.NetworkConfigDisabled},
+		{"lo", inform.NetworkConfigDisabled},
+		{"eth9", inform.NetworkConfigDisabled},
+	}
+	for _, test := range tests {
+		if got := ipv6ConfigType(addresses, test.iface); got != test.want {
+			t.Errorf("ipv6ConfigType(%s) = %q, want %q", test.iface, got, test.want)
+		}
+	}
+}
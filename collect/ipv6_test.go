@@ -0,0 +1,125 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"github.com/COSAE-FR/ripugw/inform"
+	"github.com/COSAE-FR/ripugw/pfconf"
+	"reflect"
+	"testing"
+)
+
+func TestPfSenseIpv6Type(t *testing.T) {
+	tests := []struct {
+		ipaddrv6 string
+		want     string
+	}{
+		{"", inform.NetworkConfigDisabled},
+		{"dhcp6", inform.NetworkConfigDhcpv6},
+		{"slaac", inform.NetworkConfigSlaac},
+		{"track6", inform.NetworkConfigTrack},
+		{"6rd", "6rd"},
+		{"6to4", "6to4"},
+		{"2001:db8:1::1", inform.NetworkConfigStatic},
+		{"192.0.2.1", inform.NetworkConfigStatic},
+		{"pppoe", ""},
+	}
+	for _, test := range tests {
+		if got := pfSenseIpv6Type(pfconf.Interface{Ipv6: test.ipaddrv6}); got != test.want {
+			t.Errorf("pfSenseIpv6Type(%q) = %q, want %q", test.ipaddrv6, got, test.want)
+		}
+	}
+}
+
+func TestPfSenseGateway6(t *testing.T) {
+	gateways := []pfconf.Gateway{
+		{Name: "WAN_DHCP6", Gateway: "dynamic"},
+		{Name: "WAN_GW6", Gateway: "2001:db8::1"},
+	}
+	tests := []struct {
+		gatewayv6 string
+		want      string
+	}{
+		{"WAN_GW6", "2001:db8::1"},
+		{"WAN_DHCP6", ""},
+		{"MISSING", ""},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if got := pfSenseGateway6(pfconf.Interface{Gatewayv6: test.gatewayv6}, gateways); got != test.want {
+			t.Errorf("pfSenseGateway6(%q) = %q, want %q", test.gatewayv6, got, test.want)
+		}
+	}
+}
+
+func TestApplyIpv6Config(t *testing.T) {
+	addresses := []string{"2001:db8:1::2/64", "2001:db8:1::3/64"}
+	tests := []struct {
+		name      string
+		addresses []string
+		ipv6Type  string
+		gateway   string
+		want      inform.NetworkConfig
+	}{
+		{"disabled", nil, inform.NetworkConfigDisabled, "fe80::1",
+			inform.NetworkConfig{Type6: inform.NetworkConfigDisabled}},
+		{"unknown without address", nil, "", "",
+			inform.NetworkConfig{}},
+		{"unknown with addresses", addresses, "", "fe80::1",
+			inform.NetworkConfig{Type6: inform.NetworkConfigSlaac, Ip6: "2001:db8:1::2/64", Gateway6: "fe80::1"}},
+		{"dhcp6", addresses[:1], inform.NetworkConfigDhcpv6, "fe80::1",
+			inform.NetworkConfig{Type6: inform.NetworkConfigDhcpv6, Ip6: "2001:db8:1::2/64", Gateway6: "fe80::1"}},
+		{"static", addresses[:1], inform.NetworkConfigStatic, "2001:db8:1::1",
+			inform.NetworkConfig{Type6: inform.NetworkConfigStatic, Ip6: "2001:db8:1::2/64", Gateway6: "2001:db8:1::1"}},
+		{"6rd", addresses[:1], "6rd", "",
+			inform.NetworkConfig{Type6: "6rd", Ip6: "2001:db8:1::2/64"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var config inform.NetworkConfig
+			applyIpv6Config(&config, inform.Interface{Ipv6: test.addresses}, test.ipv6Type, test.gateway)
+			if !reflect.DeepEqual(config, test.want) {
+				t.Errorf("applyIpv6Config() = %+v, want %+v", config, test.want)
+			}
+		})
+	}
+}
+
+func TestApplyPfSenseIpv6Network(t *testing.T) {
+	translation := PfSenseTranslationTable{Wan: "wan", Lan: "lan", Wan2: "opt2"}
+	tests := []struct {
+		name        string
+		pfInterface pfconf.Interface
+		addresses   []string
+		want        inform.Network
+	}{
+		{"track wan", pfconf.Interface{Ipv6: "track6", Track6If: "wan", Track6PrefixId: "1"}, nil,
+			inform.Network{Ipv6Type: "pd", Ipv6PdIf: RoleWan, Ipv6PdPrefixId: "1"}},
+		{"track second wan", pfconf.Interface{Ipv6: "track6", Track6If: "opt2", Track6PrefixId: "0"}, nil,
+			inform.Network{Ipv6Type: "pd", Ipv6PdIf: RoleWan2, Ipv6PdPrefixId: "0"}},
+		{"track unmapped", pfconf.Interface{Ipv6: "track6", Track6If: "opt5"}, nil,
+			inform.Network{Ipv6Type: "pd", Ipv6PdIf: "opt5"}},
+		{"static from the configuration", pfconf.Interface{Ipv6: "2001:db8:10::1", Subnetv6: 64}, nil,
+			inform.Network{Ipv6Type: "static", Ipv6: []string{"2001:db8:10::1/64"}, Ipv6Prefix: "2001:db8:10::/64"}},
+		{"static from the interface", pfconf.Interface{Ipv6: "2001:db8:10::1", Subnetv6: 64}, []string{"2001:db8:20::1/48"},
+			inform.Network{Ipv6Type: "static", Ipv6: []string{"2001:db8:20::1/48"}}},
+		{"dhcp6", pfconf.Interface{Ipv6: "dhcp6"}, nil, inform.Network{Ipv6Type: "none"}},
+		{"slaac", pfconf.Interface{Ipv6: "slaac"}, nil, inform.Network{Ipv6Type: "none"}},
+		{"disabled", pfconf.Interface{}, nil, inform.Network{Ipv6Type: "none"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			network := inform.Network{Ipv6: test.addresses}
+			applyPfSenseIpv6Network(&network, test.pfInterface, translation)
+			if !reflect.DeepEqual(network, test.want) {
+				t.Errorf("applyPfSenseIpv6Network() = %+v, want %+v", network, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultLeaseFiles lists the DHCP server lease databases searched when no
+// lease file is configured.
+var DefaultLeaseFiles = []string{
+	"/var/dhcpd/var/db/dhcpd.leases",
+	"/var/lib/kea/dhcp4.leases",
+	"/var/lib/dhcp/dhcpd.leases",
+	"/var/lib/kea/kea-leases4.csv",
+	"/var/lib/misc/dnsmasq.leases",
+}
+
+type Lease struct {
+	Ip       net.IP
+	Mac      net.HardwareAddr
+	Hostname string
+	Start    time.Time
+	End      time.Time
+	Active   bool
+}
+
+// ReadLeaseFile parses an ISC dhcpd, Kea CSV or dnsmasq lease file, guessing
+// the format from its content.
+func ReadLeaseFile(path string) ([]Lease, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	reader := bufio.NewReader(f)
+	head, _ := reader.Peek(512)
+	content := string(head)
+	switch {
+	case strings.HasPrefix(content, "address,"):
+		return ParseKeaLeases(reader)
+	case strings.Contains(content, "lease ") && strings.Contains(content, "{"):
+		return ParseDhcpdLeases(reader)
+	default:
+		return ParseDnsmasqLeases(reader)
+	}
+}
+
+// ParseDhcpdLeases parses an ISC dhcpd.leases file. Later entries for the
+// same address replace earlier ones, as dhcpd appends to the file.
+func ParseDhcpdLeases(r io.Reader) ([]Lease, error) {
+	var leases []Lease
+	index := make(map[string]int)
+	var current *Lease
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if current == nil {
+			fields := strings.Fields(line)
+			if len(fields) >= 3 && fields[0] == "lease" && fields[2] == "{" {
+				current = &Lease{Ip: net.ParseIP(fields[1])}
+			}
+			continue
+		}
+		if line == "}" {
+			if current.Ip != nil {
+				key := current.Ip.String()
+				if i, ok := index[key]; ok {
+					leases[i] = *current
+				} else {
+					index[key] = len(leases)
+					leases = append(leases, *current)
+				}
+			}
+			current = nil
+			continue
+		}
+		line = strings.TrimSuffix(line, ";")
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "starts":
+			current.Start = parseDhcpdTime(fields[1:])
+		case "ends":
+			current.End = parseDhcpdTime(fields[1:])
+		case "hardware":
+			if len(fields) >= 3 {
+				current.Mac, _ = net.ParseMAC(fields[2])
+			}
+		case "client-hostname":
+			current.Hostname = strings.Trim(strings.Join(fields[1:], " "), `"`)
+		case "binding":
+			if len(fields) >= 3 && fields[1] == "state" {
+				current.Active = fields[2] == "active"
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return leases, err
+	}
+	return leases, nil
+}
+
+// parseDhcpdTime parses "4 2020/07/09 10:00:00" or "epoch 1594288800".
+func parseDhcpdTime(fields []string) time.Time {
+	if len(fields) == 2 && fields[0] == "epoch" {
+		epoch, err := strconv.ParseInt(fields[1], 10, 64)
+		if err == nil {
+			return time.Unix(epoch, 0)
+		}
+		return time.Time{}
+	}
+	if len(fields) < 3 {
+		return time.Time{}
+	}
+	t, err := time.ParseInLocation("2006/01/02 15:04:05", fields[1]+" "+fields[2], time.UTC)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// ParseKeaLeases parses a Kea memfile lease4 CSV database.
+func ParseKeaLeases(r io.Reader) ([]Lease, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[name] = i
+	}
+	for _, name := range []string{"address", "hwaddr", "valid_lifetime", "expire"} {
+		if _, ok := columns[name]; !ok {
+			return nil, errors.New("missing column " + name + " in Kea lease file")
+		}
+	}
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return record[i]
+	}
+	var leases []Lease
+	index := make(map[string]int)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return leases, err
+		}
+		lease := Lease{
+			Ip:       net.ParseIP(field(record, "address")),
+			Hostname: strings.TrimSuffix(field(record, "hostname"), "."),
+			Active:   field(record, "state") == "" || field(record, "state") == "0",
+		}
+		if lease.Ip == nil {
+			continue
+		}
+		lease.Mac, _ = net.ParseMAC(field(record, "hwaddr"))
+		expire, err := strconv.ParseInt(field(record, "expire"), 10, 64)
+		if err == nil {
+			lease.End = time.Unix(expire, 0)
+			lifetime, err := strconv.ParseInt(field(record, "valid_lifetime"), 10, 64)
+			if err == nil {
+				lease.Start = lease.End.Add(-time.Duration(lifetime) * time.Second)
+			}
+		}
+		key := lease.Ip.String()
+		if i, ok := index[key]; ok {
+			leases[i] = lease
+		} else {
+			index[key] = len(leases)
+			leases = append(leases, lease)
+		}
+	}
+	return leases, nil
+}
+
+// ParseDnsmasqLeases parses a dnsmasq.leases file.
+func ParseDnsmasqLeases(r io.Reader) ([]Lease, error) {
+	var leases []Lease
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		lease := Lease{
+			Ip:     net.ParseIP(fields[2]),
+			Active: true,
+		}
+		if lease.Ip == nil {
+			continue
+		}
+		lease.Mac, _ = net.ParseMAC(fields[1])
+		if fields[3] != "*" {
+			lease.Hostname = fields[3]
+		}
+		expiry, err := strconv.ParseInt(fields[0], 10, 64)
+		if err == nil && expiry > 0 {
+			lease.End = time.Unix(expiry, 0)
+		}
+		leases = append(leases, lease)
+	}
+	return leases, scanner.Err()
+}
+
+// ReadLeases reads all the existing lease files in paths.
+func ReadLeases(paths []string) []Lease {
+	var leases []Lease
+	for _, path := range paths {
+		if !fileExists(path) {
+			continue
+		}
+		fileLeases, err := ReadLeaseFile(path)
+		if err == nil {
+			leases = append(leases, fileLeases...)
+		}
+	}
+	return leases
+}
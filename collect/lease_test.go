@@ -0,0 +1,172 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// leaseString summarizes a lease for comparisons, times as Unix seconds.
+func leaseString(lease Lease) string {
+	unix := func(t time.Time) int64 {
+		if t.IsZero() {
+			return 0
+		}
+		return t.Unix()
+	}
+	return fmt.Sprintf("%s %s %q %d-%d %v", lease.Ip, lease.Mac, lease.Hostname, unix(lease.Start), unix(lease.End), lease.Active)
+}
+
+func leaseStrings(leases []Lease) []string {
+	var result []string
+	for _, lease := range leases {
+		result = append(result, leaseString(lease))
+	}
+	return result
+}
+
+func TestParseLeases(t *testing.T) {
+	tests := []struct {
+		name    string
+		parse   func(io.Reader) ([]Lease, error)
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "dhcpd",
+			parse: ParseDhcpdLeases,
+			input: `# The format of this file is documented in the dhcpd.leases(5) manual page.
+authoring-byte-order little-endian;
+
+lease 192.168.1.100 {
+  starts 4 2020/07/09 10:00:00;
+  ends 4 2020/07/09 12:00:00;
+  binding state active;
+  hardware ethernet 00:11:22:33:44:55;
+  client-hostname "laptop";
+}
+lease 192.168.1.101 {
+  starts epoch 1594288800;
+  ends never;
+  binding state free;
+  hardware ethernet 00:11:22:33:44:66;
+}
+lease 192.168.1.100 {
+  starts 4 2020/07/09 11:00:00;
+  ends 4 2020/07/09 13:00:00;
+  binding state active;
+  hardware ethernet 00:11:22:33:44:55;
+  client-hostname "laptop renamed";
+}
+lease not-an-ip {
+  binding state active;
+}
+server-duid "\000\001\000\001";
+`,
+			want: []string{
+				`192.168.1.100 00:11:22:33:44:55 "laptop renamed" 1594292400-1594299600 true`,
+				`192.168.1.101 00:11:22:33:44:66 "" 1594288800-0 false`,
+			},
+		},
+		{
+			name:  "dhcpd truncated",
+			parse: ParseDhcpdLeases,
+			input: "lease 192.168.1.100 {\n  hardware ethernet zz:11;\n}\nlease 192.168.1.102 {\n  binding state active;\n",
+			want:  []string{`192.168.1.100  "" 0-0 false`},
+		},
+		{
+			name:  "kea",
+			parse: ParseKeaLeases,
+			input: `address,hwaddr,client_id,valid_lifetime,expire,subnet_id,fqdn_fwd,fqdn_rev,hostname,state,user_context
+192.168.1.100,00:11:22:33:44:55,01:00:11:22:33:44:55,3600,1594292400,1,0,0,laptop.home.arpa.,0,
+192.168.1.101,00:11:22:33:44:66,,3600,1594292400,1,0,0,,1,
+not-an-ip,00:11:22:33:44:77,,3600,1594292400,1,0,0,,0,
+192.168.1.100,00:11:22:33:44:55,,7200,1594299600,1,0,0,laptop.home.arpa.,0,
+192.168.1.102,garbage,,soon,later,1
+`,
+			want: []string{
+				`192.168.1.100 00:11:22:33:44:55 "laptop.home.arpa" 1594292400-1594299600 true`,
+				`192.168.1.101 00:11:22:33:44:66 "" 1594288800-1594292400 false`,
+				// Short record: no state column, an active lease
+				`192.168.1.102  "" 0-0 true`,
+			},
+		},
+		{
+			name:    "kea missing column",
+			parse:   ParseKeaLeases,
+			input:   "address,hwaddr,hostname\n192.168.1.100,00:11:22:33:44:55,laptop\n",
+			wantErr: true,
+		},
+		{
+			name:    "kea empty",
+			parse:   ParseKeaLeases,
+			wantErr: true,
+		},
+		{
+			name:  "dnsmasq",
+			parse: ParseDnsmasqLeases,
+			input: `1594292400 00:11:22:33:44:55 192.168.1.100 laptop 01:00:11:22:33:44:55
+0 00:11:22:33:44:66 192.168.1.101 * *
+1594292400 00:11:22:33:44:77 not-an-ip phone *
+1594292400 00:11:22:33:44:88
+duid 00:01:00:01:26:7f:ab:cd:00:11:22:33:44:55
+`,
+			want: []string{
+				`192.168.1.100 00:11:22:33:44:55 "laptop" 0-1594292400 true`,
+				`192.168.1.101 00:11:22:33:44:66 "" 0-0 true`,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			leases, err := test.parse(strings.NewReader(test.input))
+			if (err != nil) != test.wantErr {
+				t.Fatalf("error = %v, want error %v", err, test.wantErr)
+			}
+			if got := leaseStrings(leases); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("leases =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(test.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestReadLeases(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"dhcpd.leases":    "lease 192.168.1.100 {\n  binding state active;\n  hardware ethernet 00:11:22:33:44:55;\n}\n",
+		"kea-leases4.csv": "address,hwaddr,client_id,valid_lifetime,expire,subnet_id,fqdn_fwd,fqdn_rev,hostname,state\n192.168.2.100,00:11:22:33:44:66,,3600,1594292400,1,0,0,,0\n",
+		"dnsmasq.leases":  "1594292400 00:11:22:33:44:77 192.168.3.100 phone *\n",
+	}
+	var paths []string
+	for _, name := range []string{"dhcpd.leases", "kea-leases4.csv", "dnsmasq.leases", "missing.leases"} {
+		path := filepath.Join(dir, name)
+		if content, ok := files[name]; ok {
+			if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		paths = append(paths, path)
+	}
+	want := []string{
+		`192.168.1.100 00:11:22:33:44:55 "" 0-0 true`,
+		`192.168.2.100 00:11:22:33:44:66 "" 1594288800-1594292400 true`,
+		`192.168.3.100 00:11:22:33:44:77 "phone" 0-1594292400 true`,
+	}
+	if got := leaseStrings(ReadLeases(paths)); !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadLeases() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
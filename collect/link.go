@@ -0,0 +1,184 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"github.com/COSAE-FR/ripugw/inform"
+	"sync"
+	"time"
+)
+
+const (
+	defaultLinkHistory  = 20
+	defaultLinkInterval = 5 * time.Second
+)
+
+type LinkTransition struct {
+	Interface string    `json:"interface"`
+	Up        bool      `json:"up"`
+	At        time.Time `json:"at"`
+}
+
+type LinkState struct {
+	Up      bool             `json:"up"`
+	Since   time.Time        `json:"since"`
+	Flaps   uint64           `json:"flaps"`
+	History []LinkTransition `json:"history"`
+}
+
+// LinkTracker follows the link state of the interfaces, from system events
+// when available and from periodic sampling otherwise.
+type LinkTracker struct {
+	HistorySize int
+	Interval    time.Duration
+	OnChange    func(LinkTransition)
+
+	lock   sync.Mutex
+	states map[string]*LinkState
+	stop   chan bool
+}
+
+func NewLinkTracker(historySize int, interval time.Duration) *LinkTracker {
+	if historySize <= 0 {
+		historySize = defaultLinkHistory
+	}
+	if interval <= 0 {
+		interval = defaultLinkInterval
+	}
+	return &LinkTracker{
+		HistorySize: historySize,
+		Interval:    interval,
+		states:      make(map[string]*LinkState),
+	}
+}
+
+// Observe records the link state of an interface. A change of state is a
+// transition; going down counts as a flap.
+func (t *LinkTracker) Observe(name string, up bool, at time.Time) {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	state, ok := t.states[name]
+	if !ok {
+		t.states[name] = &LinkState{Up: up, Since: at}
+		t.lock.Unlock()
+		return
+	}
+	if state.Up == up {
+		t.lock.Unlock()
+		return
+	}
+	transition := LinkTransition{Interface: name, Up: up, At: at}
+	state.Up = up
+	state.Since = at
+	if !up {
+		state.Flaps++
+	}
+	state.History = append(state.History, transition)
+	if len(state.History) > t.HistorySize {
+		state.History = state.History[len(state.History)-t.HistorySize:]
+	}
+	onChange := t.OnChange
+	t.lock.Unlock()
+	if onChange != nil {
+		onChange(transition)
+	}
+}
+
+// Apply sets the uptime of iface, keyed by its physical name, from the
+// last time its link went up. The interface state is only used for links
+// not seen by the sampler or the system events yet.
+func (t *LinkTracker) Apply(iface *inform.Interface, now time.Time) {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	state, ok := t.states[iface.Name]
+	if !ok {
+		state = &LinkState{Up: iface.Up, Since: now}
+		t.states[iface.Name] = state
+	}
+	iface.Uptime = 0
+	if state.Up && now.After(state.Since) {
+		iface.Uptime = uint64(now.Sub(state.Since).Seconds())
+	}
+}
+
+func (t *LinkTracker) ApplyAll(ifaces []inform.Interface) {
+	now := time.Now()
+	for i := range ifaces {
+		t.Apply(&ifaces[i], now)
+	}
+}
+
+// States returns a copy of the tracked states by interface name.
+func (t *LinkTracker) States() map[string]LinkState {
+	states := make(map[string]LinkState)
+	if t == nil {
+		return states
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for name, state := range t.states {
+		copied := *state
+		copied.History = append([]LinkTransition{}, state.History...)
+		states[name] = copied
+	}
+	return states
+}
+
+// Start samples the link states periodically. System link events are fed
+// by a NetWatcher where supported.
+func (t *LinkTracker) Start() {
+	t.stop = make(chan bool)
+	go func() {
+		ticker := time.NewTicker(t.Interval)
+		defer ticker.Stop()
+		t.sample()
+		for {
+			select {
+			case <-t.stop:
+				return
+			case <-ticker.C:
+				t.sample()
+			}
+		}
+	}()
+}
+
+func (t *LinkTracker) Stop() {
+	if t != nil && t.stop != nil {
+		close(t.stop)
+	}
+}
+
+func (t *LinkTracker) sample() {
+	states, err := getLinkStates()
+	if err != nil {
+		return
+	}
+	t.observeAll(states, time.Now())
+}
+
+// observeAll records the link states of all the system interfaces and
+// forgets the interfaces that disappeared.
+func (t *LinkTracker) observeAll(states map[string]bool, at time.Time) {
+	for name, up := range states {
+		t.Observe(name, up, at)
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for name := range t.states {
+		if _, ok := states[name]; !ok {
+			delete(t.states, name)
+		}
+	}
+}
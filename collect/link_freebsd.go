@@ -0,0 +1,25 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+// getLinkStates reads the media status of the interfaces from ifconfig.
+func getLinkStates() (map[string]bool, error) {
+	interfaces, err := ifconfig("-a")
+	if err != nil {
+		return nil, err
+	}
+	states := make(map[string]bool)
+	for _, iface := range interfaces {
+		if iface.HasFlag("LOOPBACK") {
+			continue
+		}
+		states[iface.Name] = iface.Up()
+	}
+	return states, nil
+}
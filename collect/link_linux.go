@@ -0,0 +1,39 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"io/ioutil"
+	"net"
+	"path"
+	"strings"
+)
+
+// getLinkStates reads the operational state of the interfaces from sysfs.
+func getLinkStates() (map[string]bool, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	states := make(map[string]bool)
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		up := iface.Flags&net.FlagUp != 0
+		if up {
+			carrier, err := ioutil.ReadFile(path.Join(SysfsRoot, "class/net", iface.Name, "carrier"))
+			if err == nil {
+				up = strings.TrimSpace(string(carrier)) == "1"
+			}
+		}
+		states[iface.Name] = up
+	}
+	return states, nil
+}
@@ -0,0 +1,100 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"testing"
+	"time"
+
+	"github.com/COSAE-FR/ripugw/inform"
+)
+
+func TestLinkTrackerTransitions(t *testing.T) {
+	tracker := NewLinkTracker(2, 0)
+	var transitions []LinkTransition
+	tracker.OnChange = func(transition LinkTransition) {
+		transitions = append(transitions, transition)
+	}
+	start := time.Unix(1594288800, 0)
+	tracker.Observe("eth0", true, start)
+	tracker.Observe("eth0", true, start.Add(time.Second))
+	tracker.Observe("eth0", false, start.Add(2*time.Second))
+	tracker.Observe("eth0", true, start.Add(3*time.Second))
+	tracker.Observe("eth0", false, start.Add(4*time.Second))
+	tracker.Observe("eth0", true, start.Add(5*time.Second))
+
+	if len(transitions) != 4 {
+		t.Fatalf("%d transitions, want 4", len(transitions))
+	}
+	state := tracker.States()["eth0"]
+	if !state.Up || state.Flaps != 2 || !state.Since.Equal(start.Add(5*time.Second)) {
+		t.Errorf("state = %+v, want up since the last transition after 2 flaps", state)
+	}
+	if len(state.History) != 2 || state.History[0].Up || !state.History[1].Up {
+		t.Errorf("history = %+v, want the last down and up transitions", state.History)
+	}
+}
+
+func TestLinkTrackerApply(t *testing.T) {
+	tracker := NewLinkTracker(0, 0)
+	start := time.Unix(1594288800, 0)
+	tracker.Observe("eth0", true, start)
+	tracker.Observe("eth1", false, start)
+
+	tests := []struct {
+		iface  inform.Interface
+		uptime uint64
+	}{
+		{inform.Interface{Name: "eth0", Up: true}, 90},
+		{inform.Interface{Name: "eth1", Up: true}, 0},
+		// Not seen yet: up from now on
+		{inform.Interface{Name: "eth2", Up: true}, 0},
+	}
+	for _, test := range tests {
+		iface := test.iface
+		tracker.Apply(&iface, start.Add(90*time.Second))
+		if iface.Uptime != test.uptime {
+			t.Errorf("%s uptime = %d, want %d", iface.Name, iface.Uptime, test.uptime)
+		}
+	}
+	iface := inform.Interface{Name: "eth2", Up: true}
+	tracker.Apply(&iface, start.Add(100*time.Second))
+	if iface.Uptime != 10 {
+		t.Errorf("eth2 uptime = %d, want 10", iface.Uptime)
+	}
+
+	var nilTracker *LinkTracker
+	nilTracker.Observe("eth0", false, start)
+	nilTracker.Apply(&iface, start)
+	if states := nilTracker.States(); len(states) != 0 {
+		t.Errorf("nil tracker states = %+v", states)
+	}
+}
+
+func TestLinkTrackerForgetsVanishedInterfaces(t *testing.T) {
+	tracker := NewLinkTracker(0, 0)
+	start := time.Unix(1600000000, 0)
+	tracker.observeAll(map[string]bool{"eth0": true, "ppp0": true}, start)
+	tracker.observeAll(map[string]bool{"eth0": true, "ppp0": false}, start.Add(time.Minute))
+	if states := tracker.States(); states["ppp0"].Flaps != 1 {
+		t.Fatalf("ppp0 flaps = %d, want 1", states["ppp0"].Flaps)
+	}
+	tracker.observeAll(map[string]bool{"eth0": true}, start.Add(2*time.Minute))
+	states := tracker.States()
+	if _, ok := states["ppp0"]; ok {
+		t.Error("state of a vanished interface kept")
+	}
+	if _, ok := states["eth0"]; !ok {
+		t.Error("state of a present interface forgotten")
+	}
+	tracker.observeAll(map[string]bool{"eth0": true, "ppp0": true}, start.Add(3*time.Minute))
+	if recreated := tracker.States()["ppp0"]; recreated.Flaps != 0 || !recreated.Since.Equal(start.Add(3*time.Minute)) {
+		t.Errorf("recreated interface state = %+v", recreated)
+	}
+}
@@ -8,9 +8,26 @@
 
 package collect
 
+// Media reported when the link speed or duplex cannot be read: without
+// them, the controller shows the port as disconnected.
+const (
+	defaultMediaSpeed      = 1000
+	defaultMediaFullDuplex = true
+)
+
 type InterfaceMedia struct {
 	Speed      uint64
 	FullDuplex bool
+	// Virtual is set for interfaces without physical device
+	Virtual bool
+	Mtu     int
+}
+
+func defaultMedia() InterfaceMedia {
+	return InterfaceMedia{
+		Speed:      defaultMediaSpeed,
+		FullDuplex: defaultMediaFullDuplex,
+	}
 }
 
 func GetInterfaceMedia(iface string) (InterfaceMedia, error) {
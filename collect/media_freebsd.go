@@ -11,36 +11,29 @@ package collect
 import (
 	"bytes"
 	"fmt"
-	"log"
 	"os/exec"
-	"regexp"
-	"strconv"
 )
 
-func getInterfaceMediaForOs(iface string) (InterfaceMedia, error) {
-	re := regexp.MustCompile(`\tmedia:\s.+\s\((?P<speed>\d+)base.{2,}\s<(?P<duplex>[a-z]+)-duplex>\)\n`)
-
-	media := InterfaceMedia{
-		Speed:      1000,
-		FullDuplex: true,
-	}
-	cmd := exec.Command("ifconfig", iface)
+// ifconfig runs ifconfig with args and parses its output.
+func ifconfig(args ...string) ([]IfconfigInterface, error) {
+	cmd := exec.Command("ifconfig", args...)
 	var out bytes.Buffer
 	cmd.Stdout = &out
-	err := cmd.Run()
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+	return ParseIfconfig(&out)
+}
+
+func getInterfaceMediaForOs(iface string) (InterfaceMedia, error) {
+	interfaces, err := ifconfig(iface)
 	if err != nil {
-		log.Fatal(err)
+		return defaultMedia(), err
 	}
-	match := re.FindSubmatch(out.Bytes())
-	if len(match) == 3 {
-		speed, err := strconv.Atoi(fmt.Sprintf("%s", match[1]))
-		if err == nil {
-			media.Speed = uint64(speed)
-		}
-		if fmt.Sprintf("%s", match[2]) == "half" {
-			media.FullDuplex = false
+	for _, parsed := range interfaces {
+		if parsed.Name == iface {
+			return parsed.InterfaceMedia(), nil
 		}
 	}
-
-	return media, nil
+	return defaultMedia(), fmt.Errorf("interface %s not found", iface)
 }
@@ -9,50 +9,138 @@
 package collect
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
 	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
 )
 
-func getInterfaceMediaForOs(iface string) (InterfaceMedia, error) {
-	base := path.Join("/sys/class/net", iface)
-	speedFile := path.Join(base, "speed")
-	duplexFile := path.Join(base, "duplex")
-	media := InterfaceMedia{
-		Speed:      1000,
-		FullDuplex: true,
+// SysfsRoot is where sysfs is mounted, changed to read fixture trees.
+var SysfsRoot = "/sys"
+
+// ethtool ioctl, from linux/sockios.h and linux/ethtool.h
+const (
+	siocEthtool    = 0x8946
+	ethtoolGset    = 0x1
+	ethtoolUnknown = 0xff
+	speedUnknown   = 0xffffffff
+)
+
+type ethtoolCmd struct {
+	Cmd           uint32
+	Supported     uint32
+	Advertising   uint32
+	Speed         uint16
+	Duplex        uint8
+	Port          uint8
+	PhyAddress    uint8
+	Transceiver   uint8
+	Autoneg       uint8
+	MdioSupport   uint8
+	Maxtxpkt      uint32
+	Maxrxpkt      uint32
+	SpeedHi       uint16
+	EthTpMdix     uint8
+	EthTpMdixCtrl uint8
+	LpAdvertising uint32
+	Reserved      [2]uint32
+}
+
+// ifreqData is a struct ifreq holding a pointer in its union, kept as an
+// unsafe.Pointer so the garbage collector tracks the ethtool command.
+type ifreqData struct {
+	Name [syscall.IFNAMSIZ]byte
+	Data unsafe.Pointer
+	_    [16]byte
+}
+
+// ethtoolMedia asks the driver for the link settings.
+func ethtoolMedia(iface string) (InterfaceMedia, error) {
+	media := defaultMedia()
+	if len(iface) >= syscall.IFNAMSIZ {
+		return media, fmt.Errorf("invalid interface name %s", iface)
 	}
-	if _, err := os.Stat(base); err != nil {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
 		return media, err
 	}
-	if _, err := os.Stat(speedFile); err != nil {
-		return media, err
+	defer syscall.Close(fd)
+	cmd := ethtoolCmd{Cmd: ethtoolGset}
+	request := ifreqData{Data: unsafe.Pointer(&cmd)}
+	copy(request.Name[:], iface)
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), siocEthtool, uintptr(unsafe.Pointer(&request)))
+	if errno != 0 {
+		return media, errno
+	}
+	speed := uint32(cmd.SpeedHi)<<16 | uint32(cmd.Speed)
+	if speed == 0 || speed == speedUnknown || cmd.Duplex == ethtoolUnknown {
+		return media, fmt.Errorf("unknown media for %s", iface)
 	}
-	speedHandle, err := os.Open(speedFile)
+	media.Speed = uint64(speed)
+	media.FullDuplex = cmd.Duplex == 1
+	return media, nil
+}
+
+func readSysfsValue(file string) (string, error) {
+	value, err := ioutil.ReadFile(file)
 	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(value)), nil
+}
+
+// ReadSysfsMedia reads the link settings of an interface in the sysfs
+// tree mounted at root. Unknown values keep the default media.
+func ReadSysfsMedia(root string, iface string) (InterfaceMedia, error) {
+	media := defaultMedia()
+	base := path.Join(root, "class/net", iface)
+	if _, err := os.Stat(base); err != nil {
 		return media, err
 	}
-	speedString, err := ioutil.ReadAll(speedHandle)
+	if _, err := os.Stat(path.Join(base, "device")); err != nil {
+		media.Virtual = true
+	}
+	speedString, err := readSysfsValue(path.Join(base, "speed"))
 	if err != nil {
+		// Virtual interfaces do not always expose their speed
+		if media.Virtual {
+			return media, nil
+		}
 		return media, err
 	}
-	speed, err := strconv.Atoi(string(speedString))
+	speed, err := strconv.ParseInt(speedString, 10, 64)
 	if err != nil {
-		return media, err
+		return media, fmt.Errorf("invalid speed %q for %s", speedString, iface)
 	}
-	media.Speed = uint64(speed)
-	if _, err := os.Stat(duplexFile); err != nil {
-		return media, err
+	if speed <= 0 || uint64(speed) >= speedUnknown {
+		// -1: unknown, usually no carrier or virtual device
+		return media, nil
 	}
-	duplexHandle, err := os.Open(duplexFile)
+	media.Speed = uint64(speed)
+	duplex, err := readSysfsValue(path.Join(base, "duplex"))
 	if err != nil {
 		return media, err
 	}
-	duplex, err := ioutil.ReadAll(duplexHandle)
-	if err != nil {
-		return media, err
+	switch duplex {
+	case "full":
+		media.FullDuplex = true
+	case "half":
+		media.FullDuplex = false
+	}
+	return media, nil
+}
+
+func getInterfaceMediaForOs(iface string) (InterfaceMedia, error) {
+	media, err := ethtoolMedia(iface)
+	if err == nil {
+		if _, statErr := os.Stat(path.Join(SysfsRoot, "class/net", iface, "device")); statErr != nil {
+			media.Virtual = true
+		}
+		return media, nil
 	}
-	media.FullDuplex = string(duplex) == "full"
-	return media, err
+	return ReadSysfsMedia(SysfsRoot, iface)
 }
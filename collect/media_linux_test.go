@@ -0,0 +1,51 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestReadSysfsMedia(t *testing.T) {
+	root := filepath.Join("testdata", "sysfs")
+	tests := []struct {
+		iface   string
+		want    InterfaceMedia
+		wantErr bool
+	}{
+		{"eth0", InterfaceMedia{Speed: 1000, FullDuplex: true}, false},
+		{"eth1", InterfaceMedia{Speed: 100, FullDuplex: false}, false},
+		// No carrier: speed -1
+		{"eth2", InterfaceMedia{Speed: 1000, FullDuplex: true}, false},
+		{"eth3", InterfaceMedia{Speed: 1000, FullDuplex: true}, true},
+		{"br0", InterfaceMedia{Speed: 10000, FullDuplex: true, Virtual: true}, false},
+		{"wg0", InterfaceMedia{Speed: 1000, FullDuplex: true, Virtual: true}, false},
+		{"eth9", InterfaceMedia{Speed: 1000, FullDuplex: true}, true},
+	}
+	for _, test := range tests {
+		media, err := ReadSysfsMedia(root, test.iface)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: error = %v, want error %v", test.iface, err, test.wantErr)
+		}
+		if media != test.want {
+			t.Errorf("%s: media = %+v, want %+v", test.iface, media, test.want)
+		}
+	}
+}
+
+func TestEthtoolMedia(t *testing.T) {
+	if _, err := ethtoolMedia("an-interface-name-too-long"); err == nil {
+		t.Error("invalid interface name accepted")
+	}
+	// The loopback has no link settings: the ioctl runs and fails cleanly
+	if media, err := ethtoolMedia("lo"); err == nil && media.Speed == 0 {
+		t.Errorf("lo media = %+v", media)
+	}
+}
@@ -0,0 +1,45 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import "fmt"
+
+// DeviceModel is a UniFi gateway model the device announces itself as.
+type DeviceModel struct {
+	Model   string
+	Display string
+	Ports   int
+}
+
+// DeviceModels are sorted by number of ports.
+var DeviceModels = []DeviceModel{
+	{Model: "UGW3", Display: "UniFi-Gateway-3", Ports: 3},
+	{Model: "UGW4", Display: "UniFi-Gateway-4", Ports: 4},
+	{Model: "UGWXG", Display: "UniFi Security Gateway XG-8", Ports: 8},
+}
+
+// SelectDeviceModel returns the named model, or the smallest one with
+// enough ports.
+func SelectDeviceModel(name string, ports int) (DeviceModel, error) {
+	for _, model := range DeviceModels {
+		if len(name) > 0 && model.Model != name {
+			continue
+		}
+		if model.Ports >= ports {
+			return model, nil
+		}
+		if len(name) > 0 {
+			return model, fmt.Errorf("model %s has %d ports, %d mapped", name, model.Ports, ports)
+		}
+	}
+	if len(name) > 0 {
+		return DeviceModels[0], fmt.Errorf("unknown model %s", name)
+	}
+	return DeviceModels[len(DeviceModels)-1], fmt.Errorf("no model with %d ports", ports)
+}
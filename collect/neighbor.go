@@ -0,0 +1,148 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strings"
+)
+
+type Neighbor struct {
+	Ip        net.IP
+	Mac       net.HardwareAddr
+	Interface string
+}
+
+// Neighbors returns the IPv4 ARP and IPv6 NDP entries of the system.
+func Neighbors() ([]Neighbor, error) {
+	return getNeighbors()
+}
+
+// ParseProcArp parses the content of /proc/net/arp.
+func ParseProcArp(r io.Reader) ([]Neighbor, error) {
+	var neighbors []Neighbor
+	scanner := bufio.NewScanner(r)
+	header := true
+	for scanner.Scan() {
+		if header {
+			header = false
+			continue
+		}
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 {
+			continue
+		}
+		// Flags 0x0 is an incomplete entry
+		if fields[2] == "0x0" {
+			continue
+		}
+		neighbor, ok := newNeighbor(fields[0], fields[3], fields[5])
+		if ok {
+			neighbors = append(neighbors, neighbor)
+		}
+	}
+	return neighbors, scanner.Err()
+}
+
+// ParseIpNeigh parses the output of "ip neigh show".
+func ParseIpNeigh(r io.Reader) ([]Neighbor, error) {
+	var neighbors []Neighbor
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		var device, mac string
+		for i := 1; i < len(fields)-1; i++ {
+			switch fields[i] {
+			case "dev":
+				device = fields[i+1]
+			case "lladdr":
+				mac = fields[i+1]
+			}
+		}
+		switch fields[len(fields)-1] {
+		case "FAILED", "INCOMPLETE":
+			continue
+		}
+		neighbor, ok := newNeighbor(fields[0], mac, device)
+		if ok {
+			neighbors = append(neighbors, neighbor)
+		}
+	}
+	return neighbors, scanner.Err()
+}
+
+// ParseArpAn parses the output of FreeBSD "arp -an".
+func ParseArpAn(r io.Reader) ([]Neighbor, error) {
+	var neighbors []Neighbor
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		// ? (192.168.1.10) at 00:11:22:33:44:55 on em1 expires in 1187 seconds [ethernet]
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 || fields[2] != "at" || fields[4] != "on" {
+			continue
+		}
+		neighbor, ok := newNeighbor(strings.Trim(fields[1], "()"), fields[3], fields[5])
+		if ok {
+			neighbors = append(neighbors, neighbor)
+		}
+	}
+	return neighbors, scanner.Err()
+}
+
+// ParseNdpAn parses the output of FreeBSD "ndp -an".
+func ParseNdpAn(r io.Reader) ([]Neighbor, error) {
+	var neighbors []Neighbor
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		// fe80::1%em0  00:11:22:33:44:55  em0 23h59m58s  S R
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || fields[0] == "Neighbor" {
+			continue
+		}
+		address := fields[0]
+		if i := strings.IndexByte(address, '%'); i >= 0 {
+			address = address[:i]
+		}
+		neighbor, ok := newNeighbor(address, fields[1], fields[2])
+		if ok {
+			neighbors = append(neighbors, neighbor)
+		}
+	}
+	return neighbors, scanner.Err()
+}
+
+func newNeighbor(ip string, mac string, device string) (Neighbor, bool) {
+	neighbor := Neighbor{
+		Ip:        net.ParseIP(ip),
+		Interface: device,
+	}
+	if neighbor.Ip == nil {
+		return neighbor, false
+	}
+	hw, err := net.ParseMAC(mac)
+	if err != nil || isZeroMac(hw) {
+		return neighbor, false
+	}
+	neighbor.Mac = hw
+	return neighbor, true
+}
+
+func isZeroMac(mac net.HardwareAddr) bool {
+	for _, b := range mac {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
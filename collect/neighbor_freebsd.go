@@ -0,0 +1,35 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"bytes"
+	"os/exec"
+)
+
+func getNeighbors() ([]Neighbor, error) {
+	cmd := exec.Command("arp", "-an")
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+	neighbors, err := ParseArpAn(&out)
+	if err != nil {
+		return neighbors, err
+	}
+	cmd = exec.Command("ndp", "-an")
+	out.Reset()
+	cmd.Stdout = &out
+	if err := cmd.Run(); err == nil {
+		ndp, _ := ParseNdpAn(&out)
+		neighbors = append(neighbors, ndp...)
+	}
+	return neighbors, nil
+}
@@ -0,0 +1,35 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+)
+
+func getNeighbors() ([]Neighbor, error) {
+	f, err := os.Open("/proc/net/arp")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	neighbors, err := ParseProcArp(f)
+	if err != nil {
+		return neighbors, err
+	}
+	cmd := exec.Command("ip", "-6", "neigh", "show")
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err == nil {
+		ndp, _ := ParseIpNeigh(&out)
+		neighbors = append(neighbors, ndp...)
+	}
+	return neighbors, nil
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseNeighbors(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func(io.Reader) ([]Neighbor, error)
+		input string
+		want  []string
+	}{
+		{
+			name:  "proc arp",
+			parse: ParseProcArp,
+			input: `IP address       HW type     Flags       HW address            Mask     Device
+192.168.1.10     0x1         0x2         00:11:22:33:44:55     *        eth1
+192.168.1.11     0x1         0x0         00:00:00:00:00:00     *        eth1
+192.168.1.12     0x1         0x2         00:00:00:00:00:00     *        eth1
+192.168.1.13     0x1         0x2         not-a-mac             *        eth1
+192.168.1.14     0x1         0x2
+10.0.0.2         0x1         0x6         00:11:22:33:44:66     *        eth2
+`,
+			want: []string{"192.168.1.10 00:11:22:33:44:55 eth1", "10.0.0.2 00:11:22:33:44:66 eth2"},
+		},
+		{
+			name:  "ip neigh",
+			parse: ParseIpNeigh,
+			input: `192.168.1.10 dev eth1 lladdr 00:11:22:33:44:55 REACHABLE
+192.168.1.11 dev eth1  FAILED
+192.168.1.12 dev eth1 lladdr 00:11:22:33:44:77 INCOMPLETE
+192.168.1.13 dev eth1 lladdr 00:11:22:33:44:88 router STALE
+fe80::211:22ff:fe33:4499 dev eth1 lladdr 00:11:22:33:44:99 router REACHABLE
+2001:db8::10 dev eth1 lladdr 00:11:22:33:44:aa DELAY
+garbage
+`,
+			want: []string{
+				"192.168.1.10 00:11:22:33:44:55 eth1",
+				"192.168.1.13 00:11:22:33:44:88 eth1",
+				"fe80::211:22ff:fe33:4499 00:11:22:33:44:99 eth1",
+				"2001:db8::10 00:11:22:33:44:aa eth1",
+			},
+		},
+		{
+			name:  "arp -an",
+			parse: ParseArpAn,
+			input: `? (192.168.1.10) at 00:11:22:33:44:55 on em1 expires in 1187 seconds [ethernet]
+? (192.168.1.1) at 00:0d:b9:4a:11:21 on em1 permanent [ethernet]
+? (192.168.1.11) at (incomplete) on em1 expired [ethernet]
+? (10.0.0.2) at 00:11:22:33:44:66 on lagg0 expires in 20 seconds [ethernet]
+arp: 192.168.1.12: no entry
+`,
+			want: []string{
+				"192.168.1.10 00:11:22:33:44:55 em1",
+				"192.168.1.1 00:0d:b9:4a:11:21 em1",
+				"10.0.0.2 00:11:22:33:44:66 lagg0",
+			},
+		},
+		{
+			name:  "ndp -an",
+			parse: ParseNdpAn,
+			input: `Neighbor                             Linklayer Address  Netif Expire    S Flags
+fe80::211:22ff:fe33:4455%em1         00:11:22:33:44:55    em1 23h59m58s S R
+2001:db8::10                         00:11:22:33:44:aa    em1 permanent R
+fe80::1%lo0                          (incomplete)         lo0 permanent R
+2001:db8::11                         00:00:00:00:00:00    em1 expired   I
+`,
+			want: []string{
+				"fe80::211:22ff:fe33:4455 00:11:22:33:44:55 em1",
+				"2001:db8::10 00:11:22:33:44:aa em1",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			neighbors, err := test.parse(strings.NewReader(test.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, neighbor := range neighbors {
+				got = append(got, neighbor.Ip.String()+" "+neighbor.Mac.String()+" "+neighbor.Interface)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("neighbors =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(test.want, "\n"))
+			}
+		})
+	}
+}
@@ -8,8 +8,69 @@
 
 package collect
 
-import "net"
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
 
+// The default gateways are cached between informs while a NetWatcher
+// invalidates them on route changes. Without one, as on FreeBSD, they are
+// read every time.
+const gatewayCacheTtl = time.Minute
+
+// cacheWatchers counts the running NetWatchers.
+var cacheWatchers int32
+
+type gatewayCache struct {
+	lock    sync.Mutex
+	fetch   func() (net.IP, error)
+	ip      net.IP
+	expires time.Time
+}
+
+func (c *gatewayCache) get() (net.IP, error) {
+	if atomic.LoadInt32(&cacheWatchers) == 0 {
+		return c.fetch()
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.ip != nil && time.Now().Before(c.expires) {
+		return c.ip, nil
+	}
+	ip, err := c.fetch()
+	if err != nil {
+		return nil, err
+	}
+	c.ip = ip
+	c.expires = time.Now().Add(gatewayCacheTtl)
+	return ip, nil
+}
+
+func (c *gatewayCache) invalidate() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.ip = nil
+}
+
+var (
+	gateway4Cache = &gatewayCache{fetch: getGateway}
+	gateway6Cache = &gatewayCache{fetch: getGateway6}
+)
+
+// Gateway returns the default IPv4 gateway.
 func Gateway() (net.IP, error) {
-	return getGateway()
+	return gateway4Cache.get()
+}
+
+// Gateway6 returns the default IPv6 gateway.
+func Gateway6() (net.IP, error) {
+	return gateway6Cache.get()
+}
+
+// InvalidateCache drops the cached network data.
+func InvalidateCache() {
+	gateway4Cache.invalidate()
+	gateway6Cache.invalidate()
 }
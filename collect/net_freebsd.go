@@ -10,45 +10,16 @@ package collect
 
 import (
 	"fmt"
-	"golang.org/x/net/route"
 	"net"
 )
 
-var defaultRoute = [4]byte{0, 0, 0, 0}
-
 func getGateway() (net.IP, error) {
-	rib, err := route.FetchRIB(0, route.RIBTypeRoute, 0)
-	if err != nil {
-		return nil, err
-	}
-
-	messages, err := route.ParseRIB(route.RIBTypeRoute, rib)
+	routes, err := getRoutes()
 	if err != nil {
 		return nil, err
 	}
-
-	for _, message := range messages {
-		routeMessage := message.(*route.RouteMessage)
-		addresses := routeMessage.Addrs
-
-		var destination, gateway *route.Inet4Addr
-		ok := false
-
-		if destination, ok = addresses[0].(*route.Inet4Addr); !ok {
-			continue
-		}
-
-		if gateway, ok = addresses[1].(*route.Inet4Addr); !ok {
-			continue
-		}
-
-		if destination == nil || gateway == nil {
-			continue
-		}
-
-		if destination.IP == defaultRoute {
-			return net.IPv4(gateway.IP[0], gateway.IP[1], gateway.IP[2], gateway.IP[3]), nil
-		}
+	if defaults := DefaultRoutes(routes, false); len(defaults) > 0 {
+		return defaults[0].Gateway, nil
 	}
 	return nil, fmt.Errorf("Cannot find gateway")
 }
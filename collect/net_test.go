@@ -0,0 +1,50 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+)
+
+func TestGatewayCache(t *testing.T) {
+	fetches := 0
+	cache := &gatewayCache{fetch: func() (net.IP, error) {
+		fetches++
+		return net.IPv4(192, 0, 2, 1), nil
+	}}
+	get := func() {
+		t.Helper()
+		if ip, err := cache.get(); err != nil || !ip.Equal(net.IPv4(192, 0, 2, 1)) {
+			t.Fatalf("get() = %v, %v", ip, err)
+		}
+	}
+
+	// Nothing invalidates the cache: every call reads the system
+	get()
+	get()
+	if fetches != 2 {
+		t.Errorf("fetches without watcher = %d, want 2", fetches)
+	}
+
+	atomic.AddInt32(&cacheWatchers, 1)
+	defer atomic.AddInt32(&cacheWatchers, -1)
+	fetches = 0
+	get()
+	get()
+	if fetches != 1 {
+		t.Errorf("fetches with watcher = %d, want 1", fetches)
+	}
+	cache.invalidate()
+	get()
+	if fetches != 2 {
+		t.Errorf("fetches after invalidation = %d, want 2", fetches)
+	}
+}
@@ -0,0 +1,16 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import "errors"
+
+// NewSystemEventSource is not implemented: changes are noticed by sampling.
+func NewSystemEventSource() (EventSource, error) {
+	return nil, errors.New("network events not supported")
+}
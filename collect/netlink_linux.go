@@ -0,0 +1,211 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Netlink multicast groups, not exported by syscall
+const (
+	rtmgrpLink       = 0x1
+	rtmgrpIpv4Ifaddr = 0x10
+	rtmgrpIpv4Route  = 0x40
+	rtmgrpIpv6Ifaddr = 0x100
+	rtmgrpIpv6Route  = 0x400
+
+	rtTableMain = 254
+)
+
+// Closing a socket does not interrupt a blocked read: the reader wakes up
+// at this interval to notice the source is closed.
+const netlinkReadTimeout = 500 * time.Millisecond
+
+// nativeEndian is the byte order of the netlink attributes.
+var nativeEndian binary.ByteOrder = func() binary.ByteOrder {
+	i := uint16(1)
+	if *(*byte)(unsafe.Pointer(&i)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}()
+
+type netlinkSource struct {
+	fd      int
+	events  chan NetEvent
+	closing chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+// NewSystemEventSource subscribes to the link, address and route changes.
+func NewSystemEventSource() (EventSource, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_ROUTE)
+	if err != nil {
+		return nil, err
+	}
+	groups := uint32(rtmgrpLink | rtmgrpIpv4Ifaddr | rtmgrpIpv4Route | rtmgrpIpv6Ifaddr | rtmgrpIpv6Route)
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: groups}); err != nil {
+		_ = syscall.Close(fd)
+		return nil, err
+	}
+	timeout := syscall.NsecToTimeval(netlinkReadTimeout.Nanoseconds())
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &timeout); err != nil {
+		_ = syscall.Close(fd)
+		return nil, err
+	}
+	source := &netlinkSource{
+		fd:      fd,
+		events:  make(chan NetEvent, 64),
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go source.read()
+	return source, nil
+}
+
+func (s *netlinkSource) Events() <-chan NetEvent {
+	return s.events
+}
+
+// Close stops the reader, then releases the socket so its descriptor cannot
+// be reused while a read is pending.
+func (s *netlinkSource) Close() error {
+	var err error
+	s.once.Do(func() {
+		close(s.closing)
+		<-s.done
+		err = syscall.Close(s.fd)
+	})
+	return err
+}
+
+func (s *netlinkSource) read() {
+	defer close(s.done)
+	defer close(s.events)
+	buffer := make([]byte, 65536)
+	for {
+		select {
+		case <-s.closing:
+			return
+		default:
+		}
+		n, _, err := syscall.Recvfrom(s.fd, buffer, 0)
+		if err != nil {
+			if err == syscall.ENOBUFS {
+				// The socket buffer overflowed: the kernel dropped events
+				// and the cached network data may be stale.
+				if !s.send(NetEvent{Type: NetEventOverrun}) {
+					return
+				}
+				continue
+			}
+			if err == syscall.EINTR || err == syscall.EAGAIN {
+				continue
+			}
+			return
+		}
+		messages, err := syscall.ParseNetlinkMessage(buffer[:n])
+		if err != nil {
+			continue
+		}
+		for i := range messages {
+			if event, ok := parseNetlinkMessage(&messages[i]); ok && !s.send(event) {
+				return
+			}
+		}
+	}
+}
+
+// send delivers an event, unless the source is closing.
+func (s *netlinkSource) send(event NetEvent) bool {
+	select {
+	case s.events <- event:
+		return true
+	case <-s.closing:
+		return false
+	}
+}
+
+func parseNetlinkMessage(message *syscall.NetlinkMessage) (NetEvent, bool) {
+	attributes, err := syscall.ParseNetlinkRouteAttr(message)
+	if err != nil {
+		return NetEvent{}, false
+	}
+	switch message.Header.Type {
+	case syscall.RTM_NEWLINK, syscall.RTM_DELLINK:
+		if len(message.Data) < syscall.SizeofIfInfomsg {
+			return NetEvent{}, false
+		}
+		info := (*syscall.IfInfomsg)(unsafe.Pointer(&message.Data[0]))
+		event := NetEvent{
+			Type:    NetEventLink,
+			Deleted: message.Header.Type == syscall.RTM_DELLINK,
+			Up:      info.Flags&syscall.IFF_UP != 0 && info.Flags&syscall.IFF_RUNNING != 0,
+		}
+		for _, attribute := range attributes {
+			if attribute.Attr.Type == syscall.IFLA_IFNAME {
+				event.Interface = strings.TrimRight(string(attribute.Value), "\x00")
+			}
+		}
+		return event, len(event.Interface) > 0
+	case syscall.RTM_NEWADDR, syscall.RTM_DELADDR:
+		if len(message.Data) < syscall.SizeofIfAddrmsg {
+			return NetEvent{}, false
+		}
+		info := (*syscall.IfAddrmsg)(unsafe.Pointer(&message.Data[0]))
+		event := NetEvent{
+			Type:      NetEventAddress,
+			Deleted:   message.Header.Type == syscall.RTM_DELADDR,
+			Interface: interfaceName(int(info.Index)),
+		}
+		for _, attribute := range attributes {
+			switch attribute.Attr.Type {
+			case syscall.IFA_LOCAL:
+				event.Address = net.IP(attribute.Value)
+			case syscall.IFA_ADDRESS:
+				if event.Address == nil {
+					event.Address = net.IP(attribute.Value)
+				}
+			}
+		}
+		return event, true
+	case syscall.RTM_NEWROUTE, syscall.RTM_DELROUTE:
+		if len(message.Data) < syscall.SizeofRtMsg {
+			return NetEvent{}, false
+		}
+		info := (*syscall.RtMsg)(unsafe.Pointer(&message.Data[0]))
+		if info.Table != rtTableMain {
+			return NetEvent{}, false
+		}
+		event := NetEvent{
+			Type:    NetEventRoute,
+			Deleted: message.Header.Type == syscall.RTM_DELROUTE,
+			Default: info.Dst_len == 0,
+		}
+		for _, attribute := range attributes {
+			switch attribute.Attr.Type {
+			case syscall.RTA_GATEWAY:
+				event.Gateway = net.IP(attribute.Value)
+			case syscall.RTA_OIF:
+				if len(attribute.Value) >= 4 {
+					event.Interface = interfaceName(int(nativeEndian.Uint32(attribute.Value)))
+				}
+			}
+		}
+		return event, true
+	}
+	return NetEvent{}, false
+}
@@ -0,0 +1,87 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"net"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+func TestNetlinkSourceClose(t *testing.T) {
+	source, err := NewSystemEventSource()
+	if err != nil {
+		t.Skipf("netlink not available: %v", err)
+	}
+	// Let the reader block on the socket
+	time.Sleep(100 * time.Millisecond)
+	closed := make(chan error, 1)
+	go func() {
+		closed <- source.Close()
+	}()
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Errorf("Close() = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close() did not interrupt the reader")
+	}
+	for range source.Events() {
+	}
+	if err := source.Close(); err != nil {
+		t.Errorf("second Close() = %v", err)
+	}
+}
+
+func netlinkAttribute(attributeType uint16, value []byte) []byte {
+	b := make([]byte, syscall.SizeofRtAttr, syscall.SizeofRtAttr+len(value))
+	attribute := (*syscall.RtAttr)(unsafe.Pointer(&b[0]))
+	attribute.Type = attributeType
+	attribute.Len = uint16(syscall.SizeofRtAttr + len(value))
+	b = append(b, value...)
+	for len(b)%syscall.NLMSG_ALIGNTO != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+func TestParseNetlinkRoute(t *testing.T) {
+	loopback, err := net.InterfaceByName("lo")
+	if err != nil {
+		t.Skipf("no loopback interface: %v", err)
+	}
+	data := make([]byte, syscall.SizeofRtMsg)
+	info := (*syscall.RtMsg)(unsafe.Pointer(&data[0]))
+	info.Family = syscall.AF_INET
+	info.Table = rtTableMain
+	index := make([]byte, 4)
+	nativeEndian.PutUint32(index, uint32(loopback.Index))
+	data = append(data, netlinkAttribute(syscall.RTA_GATEWAY, []byte{192, 0, 2, 1})...)
+	data = append(data, netlinkAttribute(syscall.RTA_OIF, index)...)
+	message := syscall.NetlinkMessage{
+		Header: syscall.NlMsghdr{Type: syscall.RTM_NEWROUTE, Len: uint32(syscall.NLMSG_HDRLEN + len(data))},
+		Data:   data,
+	}
+	event, ok := parseNetlinkMessage(&message)
+	if !ok {
+		t.Fatal("route message not parsed")
+	}
+	if event.Type != NetEventRoute || !event.Default || event.Deleted {
+		t.Errorf("event = %+v, want a new default route", event)
+	}
+	if event.Interface != "lo" {
+		t.Errorf("interface = %q, want lo", event.Interface)
+	}
+	if !event.Gateway.Equal(net.IPv4(192, 0, 2, 1)) {
+		t.Errorf("gateway = %s, want 192.0.2.1", event.Gateway)
+	}
+}
@@ -9,12 +9,16 @@
 package collect
 
 import (
+	"errors"
+	"fmt"
 	"github.com/COSAE-FR/ripugw/inform"
 	"github.com/COSAE-FR/ripugw/pfconf"
 	hoststats "github.com/shirou/gopsutil/host"
 	"io/ioutil"
 	"net"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -24,6 +28,160 @@ type PfSenseTranslationTable struct {
 	Wan2 string `json:"wan2,omitempty"`
 	Lan  string `json:"lan"`
 	Uid  string `json:"uid,omitempty"`
+	// Model forces the UniFi model announced, chosen from the ports used
+	// when empty
+	Model    string             `toml:"model,omitempty" json:"model,omitempty"`
+	Mappings []InterfaceMapping `toml:"mappings,omitempty" json:"mappings,omitempty"`
+}
+
+// InterfaceMapping maps a pfSense interface (wan, lan, opt1...) to a UniFi
+// role: wan, wan2, lan, lan2... The port number gives the ethN name.
+type InterfaceMapping struct {
+	Pfsense string `toml:"pfsense" json:"pfsense"`
+	Role    string `toml:"role" json:"role"`
+	Label   string `toml:"label,omitempty" json:"label,omitempty"`
+	Port    *int   `toml:"port,omitempty" json:"port,omitempty"`
+}
+
+const (
+	RoleWan  = "wan"
+	RoleWan2 = "wan2"
+	RoleLan  = "lan"
+	RoleUid  = "uid"
+)
+
+// Historical ports of the Wan, Lan and Wan2 mappings
+var defaultRolePorts = map[string]int{RoleWan: 0, RoleLan: 1, RoleWan2: 2}
+
+func isLanRole(role string) bool {
+	if role == RoleLan {
+		return true
+	}
+	if !strings.HasPrefix(role, RoleLan) {
+		return false
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(role, RoleLan))
+	return err == nil && n > 1
+}
+
+func validRole(role string) bool {
+	return role == RoleWan || role == RoleWan2 || role == RoleUid || isLanRole(role)
+}
+
+// All returns the mappings of the table, the Wan, Wan2, Lan and Uid
+// fields first, with their port numbers set.
+func (t PfSenseTranslationTable) All() []InterfaceMapping {
+	return t.mappings(nil)
+}
+
+// Resolve returns the mappings like All, VLANs of a mapped parent
+// excepted from the port numbering: they share the port of their parent.
+func (t PfSenseTranslationTable) Resolve(pfsense pfconf.Configuration) []InterfaceMapping {
+	devices := make(map[string]string)
+	for _, iface := range pfsense.Interfaces.List {
+		devices[iface.XMLName.Local] = iface.If
+	}
+	mapped := make(map[string]bool)
+	for _, mapping := range t.All() {
+		mapped[devices[mapping.Pfsense]] = true
+	}
+	tagged := make(map[string]bool)
+	for _, mapping := range t.All() {
+		if vlan, ok := pfsense.Vlan(devices[mapping.Pfsense]); ok && mapped[vlan.If] {
+			tagged[mapping.Pfsense] = true
+		}
+	}
+	return t.mappings(tagged)
+}
+
+func (t PfSenseTranslationTable) mappings(tagged map[string]bool) []InterfaceMapping {
+	var mappings []InterfaceMapping
+	seen := make(map[string]bool)
+	add := func(mapping InterfaceMapping) {
+		if len(mapping.Pfsense) == 0 || seen[mapping.Role] {
+			return
+		}
+		seen[mapping.Role] = true
+		mappings = append(mappings, mapping)
+	}
+	add(InterfaceMapping{Pfsense: t.Wan, Role: RoleWan})
+	add(InterfaceMapping{Pfsense: t.Lan, Role: RoleLan})
+	add(InterfaceMapping{Pfsense: t.Wan2, Role: RoleWan2})
+	add(InterfaceMapping{Pfsense: t.Uid, Role: RoleUid})
+	for _, mapping := range t.Mappings {
+		add(mapping)
+	}
+	used := make(map[int]bool)
+	for _, mapping := range mappings {
+		if mapping.Port != nil {
+			used[*mapping.Port] = true
+		}
+	}
+	for i := range mappings {
+		if mappings[i].Port != nil || mappings[i].Role == RoleUid || tagged[mappings[i].Pfsense] {
+			continue
+		}
+		port, ok := defaultRolePorts[mappings[i].Role]
+		if !ok || used[port] {
+			for port = 0; used[port]; port++ {
+			}
+		}
+		used[port] = true
+		mappings[i].Port = &port
+	}
+	return mappings
+}
+
+// Role returns the role of a pfSense interface, if mapped.
+func (t PfSenseTranslationTable) Role(pfsense string) string {
+	for _, mapping := range t.All() {
+		if mapping.Pfsense == pfsense {
+			return mapping.Role
+		}
+	}
+	return ""
+}
+
+// Validate checks the mappings against the pfSense interfaces.
+func (t PfSenseTranslationTable) Validate(pfsense pfconf.Configuration) error {
+	known := make(map[string]bool)
+	for _, iface := range pfsense.Interfaces.List {
+		known[iface.XMLName.Local] = true
+	}
+	var problems []string
+	ports := make(map[int]string)
+	layout := 0
+	hasWan, hasLan := false, false
+	mappings := t.Resolve(pfsense)
+	for _, mapping := range mappings {
+		if !validRole(mapping.Role) {
+			problems = append(problems, fmt.Sprintf("invalid role %q for %s", mapping.Role, mapping.Pfsense))
+		}
+		if !known[mapping.Pfsense] {
+			problems = append(problems, fmt.Sprintf("unknown pfSense interface %s for %s", mapping.Pfsense, mapping.Role))
+		}
+		if mapping.Port != nil {
+			if other, ok := ports[*mapping.Port]; ok {
+				problems = append(problems, fmt.Sprintf("port %d used by %s and %s", *mapping.Port, other, mapping.Role))
+			}
+			ports[*mapping.Port] = mapping.Role
+			if *mapping.Port >= layout {
+				layout = *mapping.Port + 1
+			}
+		}
+		hasWan = hasWan || mapping.Role == RoleWan
+		hasLan = hasLan || isLanRole(mapping.Role)
+	}
+	if !hasWan || !hasLan {
+		problems = append(problems, "at least a wan and a lan mapping are needed")
+	}
+	if _, err := SelectDeviceModel(t.Model, layout); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, ", "))
+	}
+	return nil
 }
 
 type TranslatedInterface struct {
@@ -31,6 +189,11 @@ type TranslatedInterface struct {
 	Pfsense    pfconf.Interface
 	UnifiName  string
 	UnifiLabel string
+	Role       string
+	Port       int
+	// VLAN tag and UniFi name of the parent port, for tagged networks
+	Vlan   int
+	Parent string
 }
 
 type PfSenseTranslation struct {
@@ -38,6 +201,10 @@ type PfSenseTranslation struct {
 	Wan2 TranslatedInterface
 	Lan  TranslatedInterface
 	Uid  TranslatedInterface
+	// Lans holds all the LAN roles, Lan first
+	Lans []TranslatedInterface
+	// Ports is the number of UniFi ports needed, up to the highest used
+	Ports int
 }
 
 func prepareRealInterface(pfInterface pfconf.Interface, interfaces []inform.Interface, unifiName string, unifiLabel string) TranslatedInterface {
@@ -54,25 +221,110 @@ func prepareRealInterface(pfInterface pfconf.Interface, interfaces []inform.Inte
 	return result
 }
 
-func populateInterfaces(p []inform.Interface, pfsense []pfconf.Interface, translation PfSenseTranslationTable) PfSenseTranslation {
+func populateInterfaces(p []inform.Interface, pfsense pfconf.Configuration, translation PfSenseTranslationTable) PfSenseTranslation {
 	result := PfSenseTranslation{}
-	for _, iface := range pfsense {
-		if iface.XMLName.Local == translation.Lan {
-			result.Lan = prepareRealInterface(iface, p, "eth1", "lan")
+	var translations []TranslatedInterface
+	for _, mapping := range translation.Resolve(pfsense) {
+		for _, iface := range pfsense.Interfaces.List {
+			if iface.XMLName.Local != mapping.Pfsense {
+				continue
+			}
+			label := mapping.Label
+			if len(label) == 0 {
+				label = mapping.Role
+			}
+			name := ""
+			if mapping.Port != nil {
+				name = fmt.Sprintf("eth%d", *mapping.Port)
+				if *mapping.Port >= result.Ports {
+					result.Ports = *mapping.Port + 1
+				}
+			}
+			translated := prepareRealInterface(iface, p, name, label)
+			translated.Role = mapping.Role
+			if mapping.Port != nil {
+				translated.Port = *mapping.Port
+			}
+			if vlan, ok := pfsense.Vlan(iface.If); ok {
+				translated.Vlan = vlan.Tag
+			}
+			translations = append(translations, translated)
 		}
-		if iface.XMLName.Local == translation.Wan {
-			result.Wan = prepareRealInterface(iface, p, "eth0", "wan")
+	}
+	// Tagged networks go on the port of their parent
+	for i := range translations {
+		vlan, ok := pfsense.Vlan(translations[i].Pfsense.If)
+		if !ok || len(translations[i].UnifiName) > 0 {
+			continue
 		}
-		if iface.XMLName.Local == translation.Wan2 {
-			result.Wan2 = prepareRealInterface(iface, p, "eth2", "wan2")
+		for _, parent := range translations {
+			if parent.Pfsense.If == vlan.If && len(parent.UnifiName) > 0 {
+				translations[i].UnifiName = fmt.Sprintf("%s.%d", parent.UnifiName, vlan.Tag)
+				translations[i].Port = parent.Port
+				translations[i].Parent = parent.UnifiName
+			}
 		}
-		if iface.XMLName.Local == translation.Uid {
-			result.Uid = prepareRealInterface(iface, p, "", "uid")
+	}
+	for _, translated := range translations {
+		switch translated.Role {
+		case RoleWan:
+			result.Wan = translated
+		case RoleWan2:
+			result.Wan2 = translated
+		case RoleUid:
+			result.Uid = translated
+		default:
+			if translated.Role == RoleLan {
+				result.Lan = translated
+			}
+			result.Lans = append(result.Lans, translated)
 		}
 	}
+	sort.SliceStable(result.Lans, func(i, j int) bool {
+		return result.Lans[i].Role == RoleLan && result.Lans[j].Role != RoleLan
+	})
 	return result
 }
 
+// lanNetwork describes the network of a translated LAN interface. The
+// description is the UniFi label when one is configured, then the VLAN
+// description, then the pfSense interface description.
+func lanNetwork(translated TranslatedInterface, pfsense pfconf.Configuration, translation PfSenseTranslationTable, hosts map[string][]inform.Host) inform.Network {
+	network := networkEntry(translated.UnifiName, translated.Physical, hosts)
+	if translated.UnifiLabel != translated.Role {
+		network.Description = translated.UnifiLabel
+	}
+	if translated.Vlan > 0 {
+		network.VlanEnabled = true
+		network.Vlan = translated.Vlan
+		if vlan, ok := pfsense.Vlan(translated.Pfsense.If); ok && len(network.Description) == 0 {
+			network.Description = vlan.Description
+		}
+	}
+	applyDhcpServer(&network, translated.Pfsense, pfsense)
+	applyPfSenseIpv6Network(&network, translated.Pfsense, translation)
+	return network
+}
+
+// ethernetTable lists the mapped ports in order.
+func ethernetTable(table PfSenseTranslation) []inform.EthernetTableEntry {
+	var entries []inform.EthernetTableEntry
+	for _, translated := range append([]TranslatedInterface{table.Wan, table.Wan2}, table.Lans...) {
+		if translated.Pfsense.If == "" || translated.UnifiName == "" || len(translated.Parent) > 0 {
+			continue
+		}
+		entries = append(entries, inform.EthernetTableEntry{
+			Name:    translated.UnifiName,
+			Mac:     translated.Physical.Mac.String(),
+			NumPort: uint64(translated.Port),
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].NumPort < entries[j].NumPort
+	})
+	return entries
+}
+
 func computeMacFromIp(ip string) inform.HardwareAddr {
 	ipObject := net.ParseIP(ip)
 	if ipObject == nil {
@@ -115,7 +367,7 @@ func GetPfSenseVersion() string {
 	return ""
 }
 
-func RequestFromPfsense(address string, version string, pfsense pfconf.Configuration, translation PfSenseTranslationTable, speedtest *inform.SpeedTestStatus) (inform.Inform, error) {
+func RequestFromPfsense(address string, version string, pfsense pfconf.Configuration, translation PfSenseTranslationTable, speedtest *inform.SpeedTestStatus, options Options) (inform.Inform, error) {
 	now := time.Now()
 	request := inform.Inform{
 		ConfigNetworkWan:     inform.NetworkConfig{Type: inform.NetworkConfigDhcp},
@@ -182,12 +434,42 @@ func RequestFromPfsense(address string, version string, pfsense pfconf.Configura
 		request.SysStats = sys
 	}
 
+	// LAN clients
+	var hosts map[string][]inform.Host
+	if !options.DisableClients {
+		hosts, _ = HostTables(options.LeaseFiles)
+		applyStaticMaps(hosts, pfsense.Dhcpd.StaticMaps())
+	}
+
+	// Routes
+	routes, _ := Routes()
+	physical := make(map[string]string)
+	for _, pfInterface := range pfsense.Interfaces.List {
+		physical[pfInterface.XMLName.Local] = pfInterface.If
+	}
+	routes = mergeStaticRoutes(routes, pfSenseStaticRoutes(pfsense, physical))
+	request.RouteTable = RouteTable(routes)
+
 	// Interfaces
 	ifaces, err := Network()
 	if err == nil {
+		options.Rates.ApplyAll(ifaces)
+		options.Links.ApplyAll(ifaces)
 		request.IntfTable = make([]inform.Interface, 0)
 		request.PortTable = make([]inform.Port, 0)
-		table := populateInterfaces(ifaces, pfsense.Interfaces.List, translation)
+		table := populateInterfaces(ifaces, pfsense, translation)
+		if model, err := SelectDeviceModel(translation.Model, table.Ports); err == nil || len(translation.Model) == 0 {
+			request.Model = model.Model
+			request.ModelDisplay = model.Display
+		}
+		var wanPpp, wan2Ppp pfconf.Ppp
+		if table.Wan.Pfsense.Ip == "pppoe" {
+			table.Wan, wanPpp = resolvePppoe(table.Wan, ifaces, pfsense)
+		}
+		if table.Wan2.Pfsense.Ip == "pppoe" {
+			table.Wan2, wan2Ppp = resolvePppoe(table.Wan2, ifaces, pfsense)
+		}
+		options.Aggregates.set(applyPfSenseAggregates(&table, ifaces, pfsense))
 		if table.Wan.Pfsense.If != "" {
 			// General
 			request.Uplink = table.Wan.UnifiName
@@ -198,14 +480,32 @@ func RequestFromPfsense(address string, version string, pfsense pfconf.Configura
 
 			wan := table.Wan.Physical
 			wan.Name = table.Wan.UnifiName
-			gateway, err := Gateway()
-			if err == nil {
+			gateway := InterfaceGateway(routes, table.Wan.Physical.Name, false)
+			if gateway == nil {
+				gateway, _ = Gateway()
+			}
+			if gateway != nil {
 				wan.Gateways = append(wan.Gateways, gateway.String())
 			}
+			gateway6 := ""
+			if pfSenseIpv6Type(table.Wan.Pfsense) != inform.NetworkConfigDisabled {
+				gateway6 = pfSenseGateway6(table.Wan.Pfsense, pfsense.Gateways)
+				if gateway := InterfaceGateway(routes, table.Wan.Physical.Name, true); len(gateway6) == 0 && gateway != nil {
+					gateway6 = gateway.String()
+				}
+				if gateway, err := Gateway6(); len(gateway6) == 0 && err == nil {
+					gateway6 = gateway.String()
+				}
+				if len(gateway6) > 0 {
+					wan.Gateways = append(wan.Gateways, gateway6)
+				}
+			}
 			if len(pfsense.System.DnsServers) > 0 {
 				wan.Nameservers = pfsense.System.DnsServers
 			}
-			if speedtest != nil {
+			if status, err := InterfaceGatewayStatus(options.DpingerDir, table.Wan.Pfsense, pfsense.Gateways); err == nil {
+				applyGatewayStatus(&request, &wan, "WAN", status)
+			} else if !applyUplink(&request, &wan, table.Wan.Physical.Name, "WAN", options.Uplinks) && speedtest != nil {
 				wan.Latency = speedtest.Latency
 			}
 			request.IntfTable = append(request.IntfTable, wan)
@@ -213,7 +513,14 @@ func RequestFromPfsense(address string, version string, pfsense pfconf.Configura
 				IfName: wan.Name,
 				Name:   "wan",
 			})
-			if table.Wan.Pfsense.Ip != "dhcp" {
+			if table.Wan.Pfsense.Ip == "dhcp" {
+				request.ConfigNetworkWan.IfName = table.Wan.UnifiName
+				if lease, err := ReadWanLease(table.Wan.Physical.Name); err == nil {
+					request.ConfigNetworkWan.Lease = lease.Inform()
+				}
+			} else if table.Wan.Pfsense.Ip == "pppoe" {
+				request.ConfigNetworkWan = pppoeConfig(table.Wan, wanPpp, gateway)
+			} else {
 				request.ConfigNetworkWan = inform.NetworkConfig{
 					Type:    "static",
 					Ip:      table.Wan.Physical.Ip,
@@ -233,16 +540,50 @@ func RequestFromPfsense(address string, version string, pfsense pfconf.Configura
 					break
 				}
 			}
+			applyIpv6Config(&request.ConfigNetworkWan, table.Wan.Physical, pfSenseIpv6Type(table.Wan.Pfsense), gateway6)
 		}
 		if table.Wan2.Pfsense.If != "" {
 			wan := table.Wan2.Physical
 			wan.Name = table.Wan2.UnifiName
+			gateway := InterfaceGateway(routes, table.Wan2.Physical.Name, false)
+			if gateway == nil {
+				name, _ := gatewayName(table.Wan2.Pfsense, pfsense.Gateways, DpingerSockets(options.DpingerDir))
+				gateway = gatewayIp(pfsense, name)
+			}
+			if gateway != nil {
+				wan.Gateways = append(wan.Gateways, gateway.String())
+			}
+			gateway6 := ""
+			if pfSenseIpv6Type(table.Wan2.Pfsense) != inform.NetworkConfigDisabled {
+				gateway6 = pfSenseGateway6(table.Wan2.Pfsense, pfsense.Gateways)
+				if gateway := InterfaceGateway(routes, table.Wan2.Physical.Name, true); len(gateway6) == 0 && gateway != nil {
+					gateway6 = gateway.String()
+				}
+				if len(gateway6) > 0 {
+					wan.Gateways = append(wan.Gateways, gateway6)
+				}
+			}
+			if status, err := InterfaceGatewayStatus(options.DpingerDir, table.Wan2.Pfsense, pfsense.Gateways); err == nil {
+				applyGatewayStatus(&request, &wan, "WAN2", status)
+			} else {
+				applyUplink(&request, &wan, table.Wan2.Physical.Name, "WAN2", options.Uplinks)
+			}
 			request.IntfTable = append(request.IntfTable, wan)
 			request.PortTable = append(request.PortTable, inform.Port{
 				IfName: wan.Name,
 				Name:   "wan2",
 			})
-			if table.Wan2.Pfsense.Ip != "dhcp" {
+			if table.Wan2.Pfsense.Ip == "dhcp" {
+				request.ConfigNetworkWan2 = inform.NetworkConfig{
+					Type:   inform.NetworkConfigDhcp,
+					IfName: table.Wan2.UnifiName,
+				}
+				if lease, err := ReadWanLease(table.Wan2.Physical.Name); err == nil {
+					request.ConfigNetworkWan2.Lease = lease.Inform()
+				}
+			} else if table.Wan2.Pfsense.Ip == "pppoe" {
+				request.ConfigNetworkWan2 = pppoeConfig(table.Wan2, wan2Ppp, gateway)
+			} else {
 				request.ConfigNetworkWan2 = inform.NetworkConfig{
 					Type:    "static",
 					Ip:      table.Wan2.Physical.Ip,
@@ -262,17 +603,47 @@ func RequestFromPfsense(address string, version string, pfsense pfconf.Configura
 					break
 				}
 			}
+			applyIpv6Config(&request.ConfigNetworkWan2, table.Wan2.Physical, pfSenseIpv6Type(table.Wan2.Pfsense), gateway6)
 		}
-		if table.Lan.Pfsense.If != "" {
-			lan := table.Lan.Physical
-			lan.Name = table.Lan.UnifiName
-			lan.Mac = table.Lan.Physical.Mac
+		var uplinks []wanUplink
+		for _, uplink := range []struct {
+			label      string
+			translated TranslatedInterface
+		}{{"WAN", table.Wan}, {"WAN2", table.Wan2}} {
+			if uplink.translated.Pfsense.If == "" {
+				continue
+			}
+			for i := range request.IntfTable {
+				if request.IntfTable[i].Name == uplink.translated.UnifiName {
+					name, _ := gatewayName(uplink.translated.Pfsense, pfsense.Gateways, DpingerSockets(options.DpingerDir))
+					uplinks = append(uplinks, wanUplink{
+						Label:       uplink.label,
+						Entry:       &request.IntfTable[i],
+						GatewayName: name,
+						GatewayIp:   gatewayIp(pfsense, name),
+					})
+				}
+			}
+		}
+		// The default route is read from the system, not the cache, as it
+		// moves on failover
+		defaultGateway, _ := getGateway()
+		applyDualWan(&request, pfsense, uplinks, defaultGateway)
+		for _, translated := range table.Lans {
+			if translated.Pfsense.If == "" {
+				continue
+			}
+			lan := translated.Physical
+			lan.Name = translated.UnifiName
 			request.IntfTable = append(request.IntfTable, lan)
 			request.PortTable = append(request.PortTable, inform.Port{
 				IfName: lan.Name,
-				Name:   "lan",
+				Name:   translated.Role,
 			})
+			request.NetworkTable = append(request.NetworkTable, lanNetwork(translated, pfsense, translation, hosts))
 		}
+		request.EthernetTable = ethernetTable(table)
+		request.HasEth1 = table.Ports > 1
 		if table.Uid.Physical.Ip != "" {
 			mac := computeMacFromIp(table.Uid.Physical.Ip)
 			request.Mac = mac
@@ -0,0 +1,97 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/COSAE-FR/ripugw/inform"
+	"github.com/COSAE-FR/ripugw/pfconf"
+)
+
+func loadPfSenseFixture(t *testing.T, name string) pfconf.Configuration {
+	t.Helper()
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pfsense pfconf.Configuration
+	if err := xml.Unmarshal(data, &pfsense); err != nil {
+		t.Fatal(err)
+	}
+	if err := pfsense.Finalize(); err != nil {
+		t.Fatal(err)
+	}
+	return pfsense
+}
+
+func TestLanNetworkDescription(t *testing.T) {
+	pfsense := loadPfSenseFixture(t, "config.xml")
+	translation := PfSenseTranslationTable{
+		Wan: "wan",
+		Lan: "lan",
+		Mappings: []InterfaceMapping{
+			{Pfsense: "opt1", Role: "lan2"},
+			{Pfsense: "opt2", Role: "lan3", Label: "IoT"},
+			{Pfsense: "opt3", Role: "lan4"},
+		},
+	}
+	if err := translation.Validate(pfsense); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+	physical := []inform.Interface{
+		{Name: "em0", Ip: "198.51.100.2", Netmask: "255.255.255.0"},
+		{Name: "em1", Ip: "192.168.1.1", Netmask: "255.255.255.0"},
+		{Name: "em1.10", Ip: "192.168.10.1", Netmask: "255.255.255.0"},
+		{Name: "em1.20", Ip: "192.168.20.1", Netmask: "255.255.255.0"},
+		{Name: "em1.30", Ip: "192.168.30.1", Netmask: "255.255.255.0"},
+	}
+	table := populateInterfaces(physical, pfsense, translation)
+	tests := []struct {
+		role        string
+		name        string
+		vlan        int
+		description string
+		dhcpd       bool
+	}{
+		{"lan", "eth1", 0, "LAN", true},
+		{"lan2", "eth1.10", 10, "Guests", true},
+		{"lan3", "eth1.20", 20, "IoT", false},
+		{"lan4", "eth1.30", 30, "CAMERAS", false},
+	}
+	for _, test := range tests {
+		t.Run(test.role, func(t *testing.T) {
+			var translated *TranslatedInterface
+			for i := range table.Lans {
+				if table.Lans[i].Role == test.role {
+					translated = &table.Lans[i]
+				}
+			}
+			if translated == nil {
+				t.Fatalf("no translation for %s", test.role)
+			}
+			network := lanNetwork(*translated, pfsense, translation, nil)
+			if network.Name != test.name {
+				t.Errorf("name = %q, want %q", network.Name, test.name)
+			}
+			if network.Vlan != test.vlan || network.VlanEnabled != (test.vlan > 0) {
+				t.Errorf("vlan = %d (enabled %v), want %d", network.Vlan, network.VlanEnabled, test.vlan)
+			}
+			if network.Description != test.description {
+				t.Errorf("description = %q, want %q", network.Description, test.description)
+			}
+			if network.DhcpdEnabled != test.dhcpd {
+				t.Errorf("dhcpd enabled = %v, want %v", network.DhcpdEnabled, test.dhcpd)
+			}
+		})
+	}
+}
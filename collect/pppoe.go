@@ -0,0 +1,100 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"github.com/COSAE-FR/ripugw/inform"
+	"github.com/COSAE-FR/ripugw/pfconf"
+	"io/ioutil"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPppoeMtu = 1492
+	// A PPP link is point to point: the peer is the gateway
+	pppoeNetmask = "255.255.255.255"
+)
+
+// PppUptimeDir holds the pfSense <device>up files, written by the PPP link
+// up script with the session start time.
+var PppUptimeDir = "/tmp"
+
+func pppSessionStart(device string) (time.Time, error) {
+	content, err := ioutil.ReadFile(path.Join(PppUptimeDir, device+"up"))
+	if err != nil {
+		return time.Time{}, err
+	}
+	start, err := strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(start, 0), nil
+}
+
+// resolvePppoe points a PPPoE WAN to its pppoeN device for the counters,
+// with the MAC address of the underlying port and the session uptime.
+func resolvePppoe(wan TranslatedInterface, ifaces []inform.Interface, pfsense pfconf.Configuration) (TranslatedInterface, pfconf.Ppp) {
+	ppp, ok := pfsense.Ppp(wan.Pfsense.If)
+	if !ok {
+		return wan, ppp
+	}
+	for _, iface := range ifaces {
+		if iface.Name == ppp.If {
+			wan.Physical = iface
+		}
+	}
+	if len(wan.Physical.Mac) == 0 {
+		for _, port := range ppp.Ports() {
+			for _, iface := range ifaces {
+				if iface.Name == port && len(iface.Mac) > 0 {
+					wan.Physical.Mac = iface.Mac
+					break
+				}
+			}
+			if len(wan.Physical.Mac) > 0 {
+				break
+			}
+		}
+	}
+	if start, err := pppSessionStart(ppp.If); err == nil && time.Since(start) > 0 {
+		wan.Physical.Uptime = uint64(time.Since(start).Seconds())
+	}
+	return wan, ppp
+}
+
+// pppoeConfig returns the network config of a PPPoE WAN, gateway being the
+// peer address of the link.
+func pppoeConfig(wan TranslatedInterface, ppp pfconf.Ppp, gateway net.IP) inform.NetworkConfig {
+	mtu := defaultPppoeMtu
+	// One MTU per port, the first one applies to the link
+	if values := strings.Split(ppp.Mtu, ","); len(values) > 0 {
+		if value, err := strconv.Atoi(strings.TrimSpace(values[0])); err == nil && value > 0 {
+			mtu = value
+		}
+	}
+	config := inform.NetworkConfig{
+		Type:     inform.NetworkConfigPppoe,
+		Username: ppp.Username,
+		Mtu:      mtu,
+		Ip:       wan.Physical.Ip,
+		Netmask:  wan.Physical.Netmask,
+		IfName:   wan.UnifiName,
+	}
+	if len(config.Ip) > 0 && len(config.Netmask) == 0 {
+		config.Netmask = pppoeNetmask
+	}
+	if gateway != nil {
+		config.Gateway = gateway.String()
+	}
+	return config
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/COSAE-FR/ripugw/inform"
+	"github.com/COSAE-FR/ripugw/pfconf"
+)
+
+func pppoeInterfaces(t *testing.T) []inform.Interface {
+	mac, err := net.ParseMAC("00:11:22:33:44:02")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return []inform.Interface{
+		{Name: "em2", Mac: inform.HardwareAddr(mac)},
+		{Name: "pppoe0", Ip: "203.0.113.10", Netmask: "255.255.255.255"},
+		{Name: "pppoe1", Ip: "198.51.100.20"},
+	}
+}
+
+func TestResolvePppoe(t *testing.T) {
+	pfsense := loadPfSenseFixture(t, "config.xml")
+	ifaces := pppoeInterfaces(t)
+	PppUptimeDir = t.TempDir()
+	defer func() { PppUptimeDir = "/tmp" }()
+	start := time.Now().Add(-time.Hour).Unix()
+	if err := ioutil.WriteFile(filepath.Join(PppUptimeDir, "pppoe0up"), []byte(strconv.FormatInt(start, 10)+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	wan, ppp := resolvePppoe(TranslatedInterface{Pfsense: pfconf.Interface{If: "pppoe0", Ip: "pppoe"}}, ifaces, pfsense)
+	if ppp.If != "pppoe0" || ppp.Username != "user@isp.example" {
+		t.Fatalf("resolvePppoe() link = %+v, want pppoe0", ppp)
+	}
+	if wan.Physical.Name != "pppoe0" || wan.Physical.Ip != "203.0.113.10" {
+		t.Errorf("physical = %+v, want the pppoe0 device", wan.Physical)
+	}
+	if wan.Physical.Mac.String() != "00:11:22:33:44:02" {
+		t.Errorf("MAC = %s, want the em2 one", wan.Physical.Mac)
+	}
+	if wan.Physical.Uptime < 3600 || wan.Physical.Uptime > 3660 {
+		t.Errorf("uptime = %d, want about an hour", wan.Physical.Uptime)
+	}
+
+	// No up file, no MAC on the em3 port
+	wan, ppp = resolvePppoe(TranslatedInterface{Pfsense: pfconf.Interface{If: "em3", Ip: "pppoe"}}, ifaces, pfsense)
+	if ppp.If != "pppoe1" || wan.Physical.Name != "pppoe1" {
+		t.Errorf("resolvePppoe(em3) = %+v, %+v, want pppoe1", wan.Physical, ppp)
+	}
+	if wan.Physical.Uptime != 0 || len(wan.Physical.Mac) != 0 {
+		t.Errorf("pppoe1 uptime = %d, MAC = %s, want none", wan.Physical.Uptime, wan.Physical.Mac)
+	}
+
+	// Not a PPP link: unchanged
+	physical := inform.Interface{Name: "em0"}
+	wan, ppp = resolvePppoe(TranslatedInterface{Physical: physical, Pfsense: pfconf.Interface{If: "em0"}}, ifaces, pfsense)
+	if len(ppp.If) != 0 || wan.Physical.Name != "em0" {
+		t.Errorf("resolvePppoe(em0) = %+v, %+v, want no link", wan.Physical, ppp)
+	}
+}
+
+func TestPppoeConfig(t *testing.T) {
+	pfsense := loadPfSenseFixture(t, "config.xml")
+	ifaces := pppoeInterfaces(t)
+	tests := []struct {
+		name    string
+		ppp     string
+		gateway net.IP
+		want    inform.NetworkConfig
+	}{
+		// First MTU of the per port list
+		{"mtu list", "pppoe0", net.ParseIP("203.0.113.1"), inform.NetworkConfig{
+			Type:     inform.NetworkConfigPppoe,
+			Username: "user@isp.example",
+			Mtu:      1480,
+			Ip:       "203.0.113.10",
+			Netmask:  "255.255.255.255",
+			Gateway:  "203.0.113.1",
+			IfName:   "eth0",
+		}},
+		// Default MTU, point to point netmask
+		{"defaults", "pppoe1", nil, inform.NetworkConfig{
+			Type:     inform.NetworkConfigPppoe,
+			Username: "backup@isp.example",
+			Mtu:      defaultPppoeMtu,
+			Ip:       "198.51.100.20",
+			Netmask:  pppoeNetmask,
+			IfName:   "eth0",
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ppp, ok := pfsense.Ppp(test.ppp)
+			if !ok {
+				t.Fatalf("no %s link in the fixture", test.ppp)
+			}
+			wan := TranslatedInterface{UnifiName: "eth0"}
+			for _, iface := range ifaces {
+				if iface.Name == test.ppp {
+					wan.Physical = iface
+				}
+			}
+			if got := pppoeConfig(wan, ppp, test.gateway); got != test.want {
+				t.Errorf("pppoeConfig() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"github.com/COSAE-FR/ripugw/inform"
+	"math"
+	"sync"
+	"time"
+)
+
+// Samples closer than this keep the previously computed rates
+const minRateInterval = time.Second
+
+type counterSample struct {
+	at        time.Time
+	rxBytes   uint64
+	txBytes   uint64
+	rxPackets uint64
+	txPackets uint64
+	rates     [4]float64
+}
+
+// RateTracker computes per second rates from the cumulative interface
+// counters of consecutive informs.
+type RateTracker struct {
+	lock    sync.Mutex
+	samples map[string]counterSample
+}
+
+func NewRateTracker() *RateTracker {
+	return &RateTracker{samples: make(map[string]counterSample)}
+}
+
+// counterDelta returns the increase between two counter values. A decrease
+// is a 32 bits counter wrap when the previous value fits in 32 bits and
+// was close to the limit, a counter reset otherwise.
+func counterDelta(previous uint64, current uint64) (uint64, bool) {
+	if current >= previous {
+		return current - previous, true
+	}
+	if previous <= math.MaxUint32 && previous-current > math.MaxUint32/2 {
+		return current + (math.MaxUint32 + 1) - previous, true
+	}
+	return 0, false
+}
+
+// Apply fills the rate fields of iface, keyed by its physical name.
+func (t *RateTracker) Apply(iface *inform.Interface, now time.Time) {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	current := counterSample{
+		at:        now,
+		rxBytes:   iface.RxBytes,
+		txBytes:   iface.TxBytes,
+		rxPackets: iface.RxPackets,
+		txPackets: iface.TxPackets,
+	}
+	previous, ok := t.samples[iface.Name]
+	if ok {
+		elapsed := now.Sub(previous.at)
+		if elapsed < minRateInterval && elapsed >= 0 {
+			current = previous
+		} else if elapsed > 0 {
+			pairs := [4][2]uint64{
+				{previous.rxBytes, current.rxBytes},
+				{previous.txBytes, current.txBytes},
+				{previous.rxPackets, current.rxPackets},
+				{previous.txPackets, current.txPackets},
+			}
+			for i, pair := range pairs {
+				if delta, valid := counterDelta(pair[0], pair[1]); valid {
+					current.rates[i] = float64(delta) / elapsed.Seconds()
+				}
+			}
+		}
+	}
+	t.samples[iface.Name] = current
+	iface.RxBytesRate = current.rates[0]
+	iface.TxBytesRate = current.rates[1]
+	iface.RxPacketsRate = current.rates[2]
+	iface.TxPacketsRate = current.rates[3]
+}
+
+// ApplyAll fills the rate fields of every interface and forgets the
+// interfaces missing from ifaces, so a recreated interface starts again
+// from its first sample.
+func (t *RateTracker) ApplyAll(ifaces []inform.Interface) {
+	if t == nil {
+		return
+	}
+	now := time.Now()
+	seen := make(map[string]bool, len(ifaces))
+	for i := range ifaces {
+		t.Apply(&ifaces[i], now)
+		seen[ifaces[i].Name] = true
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for name := range t.samples {
+		if !seen[name] {
+			delete(t.samples, name)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"github.com/COSAE-FR/ripugw/inform"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCounterDelta(t *testing.T) {
+	tests := []struct {
+		name              string
+		previous, current uint64
+		delta             uint64
+		valid             bool
+	}{
+		{"increase", 1000, 1500, 500, true},
+		{"unchanged", 1000, 1000, 0, true},
+		{"32 bits wrap", math.MaxUint32 - 99, 400, 500, true},
+		{"32 bits wrap at the limit", math.MaxUint32, 0, 1, true},
+		{"64 bits reset", 1 << 40, 1000, 0, false},
+		{"64 bits counter past 32 bits", math.MaxUint32 + 10, 5, 0, false},
+		{"decrease after a small counter", 1000, 10, 0, false},
+		{"decrease in the lower half", math.MaxUint32 / 2, 10, 0, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			delta, valid := counterDelta(test.previous, test.current)
+			if delta != test.delta || valid != test.valid {
+				t.Errorf("counterDelta(%d, %d) = %d, %v, want %d, %v", test.previous, test.current, delta, valid, test.delta, test.valid)
+			}
+		})
+	}
+}
+
+func TestRateTracker(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	tests := []struct {
+		name    string
+		elapsed time.Duration
+		rxBytes uint64
+		txBytes uint64
+		rx, tx  float64
+	}{
+		{"first sample", 0, 1000, math.MaxUint32 - 999, 0, 0},
+		{"rates", 10 * time.Second, 11000, math.MaxUint32, 1000, 99.9},
+		{"32 bits wrap", 20 * time.Second, 21000, 999, 1000, 100},
+		{"too close keeps the rates", 20*time.Second + 500*time.Millisecond, 50000, 5000, 1000, 100},
+		{"reset", 30 * time.Second, 500, 1999, 0, 100},
+	}
+	tracker := NewRateTracker()
+	for _, test := range tests {
+		iface := inform.Interface{Name: "eth0", RxBytes: test.rxBytes, TxBytes: test.txBytes}
+		tracker.Apply(&iface, start.Add(test.elapsed))
+		if iface.RxBytesRate != test.rx || iface.TxBytesRate != test.tx {
+			t.Errorf("%s: rates = %g, %g, want %g, %g", test.name, iface.RxBytesRate, iface.TxBytesRate, test.rx, test.tx)
+		}
+	}
+}
+
+func TestRateTrackerForgetsVanishedInterfaces(t *testing.T) {
+	tracker := NewRateTracker()
+	tracker.ApplyAll([]inform.Interface{{Name: "eth0", RxBytes: 1000}, {Name: "ppp0", RxBytes: 5000000}})
+	tracker.ApplyAll([]inform.Interface{{Name: "eth0", RxBytes: 2000}})
+	if _, ok := tracker.samples["ppp0"]; ok {
+		t.Fatal("sample of a vanished interface kept")
+	}
+	// Make the next collection far enough from the previous one
+	for name, sample := range tracker.samples {
+		sample.at = sample.at.Add(-10 * time.Second)
+		tracker.samples[name] = sample
+	}
+	ifaces := []inform.Interface{{Name: "eth0", RxBytes: 3000}, {Name: "ppp0", RxBytes: 100}}
+	tracker.ApplyAll(ifaces)
+	if ifaces[1].RxBytesRate != 0 {
+		t.Errorf("recreated interface rate = %g, want 0", ifaces[1].RxBytesRate)
+	}
+	if ifaces[0].RxBytesRate == 0 {
+		t.Error("eth0 rate lost")
+	}
+}
@@ -0,0 +1,222 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"fmt"
+	"github.com/COSAE-FR/ripugw/inform"
+	"github.com/COSAE-FR/ripugw/pfconf"
+	"net"
+	"sort"
+)
+
+// Route flags, in the netstat -r fashion
+const (
+	RouteFlagUp      = "U"
+	RouteFlagGateway = "G"
+	RouteFlagHost    = "H"
+	RouteFlagStatic  = "S"
+)
+
+// Route is an entry of the system routing table.
+type Route struct {
+	Destination *net.IPNet
+	Gateway     net.IP
+	Interface   string
+	Metric      uint32
+	Flags       []string
+	// Static routes are set by the administrator, others by the kernel or
+	// by a DHCP client.
+	Static bool
+}
+
+// Routes returns the main routing table of the system.
+func Routes() ([]Route, error) {
+	return getRoutes()
+}
+
+func (r Route) IsDefault() bool {
+	if r.Destination == nil {
+		return false
+	}
+	ones, _ := r.Destination.Mask.Size()
+	return ones == 0
+}
+
+func (r Route) IsIpv6() bool {
+	return r.Destination != nil && r.Destination.IP.To4() == nil
+}
+
+func (r Route) HasFlag(flag string) bool {
+	for _, f := range r.Flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+func interfaceName(index int) string {
+	iface, err := net.InterfaceByIndex(index)
+	if err != nil {
+		return ""
+	}
+	return iface.Name
+}
+
+// DefaultRoutes returns the default routes of a family, lowest metric first.
+func DefaultRoutes(routes []Route, ipv6 bool) []Route {
+	var defaults []Route
+	for _, route := range routes {
+		if route.IsDefault() && route.IsIpv6() == ipv6 && route.Gateway != nil {
+			defaults = append(defaults, route)
+		}
+	}
+	sort.SliceStable(defaults, func(i, j int) bool {
+		return defaults[i].Metric < defaults[j].Metric
+	})
+	return defaults
+}
+
+// InterfaceGateway returns the default gateway reached through iface.
+func InterfaceGateway(routes []Route, iface string, ipv6 bool) net.IP {
+	for _, route := range DefaultRoutes(routes, ipv6) {
+		if route.Interface == iface {
+			return route.Gateway
+		}
+	}
+	return nil
+}
+
+// getGateway6 reads the IPv6 default gateway from the kernel route table.
+func getGateway6() (net.IP, error) {
+	routes, err := getRoutes()
+	if err != nil {
+		return nil, err
+	}
+	if defaults := DefaultRoutes(routes, true); len(defaults) > 0 {
+		return defaults[0].Gateway, nil
+	}
+	return nil, fmt.Errorf("cannot find IPv6 gateway")
+}
+
+func routeFlags(gateway net.IP, destination *net.IPNet, static bool) []string {
+	flags := []string{RouteFlagUp}
+	if gateway != nil {
+		flags = append(flags, RouteFlagGateway)
+	}
+	if ones, bits := destination.Mask.Size(); ones == bits {
+		flags = append(flags, RouteFlagHost)
+	}
+	if static {
+		flags = append(flags, RouteFlagStatic)
+	}
+	return flags
+}
+
+func routeType(route Route) string {
+	switch {
+	case route.Static:
+		return "S"
+	case route.Gateway == nil:
+		return "C"
+	}
+	return "K"
+}
+
+// RouteTable formats routes for the inform, grouping next hops by prefix.
+func RouteTable(routes []Route) []inform.Route {
+	var table []inform.Route
+	index := make(map[string]int)
+	for _, route := range routes {
+		if route.Destination == nil {
+			continue
+		}
+		prefix := route.Destination.String()
+		hop := inform.RouteNextHop{
+			Interface: route.Interface,
+			Metric:    fmt.Sprintf("%d/%d", routeDistance(route), route.Metric),
+			Type:      routeType(route),
+		}
+		if route.Gateway != nil {
+			hop.Via = route.Gateway.String()
+		}
+		i, ok := index[prefix]
+		if !ok {
+			hop.Type += ">*"
+			index[prefix] = len(table)
+			table = append(table, inform.Route{Prefix: prefix})
+			i = len(table) - 1
+		} else {
+			hop.Type += "*"
+		}
+		table[i].NextHops = append(table[i].NextHops, hop)
+	}
+	return table
+}
+
+// routeDistance is the administrative distance of the zebra route types.
+func routeDistance(route Route) int {
+	if route.Static {
+		return 1
+	}
+	return 0
+}
+
+// pfSenseStaticRoutes resolves the static routes of the pfSense
+// configuration. physical maps the pfSense interface names to the system
+// ones.
+func pfSenseStaticRoutes(pfsense pfconf.Configuration, physical map[string]string) []Route {
+	var routes []Route
+	for _, static := range pfsense.Routes {
+		_, destination, err := net.ParseCIDR(static.Network)
+		if err != nil {
+			continue
+		}
+		route := Route{Destination: destination, Static: true}
+		for _, gateway := range pfsense.Gateways {
+			if gateway.Name != static.Gateway {
+				continue
+			}
+			route.Gateway = net.ParseIP(gateway.Gateway)
+			route.Interface = physical[gateway.Interface]
+			if len(route.Interface) == 0 {
+				route.Interface = gateway.Interface
+			}
+		}
+		route.Flags = routeFlags(route.Gateway, destination, true)
+		routes = append(routes, route)
+	}
+	return routes
+}
+
+// mergeStaticRoutes marks the system routes matching a static route and
+// appends the static routes missing from the system table.
+func mergeStaticRoutes(routes []Route, statics []Route) []Route {
+	for _, static := range statics {
+		found := false
+		for i := range routes {
+			if routes[i].Destination.String() != static.Destination.String() {
+				continue
+			}
+			if static.Gateway != nil && !static.Gateway.Equal(routes[i].Gateway) {
+				continue
+			}
+			found = true
+			if !routes[i].Static {
+				routes[i].Static = true
+				routes[i].Flags = append(routes[i].Flags, RouteFlagStatic)
+			}
+		}
+		if !found {
+			routes = append(routes, static)
+		}
+	}
+	return routes
+}
@@ -0,0 +1,84 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"golang.org/x/net/route"
+	"net"
+	"syscall"
+)
+
+func inetAddr(addr route.Addr) net.IP {
+	switch a := addr.(type) {
+	case *route.Inet4Addr:
+		return net.IPv4(a.IP[0], a.IP[1], a.IP[2], a.IP[3]).To4()
+	case *route.Inet6Addr:
+		ip := make(net.IP, net.IPv6len)
+		copy(ip, a.IP[:])
+		return ip
+	}
+	return nil
+}
+
+// getRoutes reads the routing table from the kernel RIB.
+func getRoutes() ([]Route, error) {
+	rib, err := route.FetchRIB(syscall.AF_UNSPEC, route.RIBTypeRoute, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := route.ParseRIB(route.RIBTypeRoute, rib)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[int]string)
+	var routes []Route
+	for _, message := range messages {
+		routeMessage, ok := message.(*route.RouteMessage)
+		if !ok || len(routeMessage.Addrs) <= syscall.RTAX_NETMASK {
+			continue
+		}
+		if routeMessage.Flags&syscall.RTF_UP == 0 {
+			continue
+		}
+		destination := inetAddr(routeMessage.Addrs[syscall.RTAX_DST])
+		if destination == nil {
+			continue
+		}
+		bits := 8 * len(destination)
+		mask := net.CIDRMask(bits, bits)
+		if routeMessage.Flags&syscall.RTF_HOST == 0 {
+			if netmask := inetAddr(routeMessage.Addrs[syscall.RTAX_NETMASK]); netmask != nil {
+				if len(netmask) == net.IPv6len && bits == 8*net.IPv4len {
+					netmask = netmask.To4()
+				}
+				mask = net.IPMask(netmask)
+			} else {
+				mask = net.CIDRMask(0, bits)
+			}
+		}
+		entry := Route{
+			Destination: &net.IPNet{IP: destination.Mask(mask), Mask: mask},
+			Static:      routeMessage.Flags&syscall.RTF_STATIC != 0,
+		}
+		if routeMessage.Flags&syscall.RTF_GATEWAY != 0 {
+			entry.Gateway = inetAddr(routeMessage.Addrs[syscall.RTAX_GATEWAY])
+		}
+		name, ok := names[routeMessage.Index]
+		if !ok {
+			name = interfaceName(routeMessage.Index)
+			names[routeMessage.Index] = name
+		}
+		entry.Interface = name
+		entry.Flags = routeFlags(entry.Gateway, entry.Destination, entry.Static)
+		routes = append(routes, entry)
+	}
+	return routes, nil
+}
@@ -0,0 +1,160 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"net"
+	"syscall"
+	"unsafe"
+)
+
+// Route protocols, from linux/rtnetlink.h
+const (
+	rtprotKernel = 2
+	rtprotBoot   = 3
+	rtprotStatic = 4
+)
+
+// Size of struct rtnexthop, from linux/rtnetlink.h
+const sizeofRtNexthop = 8
+
+// getRoutes dumps the main routing table through netlink.
+func getRoutes() ([]Route, error) {
+	rib, err := syscall.NetlinkRIB(syscall.RTM_GETROUTE, syscall.AF_UNSPEC)
+	if err != nil {
+		return nil, err
+	}
+	return parseRouteDump(rib, make(map[int]string))
+}
+
+// parseRouteDump decodes a netlink route dump. names caches the interface
+// names by index.
+func parseRouteDump(rib []byte, names map[int]string) ([]Route, error) {
+	messages, err := syscall.ParseNetlinkMessage(rib)
+	if err != nil {
+		return nil, err
+	}
+	var routes []Route
+	for i := range messages {
+		routes = append(routes, parseRouteMessage(&messages[i], names)...)
+	}
+	return routes, nil
+}
+
+// parseRouteMessage decodes a route of the main table. A multipath route
+// gives one route per next hop.
+func parseRouteMessage(message *syscall.NetlinkMessage, names map[int]string) []Route {
+	if message.Header.Type != syscall.RTM_NEWROUTE || len(message.Data) < syscall.SizeofRtMsg {
+		return nil
+	}
+	info := (*syscall.RtMsg)(unsafe.Pointer(&message.Data[0]))
+	if info.Type != syscall.RTN_UNICAST {
+		return nil
+	}
+	attributes, err := syscall.ParseNetlinkRouteAttr(message)
+	if err != nil {
+		return nil
+	}
+	bits := 8 * net.IPv4len
+	if info.Family == syscall.AF_INET6 {
+		bits = 8 * net.IPv6len
+	}
+	route := Route{
+		Destination: &net.IPNet{IP: make(net.IP, bits/8), Mask: net.CIDRMask(int(info.Dst_len), bits)},
+		Static:      info.Protocol == rtprotStatic || info.Protocol == rtprotBoot,
+	}
+	table := uint32(info.Table)
+	var multipath []byte
+	for _, attribute := range attributes {
+		switch attribute.Attr.Type {
+		case syscall.RTA_DST:
+			route.Destination.IP = net.IP(attribute.Value)
+		case syscall.RTA_GATEWAY:
+			route.Gateway = net.IP(attribute.Value)
+		case syscall.RTA_OIF:
+			if len(attribute.Value) >= 4 {
+				route.Interface = cachedInterfaceName(int(nativeEndian.Uint32(attribute.Value)), names)
+			}
+		case syscall.RTA_PRIORITY:
+			if len(attribute.Value) >= 4 {
+				route.Metric = nativeEndian.Uint32(attribute.Value)
+			}
+		case syscall.RTA_TABLE:
+			if len(attribute.Value) >= 4 {
+				table = nativeEndian.Uint32(attribute.Value)
+			}
+		case syscall.RTA_MULTIPATH:
+			multipath = attribute.Value
+		}
+	}
+	if table != rtTableMain {
+		return nil
+	}
+	// Kernel routes of the addresses prefixes
+	if info.Protocol == rtprotKernel {
+		route.Gateway = nil
+	}
+	routes := []Route{route}
+	if multipath != nil {
+		routes = parseNexthops(route, multipath, names)
+	}
+	for i := range routes {
+		routes[i].Flags = routeFlags(routes[i].Gateway, routes[i].Destination, routes[i].Static)
+	}
+	return routes
+}
+
+// parseNexthops decodes the rtnexthop entries of a RTA_MULTIPATH
+// attribute into copies of route.
+func parseNexthops(route Route, data []byte, names map[int]string) []Route {
+	var routes []Route
+	for len(data) >= sizeofRtNexthop {
+		length := int(nativeEndian.Uint16(data[0:2]))
+		if length < sizeofRtNexthop || length > len(data) {
+			break
+		}
+		hop := route
+		hop.Interface = cachedInterfaceName(int(int32(nativeEndian.Uint32(data[4:8]))), names)
+		hop.Gateway = nil
+		attributes := data[sizeofRtNexthop:length]
+		for len(attributes) >= syscall.SizeofRtAttr {
+			attributeLength := int(nativeEndian.Uint16(attributes[0:2]))
+			if attributeLength < syscall.SizeofRtAttr || attributeLength > len(attributes) {
+				break
+			}
+			if nativeEndian.Uint16(attributes[2:4]) == syscall.RTA_GATEWAY {
+				hop.Gateway = net.IP(attributes[syscall.SizeofRtAttr:attributeLength])
+			}
+			if rtaAlign(attributeLength) >= len(attributes) {
+				break
+			}
+			attributes = attributes[rtaAlign(attributeLength):]
+		}
+		routes = append(routes, hop)
+		if rtaAlign(length) >= len(data) {
+			break
+		}
+		data = data[rtaAlign(length):]
+	}
+	return routes
+}
+
+// rtaAlign rounds length up to the netlink attribute alignment.
+func rtaAlign(length int) int {
+	return (length + syscall.RTA_ALIGNTO - 1) &^ (syscall.RTA_ALIGNTO - 1)
+}
+
+func cachedInterfaceName(index int, names map[int]string) string {
+	name, ok := names[index]
+	if !ok {
+		name = interfaceName(index)
+		names[index] = name
+	}
+	return name
+}
@@ -0,0 +1,152 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"net"
+	"syscall"
+	"testing"
+	"unsafe"
+)
+
+type routeFixture struct {
+	family, protocol, routeType uint8
+	table                       uint8
+	dstLen                      uint8
+	attributes                  [][]byte
+}
+
+func nativeUint32(value uint32) []byte {
+	b := make([]byte, 4)
+	nativeEndian.PutUint32(b, value)
+	return b
+}
+
+// nexthop encodes a struct rtnexthop followed by its gateway attribute,
+// if any.
+func nexthop(index int, gateway net.IP) []byte {
+	var attribute []byte
+	if gateway != nil {
+		attribute = netlinkAttribute(syscall.RTA_GATEWAY, gateway)
+	}
+	b := make([]byte, sizeofRtNexthop)
+	nativeEndian.PutUint16(b[0:2], uint16(sizeofRtNexthop+len(attribute)))
+	nativeEndian.PutUint32(b[4:8], uint32(index))
+	return append(b, attribute...)
+}
+
+// routeDump encodes route messages like a RTM_GETROUTE dump.
+func routeDump(fixtures []routeFixture) []byte {
+	var dump []byte
+	for _, fixture := range fixtures {
+		data := make([]byte, syscall.SizeofRtMsg)
+		info := (*syscall.RtMsg)(unsafe.Pointer(&data[0]))
+		info.Family = fixture.family
+		info.Protocol = fixture.protocol
+		info.Type = fixture.routeType
+		info.Table = fixture.table
+		info.Dst_len = fixture.dstLen
+		for _, attribute := range fixture.attributes {
+			data = append(data, attribute...)
+		}
+		header := make([]byte, syscall.NLMSG_HDRLEN)
+		h := (*syscall.NlMsghdr)(unsafe.Pointer(&header[0]))
+		h.Len = uint32(syscall.NLMSG_HDRLEN + len(data))
+		h.Type = syscall.RTM_NEWROUTE
+		dump = append(dump, header...)
+		dump = append(dump, data...)
+	}
+	done := make([]byte, syscall.NLMSG_HDRLEN+4)
+	h := (*syscall.NlMsghdr)(unsafe.Pointer(&done[0]))
+	h.Len = uint32(len(done))
+	h.Type = syscall.NLMSG_DONE
+	return append(dump, done...)
+}
+
+func TestParseRouteDump(t *testing.T) {
+	multipath := append(nexthop(2, net.IP{203, 0, 113, 1}), nexthop(3, net.IP{198, 51, 100, 1})...)
+	dump := routeDump([]routeFixture{
+		// default proto static nexthop via 203.0.113.1 dev eth0 nexthop via 198.51.100.1 dev eth2
+		{syscall.AF_INET, rtprotStatic, syscall.RTN_UNICAST, rtTableMain, 0, [][]byte{
+			netlinkAttribute(syscall.RTA_MULTIPATH, multipath),
+		}},
+		// 203.0.113.0/24 dev eth0 proto kernel
+		{syscall.AF_INET, rtprotKernel, syscall.RTN_UNICAST, rtTableMain, 24, [][]byte{
+			netlinkAttribute(syscall.RTA_DST, []byte{203, 0, 113, 0}),
+			netlinkAttribute(syscall.RTA_PREFSRC, []byte{203, 0, 113, 10}),
+			netlinkAttribute(syscall.RTA_OIF, nativeUint32(2)),
+		}},
+		// local table and local routes are skipped
+		{syscall.AF_INET, rtprotKernel, syscall.RTN_LOCAL, 255, 32, [][]byte{
+			netlinkAttribute(syscall.RTA_DST, []byte{203, 0, 113, 10}),
+			netlinkAttribute(syscall.RTA_OIF, nativeUint32(2)),
+		}},
+		{syscall.AF_INET, rtprotStatic, syscall.RTN_UNICAST, 100, 0, [][]byte{
+			netlinkAttribute(syscall.RTA_GATEWAY, []byte{192, 0, 2, 1}),
+			netlinkAttribute(syscall.RTA_OIF, nativeUint32(3)),
+		}},
+		// default via fe80::1 dev eth0 proto ra metric 1024
+		{syscall.AF_INET6, 9, syscall.RTN_UNICAST, rtTableMain, 0, [][]byte{
+			netlinkAttribute(syscall.RTA_GATEWAY, net.ParseIP("fe80::1")),
+			netlinkAttribute(syscall.RTA_OIF, nativeUint32(2)),
+			netlinkAttribute(syscall.RTA_PRIORITY, nativeUint32(1024)),
+		}},
+		// default proto static metric 10 nexthop via fe80::2 dev eth2 nexthop dev eth3
+		{syscall.AF_INET6, rtprotStatic, syscall.RTN_UNICAST, rtTableMain, 0, [][]byte{
+			netlinkAttribute(syscall.RTA_PRIORITY, nativeUint32(10)),
+			netlinkAttribute(syscall.RTA_MULTIPATH, append(nexthop(3, net.ParseIP("fe80::2")), nexthop(4, nil)...)),
+		}},
+	})
+	routes, err := parseRouteDump(dump, map[int]string{2: "eth0", 3: "eth2", 4: "eth3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 6 {
+		t.Fatalf("routes = %+v, want 6", routes)
+	}
+	for i, want := range []struct {
+		destination string
+		gateway     string
+		iface       string
+		metric      uint32
+		static      bool
+	}{
+		{"0.0.0.0/0", "203.0.113.1", "eth0", 0, true},
+		{"0.0.0.0/0", "198.51.100.1", "eth2", 0, true},
+		{"203.0.113.0/24", "", "eth0", 0, false},
+		{"::/0", "fe80::1", "eth0", 1024, false},
+		{"::/0", "fe80::2", "eth2", 10, true},
+		{"::/0", "", "eth3", 10, true},
+	} {
+		route := routes[i]
+		if route.Destination.String() != want.destination || route.Interface != want.iface || route.Metric != want.metric || route.Static != want.static {
+			t.Errorf("route %d = %+v, want %+v", i, route, want)
+		}
+		if (len(want.gateway) == 0 && route.Gateway != nil) || (len(want.gateway) > 0 && !route.Gateway.Equal(net.ParseIP(want.gateway))) {
+			t.Errorf("route %d gateway = %v, want %q", i, route.Gateway, want.gateway)
+		}
+	}
+	if gateway := InterfaceGateway(routes, "eth2", false); !gateway.Equal(net.IP{198, 51, 100, 1}) {
+		t.Errorf("eth2 gateway = %v, want the multipath next hop", gateway)
+	}
+	if defaults := DefaultRoutes(routes, true); len(defaults) != 2 || defaults[0].Interface != "eth2" {
+		t.Errorf("IPv6 defaults = %+v", defaults)
+	}
+}
+
+func TestParseNexthopsTruncated(t *testing.T) {
+	route := Route{Destination: &net.IPNet{IP: net.IPv4zero.To4(), Mask: net.CIDRMask(0, 32)}}
+	hop := nexthop(2, net.IP{203, 0, 113, 1})
+	for _, data := range [][]byte{hop[:4], hop[:len(hop)-2], append(hop, 0xff, 0xff, 0, 0)} {
+		_ = parseNexthops(route, data, map[int]string{2: "eth0"})
+	}
+	if hops := parseNexthops(route, append(hop, nexthop(2, nil)[:4]...), map[int]string{2: "eth0"}); len(hops) != 1 {
+		t.Errorf("next hops = %+v, want 1", hops)
+	}
+}
@@ -0,0 +1,138 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/COSAE-FR/ripugw/inform"
+)
+
+func mustCIDR(t *testing.T, cidr string) *net.IPNet {
+	t.Helper()
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return network
+}
+
+func testRoutes(t *testing.T) []Route {
+	return []Route{
+		{Destination: mustCIDR(t, "0.0.0.0/0"), Gateway: net.ParseIP("198.51.100.1"), Interface: "eth2", Metric: 200},
+		{Destination: mustCIDR(t, "0.0.0.0/0"), Gateway: net.ParseIP("203.0.113.1"), Interface: "eth0", Metric: 100},
+		{Destination: mustCIDR(t, "203.0.113.0/24"), Interface: "eth0"},
+		{Destination: mustCIDR(t, "::/0"), Gateway: net.ParseIP("fe80::1"), Interface: "eth0", Metric: 1024},
+		{Destination: mustCIDR(t, "::/0"), Interface: "eth1"},
+		{Destination: mustCIDR(t, "10.10.0.0/16"), Gateway: net.ParseIP("192.168.10.254"), Interface: "em1.10", Static: true},
+	}
+}
+
+func TestDefaultRoutes(t *testing.T) {
+	routes := testRoutes(t)
+	defaults := DefaultRoutes(routes, false)
+	if len(defaults) != 2 || defaults[0].Interface != "eth0" || defaults[1].Interface != "eth2" {
+		t.Errorf("IPv4 defaults = %+v, want eth0 then eth2", defaults)
+	}
+	if defaults6 := DefaultRoutes(routes, true); len(defaults6) != 1 || !defaults6[0].Gateway.Equal(net.ParseIP("fe80::1")) {
+		t.Errorf("IPv6 defaults = %+v, want the fe80::1 one", defaults6)
+	}
+	for _, test := range []struct {
+		iface   string
+		ipv6    bool
+		gateway string
+	}{
+		{"eth0", false, "203.0.113.1"},
+		{"eth2", false, "198.51.100.1"},
+		{"eth0", true, "fe80::1"},
+		{"eth1", true, ""},
+		{"em1.10", false, ""},
+	} {
+		gateway := InterfaceGateway(routes, test.iface, test.ipv6)
+		if (gateway == nil && len(test.gateway) > 0) || (gateway != nil && !gateway.Equal(net.ParseIP(test.gateway))) {
+			t.Errorf("InterfaceGateway(%s, %v) = %v, want %q", test.iface, test.ipv6, gateway, test.gateway)
+		}
+	}
+}
+
+func TestRouteTable(t *testing.T) {
+	routes := testRoutes(t)
+	for i := range routes {
+		routes[i].Flags = routeFlags(routes[i].Gateway, routes[i].Destination, routes[i].Static)
+	}
+	want := []inform.Route{
+		{Prefix: "0.0.0.0/0", NextHops: []inform.RouteNextHop{
+			{Interface: "eth2", Metric: "0/200", Type: "K>*", Via: "198.51.100.1"},
+			{Interface: "eth0", Metric: "0/100", Type: "K*", Via: "203.0.113.1"},
+		}},
+		{Prefix: "203.0.113.0/24", NextHops: []inform.RouteNextHop{{Interface: "eth0", Metric: "0/0", Type: "C>*"}}},
+		{Prefix: "::/0", NextHops: []inform.RouteNextHop{
+			{Interface: "eth0", Metric: "0/1024", Type: "K>*", Via: "fe80::1"},
+			{Interface: "eth1", Metric: "0/0", Type: "C*"},
+		}},
+		{Prefix: "10.10.0.0/16", NextHops: []inform.RouteNextHop{{Interface: "em1.10", Metric: "1/0", Type: "S>*", Via: "192.168.10.254"}}},
+	}
+	if table := RouteTable(routes); !reflect.DeepEqual(table, want) {
+		t.Errorf("RouteTable = %+v, want %+v", table, want)
+	}
+	if flags := routes[5].Flags; !reflect.DeepEqual(flags, []string{RouteFlagUp, RouteFlagGateway, RouteFlagStatic}) {
+		t.Errorf("static route flags = %v", flags)
+	}
+}
+
+func TestPfSenseStaticRoutes(t *testing.T) {
+	pfsense := loadPfSenseFixture(t, "config.xml")
+	statics := pfSenseStaticRoutes(pfsense, map[string]string{"opt1": "em1.10"})
+	if len(statics) != 2 {
+		t.Fatalf("static routes = %+v, want 2", statics)
+	}
+	lab := statics[0]
+	if lab.Destination.String() != "10.10.0.0/16" || !lab.Gateway.Equal(net.ParseIP("192.168.10.254")) || lab.Interface != "em1.10" || !lab.Static {
+		t.Errorf("lab route = %+v", lab)
+	}
+	if !reflect.DeepEqual(lab.Flags, []string{RouteFlagUp, RouteFlagGateway, RouteFlagStatic}) {
+		t.Errorf("lab route flags = %v", lab.Flags)
+	}
+	// A route through a removed gateway keeps its prefix only
+	if removed := statics[1]; removed.Destination.String() != "10.20.0.0/16" || removed.Gateway != nil || removed.Interface != "" {
+		t.Errorf("route through a removed gateway = %+v", removed)
+	}
+	if unmapped := pfSenseStaticRoutes(pfsense, nil); unmapped[0].Interface != "opt1" {
+		t.Errorf("interface without physical mapping = %q, want opt1", unmapped[0].Interface)
+	}
+}
+
+func TestMergeStaticRoutes(t *testing.T) {
+	pfsense := loadPfSenseFixture(t, "config.xml")
+	statics := pfSenseStaticRoutes(pfsense, map[string]string{"opt1": "em1.10"})
+	system := []Route{
+		{Destination: mustCIDR(t, "0.0.0.0/0"), Gateway: net.ParseIP("203.0.113.1"), Interface: "em0", Flags: []string{RouteFlagUp, RouteFlagGateway}},
+		{Destination: mustCIDR(t, "10.10.0.0/16"), Gateway: net.ParseIP("192.168.10.254"), Interface: "em1.10", Flags: []string{RouteFlagUp, RouteFlagGateway}},
+		{Destination: mustCIDR(t, "10.10.0.0/16"), Gateway: net.ParseIP("192.168.10.253"), Interface: "em1.10", Flags: []string{RouteFlagUp, RouteFlagGateway}},
+	}
+	merged := mergeStaticRoutes(system, statics)
+	if len(merged) != 4 {
+		t.Fatalf("merged routes = %+v, want 4", merged)
+	}
+	if merged[0].Static || merged[2].Static {
+		t.Error("route not matching a static route marked static")
+	}
+	if !merged[1].Static || !reflect.DeepEqual(merged[1].Flags, []string{RouteFlagUp, RouteFlagGateway, RouteFlagStatic}) {
+		t.Errorf("matching system route = %+v", merged[1])
+	}
+	if merged[3].Destination.String() != "10.20.0.0/16" || !merged[3].Static {
+		t.Errorf("missing static route not appended: %+v", merged[3])
+	}
+	// Merging again does not duplicate the static flag
+	if again := mergeStaticRoutes(merged, statics); len(again) != 4 || len(again[1].Flags) != 3 {
+		t.Errorf("second merge = %+v", again)
+	}
+}
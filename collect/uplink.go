@@ -0,0 +1,558 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/COSAE-FR/ripugw/inform"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+const (
+	ProbeIcmp = "icmp"
+	ProbeTcp  = "tcp"
+	ProbeHttp = "http"
+
+	defaultProbeInterval = 10 * time.Second
+	defaultProbeWindow   = 30
+
+	// uplinkIdleTimeout is the minimal time a monitor is kept without being
+	// asked for its statistics.
+	uplinkIdleTimeout = 10 * time.Minute
+)
+
+var DefaultProbeTargets = []string{"icmp:1.1.1.1", "icmp:8.8.8.8"}
+
+var icmpSequence uint32
+
+type ProbeTarget struct {
+	Method  string `json:"type"`
+	Address string `json:"target"`
+}
+
+// ParseProbeTarget parses "icmp:host", "tcp:host:port" or an http(s) URL.
+func ParseProbeTarget(target string) (ProbeTarget, error) {
+	switch {
+	case strings.HasPrefix(target, "http://"), strings.HasPrefix(target, "https://"):
+		return ProbeTarget{Method: ProbeHttp, Address: target}, nil
+	case strings.HasPrefix(target, "icmp:"):
+		return ProbeTarget{Method: ProbeIcmp, Address: strings.TrimPrefix(target, "icmp:")}, nil
+	case strings.HasPrefix(target, "tcp:"):
+		address := strings.TrimPrefix(target, "tcp:")
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			return ProbeTarget{}, fmt.Errorf("invalid TCP probe target %s: %v", target, err)
+		}
+		return ProbeTarget{Method: ProbeTcp, Address: address}, nil
+	}
+	return ProbeTarget{}, fmt.Errorf("unknown probe target %s", target)
+}
+
+func (t ProbeTarget) String() string {
+	if t.Method == ProbeHttp {
+		return t.Address
+	}
+	return t.Method + ":" + t.Address
+}
+
+// Probe measures the round trip time to target, sending from source when
+// it is not nil and through device when it is not empty.
+func Probe(target ProbeTarget, source net.IP, device string, timeout time.Duration) (time.Duration, error) {
+	switch target.Method {
+	case ProbeIcmp:
+		return probeIcmp(target.Address, source, device, timeout)
+	case ProbeTcp:
+		return probeTcp(target.Address, source, device, timeout)
+	case ProbeHttp:
+		return probeHttp(target.Address, source, device, timeout)
+	}
+	return 0, fmt.Errorf("unknown probe type %s", target.Method)
+}
+
+// deviceControl returns a socket control function binding the socket to
+// device, or nil when device is empty.
+func deviceControl(device string) func(network, address string, c syscall.RawConn) error {
+	if len(device) == 0 {
+		return nil
+	}
+	return func(network, address string, c syscall.RawConn) error {
+		var err error
+		if cerr := c.Control(func(fd uintptr) {
+			err = bindToDevice(fd, device)
+		}); cerr != nil {
+			return cerr
+		}
+		return err
+	}
+}
+
+// listenPing opens an unprivileged ICMP datagram socket, like
+// icmp.ListenPacket("udp4", ...), bound to source and device.
+func listenPing(source net.IP, device string) (net.PacketConn, error) {
+	s, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM, syscall.IPPROTO_ICMP)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	if len(device) > 0 {
+		if err := bindToDevice(uintptr(s), device); err != nil {
+			_ = syscall.Close(s)
+			return nil, err
+		}
+	}
+	address := &syscall.SockaddrInet4{}
+	if source != nil {
+		copy(address.Addr[:], source.To4())
+	}
+	if err := syscall.Bind(s, address); err != nil {
+		_ = syscall.Close(s)
+		return nil, os.NewSyscallError("bind", err)
+	}
+	f := os.NewFile(uintptr(s), "ping")
+	defer f.Close()
+	return net.FilePacketConn(f)
+}
+
+func probeIcmp(address string, source net.IP, device string, timeout time.Duration) (time.Duration, error) {
+	destination, err := net.ResolveIPAddr("ip4", address)
+	if err != nil {
+		return 0, err
+	}
+	// Unprivileged ping sockets first, raw sockets when running as root
+	var peer net.Addr = &net.UDPAddr{IP: destination.IP}
+	privileged := false
+	conn, err := listenPing(source, device)
+	if err != nil {
+		listen := "0.0.0.0"
+		if source != nil {
+			listen = source.String()
+		}
+		config := net.ListenConfig{Control: deviceControl(device)}
+		conn, err = config.ListenPacket(context.Background(), "ip4:icmp", listen)
+		if err != nil {
+			return 0, err
+		}
+		peer = destination
+		privileged = true
+	}
+	defer conn.Close()
+
+	id := os.Getpid() & 0xffff
+	seq := int(atomic.AddUint32(&icmpSequence, 1) & 0xffff)
+	request := icmp.Message{
+		Type: ipv4.ICMPTypeEcho,
+		Body: &icmp.Echo{ID: id, Seq: seq, Data: []byte("ripugw")},
+	}
+	b, err := request.Marshal(nil)
+	if err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	if err := conn.SetDeadline(start.Add(timeout)); err != nil {
+		return 0, err
+	}
+	if _, err := conn.WriteTo(b, peer); err != nil {
+		return 0, err
+	}
+	buffer := make([]byte, 1500)
+	for {
+		n, _, err := conn.ReadFrom(buffer)
+		if err != nil {
+			return 0, err
+		}
+		reply, err := icmp.ParseMessage(1, buffer[:n])
+		if err != nil || reply.Type != ipv4.ICMPTypeEchoReply {
+			continue
+		}
+		echo, ok := reply.Body.(*icmp.Echo)
+		if !ok || echo.Seq != seq || (privileged && echo.ID != id) {
+			continue
+		}
+		return time.Since(start), nil
+	}
+}
+
+func sourceDialer(source net.IP, device string, timeout time.Duration) *net.Dialer {
+	dialer := &net.Dialer{Timeout: timeout, Control: deviceControl(device)}
+	if source != nil {
+		dialer.LocalAddr = &net.TCPAddr{IP: source}
+	}
+	return dialer
+}
+
+func probeTcp(address string, source net.IP, device string, timeout time.Duration) (time.Duration, error) {
+	start := time.Now()
+	conn, err := sourceDialer(source, device, timeout).Dial("tcp4", address)
+	if err != nil {
+		return 0, err
+	}
+	rtt := time.Since(start)
+	_ = conn.Close()
+	return rtt, nil
+}
+
+func probeHttp(address string, source net.IP, device string, timeout time.Duration) (time.Duration, error) {
+	dialer := sourceDialer(source, device, timeout)
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DisableKeepAlives: true,
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return dialer.DialContext(ctx, "tcp4", addr)
+			},
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	start := time.Now()
+	resp, err := client.Head(address)
+	if err != nil {
+		return 0, err
+	}
+	rtt := time.Since(start)
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return rtt, fmt.Errorf("HTTP status %d", resp.StatusCode)
+	}
+	return rtt, nil
+}
+
+type probeSample struct {
+	rtt time.Duration
+	ok  bool
+}
+
+type TargetStats struct {
+	Target       ProbeTarget `json:"target"`
+	Latency      float64     `json:"latency_average"`
+	Jitter       float64     `json:"jitter_average"`
+	Availability float64     `json:"availability"`
+}
+
+// UplinkStats summarizes the probes of a WAN over the monitor window.
+type UplinkStats struct {
+	Up           bool          `json:"up"`
+	Latency      time.Duration `json:"-"`
+	Jitter       time.Duration `json:"-"`
+	Loss         float64       `json:"loss"`
+	Availability float64       `json:"availability"`
+	Samples      int           `json:"samples"`
+	Period       time.Duration `json:"-"`
+	Targets      []TargetStats `json:"monitors"`
+}
+
+// UplinkMonitor probes a set of targets from the address of a WAN interface.
+type UplinkMonitor struct {
+	Interface string
+	Targets   []ProbeTarget
+	Interval  time.Duration
+	Window    int
+
+	lock    sync.Mutex
+	samples map[string][]probeSample
+	rounds  []bool
+	stop    chan bool
+}
+
+func NewUplinkMonitor(iface string, targets []ProbeTarget, interval time.Duration, window int) *UplinkMonitor {
+	if interval <= 0 {
+		interval = defaultProbeInterval
+	}
+	if window <= 0 {
+		window = defaultProbeWindow
+	}
+	return &UplinkMonitor{
+		Interface: iface,
+		Targets:   targets,
+		Interval:  interval,
+		Window:    window,
+		samples:   make(map[string][]probeSample),
+	}
+}
+
+func (m *UplinkMonitor) Start() {
+	m.stop = make(chan bool)
+	go func() {
+		ticker := time.NewTicker(m.Interval)
+		defer ticker.Stop()
+		m.probe()
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-ticker.C:
+				m.probe()
+			}
+		}
+	}()
+}
+
+func (m *UplinkMonitor) Stop() {
+	if m.stop != nil {
+		close(m.stop)
+	}
+}
+
+func (m *UplinkMonitor) probe() {
+	var source net.IP
+	if len(m.Interface) > 0 {
+		address, err := GetIPForInterface(m.Interface)
+		if err == nil {
+			source = address.IP
+		}
+	}
+	timeout := m.Interval
+	if timeout > 5*time.Second {
+		timeout = 5 * time.Second
+	}
+	results := make([]probeSample, len(m.Targets))
+	var wg sync.WaitGroup
+	for i, target := range m.Targets {
+		wg.Add(1)
+		go func(i int, target ProbeTarget) {
+			defer wg.Done()
+			rtt, err := Probe(target, source, m.Interface, timeout)
+			results[i] = probeSample{rtt: rtt, ok: err == nil}
+		}(i, target)
+	}
+	wg.Wait()
+	m.record(results)
+}
+
+func (m *UplinkMonitor) record(results []probeSample) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	up := false
+	for i, result := range results {
+		key := m.Targets[i].String()
+		samples := append(m.samples[key], result)
+		if len(samples) > m.Window {
+			samples = samples[len(samples)-m.Window:]
+		}
+		m.samples[key] = samples
+		up = up || result.ok
+	}
+	m.rounds = append(m.rounds, up)
+	if len(m.rounds) > m.Window {
+		m.rounds = m.rounds[len(m.rounds)-m.Window:]
+	}
+}
+
+func (m *UplinkMonitor) Stats() (UplinkStats, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if len(m.rounds) == 0 {
+		return UplinkStats{}, errors.New("no probe yet")
+	}
+	stats := UplinkStats{
+		Up:      m.rounds[len(m.rounds)-1],
+		Samples: len(m.rounds),
+		Period:  time.Duration(len(m.rounds)) * m.Interval,
+	}
+	upRounds := 0
+	for _, up := range m.rounds {
+		if up {
+			upRounds++
+		}
+	}
+	stats.Availability = 100 * float64(upRounds) / float64(len(m.rounds))
+
+	var total, lost, received int
+	var rttSum, jitterSum time.Duration
+	var jitterCount int
+	for _, target := range m.Targets {
+		samples := m.samples[target.String()]
+		var targetRtt, targetJitter time.Duration
+		var targetOk, targetJitterCount int
+		var previous *probeSample
+		for i := range samples {
+			total++
+			if !samples[i].ok {
+				lost++
+				continue
+			}
+			received++
+			targetOk++
+			targetRtt += samples[i].rtt
+			if previous != nil {
+				delta := samples[i].rtt - previous.rtt
+				if delta < 0 {
+					delta = -delta
+				}
+				targetJitter += delta
+				targetJitterCount++
+			}
+			previous = &samples[i]
+		}
+		rttSum += targetRtt
+		jitterSum += targetJitter
+		jitterCount += targetJitterCount
+		targetStats := TargetStats{Target: target}
+		if len(samples) > 0 {
+			targetStats.Availability = 100 * float64(targetOk) / float64(len(samples))
+		}
+		if targetOk > 0 {
+			targetStats.Latency = float64(targetRtt/time.Duration(targetOk)) / float64(time.Millisecond)
+		}
+		if targetJitterCount > 0 {
+			targetStats.Jitter = float64(targetJitter/time.Duration(targetJitterCount)) / float64(time.Millisecond)
+		}
+		stats.Targets = append(stats.Targets, targetStats)
+	}
+	if total > 0 {
+		stats.Loss = 100 * float64(lost) / float64(total)
+	}
+	if received > 0 {
+		stats.Latency = rttSum / time.Duration(received)
+	}
+	if jitterCount > 0 {
+		stats.Jitter = jitterSum / time.Duration(jitterCount)
+	}
+	return stats, nil
+}
+
+// UplinkRegistry starts a monitor for each WAN interface it is asked about
+// and stops it when the interface disappears or is no longer a WAN.
+type UplinkRegistry struct {
+	Targets  []ProbeTarget
+	Interval time.Duration
+	Window   int
+
+	lock     sync.Mutex
+	monitors map[string]*UplinkMonitor
+	used     map[string]time.Time
+}
+
+func NewUplinkRegistry(targets []ProbeTarget, interval time.Duration, window int) *UplinkRegistry {
+	return &UplinkRegistry{
+		Targets:  targets,
+		Interval: interval,
+		Window:   window,
+		monitors: make(map[string]*UplinkMonitor),
+		used:     make(map[string]time.Time),
+	}
+}
+
+// Stats returns the statistics of the monitor of iface, starting it if needed.
+func (r *UplinkRegistry) Stats(iface string) (UplinkStats, error) {
+	if r == nil {
+		return UplinkStats{}, errors.New("no uplink monitor")
+	}
+	r.lock.Lock()
+	now := time.Now()
+	r.reap(now)
+	monitor, ok := r.monitors[iface]
+	if !ok {
+		monitor = NewUplinkMonitor(iface, r.Targets, r.Interval, r.Window)
+		monitor.Start()
+		r.monitors[iface] = monitor
+	}
+	r.used[iface] = now
+	r.lock.Unlock()
+	return monitor.Stats()
+}
+
+// reap stops the monitors of vanished interfaces and of interfaces not
+// asked about for a monitor window, and at least uplinkIdleTimeout.
+// The registry lock must be held.
+func (r *UplinkRegistry) reap(now time.Time) {
+	for iface, monitor := range r.monitors {
+		idle := time.Duration(monitor.Window) * monitor.Interval
+		if idle < uplinkIdleTimeout {
+			idle = uplinkIdleTimeout
+		}
+		if _, err := net.InterfaceByName(iface); err == nil && now.Sub(r.used[iface]) <= idle {
+			continue
+		}
+		monitor.Stop()
+		delete(r.monitors, iface)
+		delete(r.used, iface)
+	}
+}
+
+// All returns the statistics of every running monitor, by interface.
+func (r *UplinkRegistry) All() map[string]UplinkStats {
+	result := make(map[string]UplinkStats)
+	if r == nil {
+		return result
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.reap(time.Now())
+	for iface, monitor := range r.monitors {
+		if stats, err := monitor.Stats(); err == nil {
+			result[iface] = stats
+		}
+	}
+	return result
+}
+
+func (r *UplinkRegistry) Stop() {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for iface, monitor := range r.monitors {
+		monitor.Stop()
+		delete(r.monitors, iface)
+		delete(r.used, iface)
+	}
+}
+
+// applyUplink reports the monitor statistics of a WAN in its interface
+// entry and in the uptime statistics of the inform. It returns false when
+// no statistics are available yet.
+func applyUplink(request *inform.Inform, wan *inform.Interface, physical string, label string, uplinks *UplinkRegistry) bool {
+	if uplinks == nil || len(physical) == 0 {
+		return false
+	}
+	stats, err := uplinks.Stats(physical)
+	if err != nil {
+		return false
+	}
+	wan.Latency = uint64(stats.Latency / time.Millisecond)
+	if stats.Up && wan.Latency == 0 {
+		// The controller shows no Internet connection with a null latency
+		wan.Latency = 1
+	}
+	if !stats.Up {
+		wan.Latency = 0
+	}
+	stat := inform.UptimeStat{
+		Availability:   stats.Availability,
+		LatencyAverage: float64(stats.Latency) / float64(time.Millisecond),
+		JitterAverage:  float64(stats.Jitter) / float64(time.Millisecond),
+		TimePeriod:     uint64(stats.Period / time.Second),
+	}
+	for _, target := range stats.Targets {
+		stat.Monitors = append(stat.Monitors, inform.UptimeMonitor{
+			Availability:   target.Availability,
+			LatencyAverage: target.Latency,
+			JitterAverage:  target.Jitter,
+			Target:         target.Target.Address,
+			Type:           target.Target.Method,
+		})
+	}
+	if request.UptimeStats == nil {
+		request.UptimeStats = make(map[string]inform.UptimeStat)
+	}
+	request.UptimeStats[label] = stat
+	return true
+}
@@ -0,0 +1,17 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+// bindToDevice does nothing: FreeBSD has no SO_BINDTODEVICE. pfSense
+// routes the traffic sourced from a WAN address through the gateway of
+// that WAN with its route-to rules, so binding the probes to the WAN
+// address is enough there.
+func bindToDevice(fd uintptr, device string) error {
+	return nil
+}
@@ -0,0 +1,20 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"os"
+	"syscall"
+)
+
+// bindToDevice forces the packets of the socket fd out of device, whatever
+// the default route.
+func bindToDevice(fd uintptr, device string) error {
+	return os.NewSyscallError("setsockopt", syscall.SetsockoptString(int(fd), syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, device))
+}
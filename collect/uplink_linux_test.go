@@ -0,0 +1,45 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestProbeBindToDevice(t *testing.T) {
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+	target := ProbeTarget{Method: ProbeTcp, Address: listener.Addr().String()}
+
+	if _, err := Probe(target, nil, "lo", time.Second); err != nil {
+		if errors.Is(err, syscall.EPERM) {
+			t.Skip("binding to a device is not permitted")
+		}
+		t.Fatalf("probe through lo: %v", err)
+	}
+	if _, err := Probe(target, nil, "ripugw-gone0", time.Second); err == nil {
+		t.Error("probe through a missing device succeeded")
+	}
+}
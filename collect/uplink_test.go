@@ -0,0 +1,102 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"github.com/COSAE-FR/ripugw/inform"
+	"net"
+	"testing"
+	"time"
+)
+
+func loopbackName(t *testing.T) string {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			return iface.Name
+		}
+	}
+	t.Skip("no loopback interface")
+	return ""
+}
+
+// addMonitor registers a monitor that is not started, so the test controls
+// its samples.
+func addMonitor(r *UplinkRegistry, iface string, used time.Time) *UplinkMonitor {
+	monitor := NewUplinkMonitor(iface, r.Targets, r.Interval, r.Window)
+	r.monitors[iface] = monitor
+	r.used[iface] = used
+	return monitor
+}
+
+func TestUplinkRegistryReap(t *testing.T) {
+	loopback := loopbackName(t)
+	targets := []ProbeTarget{{Method: ProbeTcp, Address: "127.0.0.1:1"}}
+	registry := NewUplinkRegistry(targets, time.Second, 10)
+	now := time.Now()
+	addMonitor(registry, loopback, now)
+	addMonitor(registry, "ripugw-gone0", now)
+
+	registry.reap(now)
+	if _, ok := registry.monitors["ripugw-gone0"]; ok {
+		t.Error("monitor of a vanished interface kept")
+	}
+	if _, ok := registry.monitors[loopback]; !ok {
+		t.Fatal("monitor of an existing interface reaped")
+	}
+
+	registry.reap(now.Add(uplinkIdleTimeout))
+	if _, ok := registry.monitors[loopback]; !ok {
+		t.Fatal("monitor reaped before the idle timeout")
+	}
+	registry.reap(now.Add(uplinkIdleTimeout + time.Second))
+	if len(registry.monitors) != 0 || len(registry.used) != 0 {
+		t.Errorf("idle monitor kept: %v", registry.monitors)
+	}
+}
+
+func TestApplyUplink(t *testing.T) {
+	loopback := loopbackName(t)
+	targets := []ProbeTarget{{Method: ProbeIcmp, Address: "192.0.2.1"}, {Method: ProbeTcp, Address: "192.0.2.2:443"}}
+	registry := NewUplinkRegistry(targets, 10*time.Second, 10)
+	monitor := addMonitor(registry, loopback, time.Now())
+	ms := time.Millisecond
+	monitor.record([]probeSample{{rtt: 10 * ms, ok: true}, {rtt: 20 * ms, ok: true}})
+	monitor.record([]probeSample{{rtt: 14 * ms, ok: true}, {ok: false}})
+	monitor.record([]probeSample{{rtt: 12 * ms, ok: true}, {rtt: 30 * ms, ok: true}})
+
+	var request inform.Inform
+	var wan inform.Interface
+	if !applyUplink(&request, &wan, loopback, "WAN", registry) {
+		t.Fatal("no uplink statistics")
+	}
+	if wan.Latency != 17 {
+		t.Errorf("latency = %d, want 17", wan.Latency)
+	}
+	stat := request.UptimeStats["WAN"]
+	// (4 + 2 + 10) / 3 probe round trip variations
+	if stat.JitterAverage < 5.33 || stat.JitterAverage > 5.34 {
+		t.Errorf("jitter = %f, want 5.33", stat.JitterAverage)
+	}
+	if stat.Availability != 100 || stat.TimePeriod != 30 {
+		t.Errorf("stat = %+v", stat)
+	}
+	if len(stat.Monitors) != 2 {
+		t.Fatalf("monitors = %+v", stat.Monitors)
+	}
+	if icmp := stat.Monitors[0]; icmp.JitterAverage != 3 || icmp.LatencyAverage != 12 || icmp.Availability != 100 {
+		t.Errorf("icmp monitor = %+v", icmp)
+	}
+	if tcp := stat.Monitors[1]; tcp.JitterAverage != 10 || tcp.LatencyAverage != 25 || tcp.Type != ProbeTcp {
+		t.Errorf("tcp monitor = %+v", tcp)
+	}
+}
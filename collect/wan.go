@@ -0,0 +1,117 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"github.com/COSAE-FR/ripugw/inform"
+	"github.com/COSAE-FR/ripugw/pfconf"
+	"net"
+)
+
+const (
+	WanModeFailover    = "failover-only"
+	WanModeLoadBalance = "weighted"
+)
+
+type wanUplink struct {
+	Label       string
+	Entry       *inform.Interface
+	GatewayName string
+	GatewayIp   net.IP
+}
+
+// wanMode returns the dual WAN mode from the pfSense gateway group holding
+// both WAN gateways, preferring the group used as default gateway. Members
+// sharing a tier are load balanced, otherwise the higher tier is a failover.
+func wanMode(pfsense pfconf.Configuration, first string, second string) (string, bool) {
+	var found *pfconf.GatewayGroup
+	for i, group := range pfsense.GatewayGroups {
+		_, hasFirst := group.Member(first)
+		_, hasSecond := group.Member(second)
+		if !hasFirst || !hasSecond {
+			continue
+		}
+		if found == nil || group.Name == pfsense.GatewayIpv4 {
+			found = &pfsense.GatewayGroups[i]
+		}
+	}
+	if found == nil {
+		return "", false
+	}
+	firstMember, _ := found.Member(first)
+	secondMember, _ := found.Member(second)
+	if firstMember.Tier == secondMember.Tier {
+		return WanModeLoadBalance, true
+	}
+	return WanModeFailover, true
+}
+
+// gatewayIp returns the configured address of a gateway item, if static.
+func gatewayIp(pfsense pfconf.Configuration, name string) net.IP {
+	for _, gateway := range pfsense.Gateways {
+		if gateway.Name == name {
+			return net.ParseIP(gateway.Gateway)
+		}
+	}
+	return nil
+}
+
+// routesThrough checks whether the default gateway is reached by uplink.
+func (u wanUplink) routesThrough(gateway net.IP) bool {
+	if gateway == nil {
+		return false
+	}
+	if u.GatewayIp != nil && u.GatewayIp.Equal(gateway) {
+		return true
+	}
+	ip := net.ParseIP(u.Entry.Ip)
+	mask := net.IPMask(net.ParseIP(u.Entry.Netmask).To4())
+	if ip == nil || mask == nil {
+		return false
+	}
+	network := net.IPNet{IP: ip.Mask(mask), Mask: mask}
+	return network.Contains(gateway)
+}
+
+// applyDualWan reports the active uplink and the dual WAN mode. The active
+// WAN is the one the default route goes through, unless its gateway is
+// down, otherwise the first WAN up. The device identity (MAC, serial and
+// address) stays the one of the first WAN so the controller keeps seeing
+// the adopted device on failover.
+func applyDualWan(request *inform.Inform, pfsense pfconf.Configuration, uplinks []wanUplink, defaultGateway net.IP) {
+	if len(uplinks) == 0 {
+		return
+	}
+	var active *wanUplink
+	for i := range uplinks {
+		if uplinks[i].Entry.Up && uplinks[i].routesThrough(defaultGateway) {
+			active = &uplinks[i]
+			break
+		}
+	}
+	if active == nil {
+		for i := range uplinks {
+			if uplinks[i].Entry.Up {
+				active = &uplinks[i]
+				break
+			}
+		}
+	}
+	if active != nil {
+		request.ActiveWan = active.Label
+		request.Uplink = active.Entry.Name
+	}
+	if len(uplinks) > 1 {
+		if mode, ok := wanMode(pfsense, uplinks[0].GatewayName, uplinks[1].GatewayName); ok {
+			request.WanMode = mode
+		} else {
+			request.WanMode = WanModeFailover
+		}
+	}
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"github.com/COSAE-FR/ripugw/inform"
+	"github.com/COSAE-FR/ripugw/pfconf"
+	"net"
+	"testing"
+)
+
+func dualWanConfig(groups ...pfconf.GatewayGroup) pfconf.Configuration {
+	return pfconf.Configuration{
+		Gateways: []pfconf.Gateway{
+			{Name: "WAN_DHCP", Interface: "wan", Gateway: "dynamic"},
+			{Name: "WAN2_GW", Interface: "opt4", Gateway: "198.51.100.1"},
+		},
+		GatewayGroups: groups,
+	}
+}
+
+func TestWanMode(t *testing.T) {
+	failover := pfconf.GatewayGroup{Name: "Failover", Items: []string{"WAN_DHCP|1|address", "WAN2_GW|2|address"}}
+	balanced := pfconf.GatewayGroup{Name: "Balanced", Items: []string{"WAN_DHCP|1|address", "WAN2_GW|1|address"}}
+	single := pfconf.GatewayGroup{Name: "Single", Items: []string{"WAN_DHCP|1|address"}}
+	defaultGroup := dualWanConfig(failover, balanced)
+	defaultGroup.GatewayIpv4 = "Balanced"
+	tests := []struct {
+		name   string
+		config pfconf.Configuration
+		mode   string
+		found  bool
+	}{
+		{"failover-only", dualWanConfig(failover), WanModeFailover, true},
+		{"weighted", dualWanConfig(balanced), WanModeLoadBalance, true},
+		{"no group with both", dualWanConfig(single), "", false},
+		{"no group", dualWanConfig(), "", false},
+		{"first group", dualWanConfig(failover, balanced), WanModeFailover, true},
+		{"default gateway group", defaultGroup, WanModeLoadBalance, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mode, found := wanMode(test.config, "WAN_DHCP", "WAN2_GW")
+			if mode != test.mode || found != test.found {
+				t.Errorf("wanMode = %q, %v, want %q, %v", mode, found, test.mode, test.found)
+			}
+		})
+	}
+}
+
+func TestApplyDualWan(t *testing.T) {
+	failover := dualWanConfig(pfconf.GatewayGroup{Name: "Failover", Items: []string{"WAN_DHCP|1|address", "WAN2_GW|2|address"}})
+	balanced := dualWanConfig(pfconf.GatewayGroup{Name: "Balanced", Items: []string{"WAN_DHCP|1|address", "WAN2_GW|1|address"}})
+	primaryMac := inform.HardwareAddr{0x00, 0x0d, 0xb9, 0x00, 0x00, 0x01}
+	tests := []struct {
+		name           string
+		config         pfconf.Configuration
+		wanUp, wan2Up  bool
+		defaultGateway string
+		active, uplink string
+		mode           string
+	}{
+		{"default route through WAN subnet", failover, true, true, "203.0.113.1", "WAN", "eth0", WanModeFailover},
+		{"default route through WAN2 gateway", failover, true, true, "198.51.100.1", "WAN2", "eth2", WanModeFailover},
+		{"weighted through WAN2", balanced, true, true, "198.51.100.1", "WAN2", "eth2", WanModeLoadBalance},
+		{"gateway of a down WAN", failover, false, true, "203.0.113.1", "WAN2", "eth2", WanModeFailover},
+		{"no default route", failover, true, true, "", "WAN", "eth0", WanModeFailover},
+		{"unknown default route", failover, false, true, "192.0.2.1", "WAN2", "eth2", WanModeFailover},
+		{"all down", failover, false, false, "203.0.113.1", "", "eth0", WanModeFailover},
+		{"no gateway group", dualWanConfig(), true, true, "198.51.100.1", "WAN2", "eth2", WanModeFailover},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := inform.Inform{
+				Uplink:  "eth0",
+				Mac:     primaryMac,
+				Serial:  primaryMac.HexString(),
+				Ip:      "203.0.113.10",
+				Netmask: "255.255.255.0",
+			}
+			wan := inform.Interface{Name: "eth0", Up: test.wanUp, Mac: primaryMac, Ip: "203.0.113.10", Netmask: "255.255.255.0"}
+			wan2 := inform.Interface{Name: "eth2", Up: test.wan2Up, Mac: inform.HardwareAddr{0x00, 0x0d, 0xb9, 0x00, 0x00, 0x03}, Ip: "198.51.100.10", Netmask: "255.255.255.0"}
+			uplinks := []wanUplink{
+				{Label: "WAN", Entry: &wan, GatewayName: "WAN_DHCP", GatewayIp: gatewayIp(test.config, "WAN_DHCP")},
+				{Label: "WAN2", Entry: &wan2, GatewayName: "WAN2_GW", GatewayIp: gatewayIp(test.config, "WAN2_GW")},
+			}
+			applyDualWan(&request, test.config, uplinks, net.ParseIP(test.defaultGateway))
+			if request.ActiveWan != test.active || request.Uplink != test.uplink {
+				t.Errorf("active = %q, uplink = %q, want %q, %q", request.ActiveWan, request.Uplink, test.active, test.uplink)
+			}
+			if request.WanMode != test.mode {
+				t.Errorf("mode = %q, want %q", request.WanMode, test.mode)
+			}
+			if request.Mac.String() != primaryMac.String() || request.Serial != primaryMac.HexString() || request.Ip != "203.0.113.10" {
+				t.Errorf("device identity changed: %s %s %s", request.Mac, request.Serial, request.Ip)
+			}
+		})
+	}
+
+	// The active WAN follows the default route from one inform to the next
+	request := inform.Inform{Mac: primaryMac}
+	wan := inform.Interface{Name: "eth0", Up: true, Ip: "203.0.113.10", Netmask: "255.255.255.0"}
+	wan2 := inform.Interface{Name: "eth2", Up: true, Ip: "198.51.100.10", Netmask: "255.255.255.0"}
+	uplinks := []wanUplink{{Label: "WAN", Entry: &wan}, {Label: "WAN2", Entry: &wan2}}
+	for _, step := range []struct {
+		gateway string
+		active  string
+	}{{"203.0.113.1", "WAN"}, {"198.51.100.1", "WAN2"}, {"203.0.113.1", "WAN"}} {
+		applyDualWan(&request, failover, uplinks, net.ParseIP(step.gateway))
+		if request.ActiveWan != step.active {
+			t.Errorf("active after a switch to %s = %q, want %q", step.gateway, request.ActiveWan, step.active)
+		}
+	}
+	if request.Mac.String() != primaryMac.String() {
+		t.Errorf("mac changed to %s", request.Mac)
+	}
+}
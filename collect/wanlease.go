@@ -0,0 +1,359 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/COSAE-FR/ripugw/inform"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultWanLeaseFiles lists the DHCP client lease files, %s being the
+// interface name. dhcpcd stores the raw DHCP message.
+var DefaultWanLeaseFiles = []string{
+	"/var/db/dhclient.leases.%s",
+	"/var/lib/dhcp/dhclient.%s.leases",
+	"/var/lib/dhcp/dhclient.leases",
+	"/var/lib/dhclient/dhclient-*-%s.lease",
+	"/var/lib/dhclient/dhclient.leases",
+	"/var/lib/dhcpcd/%s.lease",
+	"/var/lib/dhcpcd5/dhcpcd-%s.lease",
+	"/var/db/dhcpcd/%s.lease",
+}
+
+// NetworkdLeases is the systemd-networkd lease directory, files are named
+// after the interface index.
+var NetworkdLeases = "/run/systemd/netif/leases"
+
+// WanLease is a lease obtained by a DHCP client.
+type WanLease struct {
+	Interface   string
+	Address     net.IP
+	Netmask     net.IPMask
+	Routers     []net.IP
+	Nameservers []string
+	DomainName  string
+	Server      net.IP
+	Start       time.Time
+	Renew       time.Time
+	Expire      time.Time
+}
+
+func (l WanLease) Inform() *inform.DhcpLease {
+	lease := &inform.DhcpLease{
+		Ip:          l.Address.String(),
+		Nameservers: l.Nameservers,
+		DomainName:  l.DomainName,
+	}
+	if l.Netmask != nil {
+		lease.Netmask = FormatMask(l.Netmask)
+	}
+	if len(l.Routers) > 0 {
+		lease.Gateway = l.Routers[0].String()
+	}
+	if l.Server != nil {
+		lease.Server = l.Server.String()
+	}
+	if !l.Start.IsZero() {
+		lease.Start = l.Start.Unix()
+	}
+	if !l.Expire.IsZero() {
+		lease.Expire = l.Expire.Unix()
+	}
+	return lease
+}
+
+func parseIpList(value string) []net.IP {
+	var ips []net.IP
+	for _, item := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if ip := net.ParseIP(item); ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
+
+func ipStrings(ips []net.IP) []string {
+	var result []string
+	for _, ip := range ips {
+		result = append(result, ip.String())
+	}
+	return result
+}
+
+// ParseDhclientLeases parses an ISC dhclient leases file, oldest first.
+func ParseDhclientLeases(r io.Reader) ([]WanLease, error) {
+	var leases []WanLease
+	var current *WanLease
+	var leaseTime time.Duration
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if current == nil {
+			if line == "lease {" {
+				current = &WanLease{}
+				leaseTime = 0
+			}
+			continue
+		}
+		if line == "}" {
+			if current.Address != nil {
+				if current.Start.IsZero() && !current.Expire.IsZero() && leaseTime > 0 {
+					current.Start = current.Expire.Add(-leaseTime)
+				}
+				leases = append(leases, *current)
+			}
+			current = nil
+			continue
+		}
+		fields := strings.Fields(strings.TrimSuffix(line, ";"))
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "interface":
+			current.Interface = strings.Trim(fields[1], `"`)
+		case "fixed-address":
+			current.Address = net.ParseIP(fields[1])
+		case "renew":
+			current.Renew = parseDhcpdTime(fields[1:])
+		case "expire":
+			current.Expire = parseDhcpdTime(fields[1:])
+		case "option":
+			if len(fields) < 3 {
+				continue
+			}
+			value := strings.Join(fields[2:], " ")
+			switch fields[1] {
+			case "subnet-mask":
+				if mask := net.ParseIP(value).To4(); mask != nil {
+					current.Netmask = net.IPMask(mask)
+				}
+			case "routers":
+				current.Routers = parseIpList(value)
+			case "domain-name-servers":
+				current.Nameservers = ipStrings(parseIpList(value))
+			case "domain-name":
+				current.DomainName = strings.Trim(value, `"`)
+			case "dhcp-server-identifier":
+				current.Server = net.ParseIP(value)
+			case "dhcp-lease-time":
+				if seconds, err := strconv.Atoi(value); err == nil {
+					leaseTime = time.Duration(seconds) * time.Second
+				}
+			}
+		}
+	}
+	return leases, scanner.Err()
+}
+
+// DHCP options, from RFC 2132
+const (
+	dhcpOptionPad        = 0
+	dhcpOptionSubnetMask = 1
+	dhcpOptionRouter     = 3
+	dhcpOptionDns        = 6
+	dhcpOptionDomainName = 15
+	dhcpOptionLeaseTime  = 51
+	dhcpOptionServerId   = 54
+	dhcpOptionRenewTime  = 58
+	dhcpOptionEnd        = 255
+
+	dhcpMessageOptions = 240
+	dhcpMagicCookie    = 0x63825363
+)
+
+func ipv4List(value []byte) []net.IP {
+	var ips []net.IP
+	for i := 0; i+net.IPv4len <= len(value); i += net.IPv4len {
+		ips = append(ips, net.IPv4(value[i], value[i+1], value[i+2], value[i+3]))
+	}
+	return ips
+}
+
+// ParseDhcpcdLease parses a dhcpcd lease, the DHCP message received from
+// the server. The lease starts when the file was written.
+func ParseDhcpcdLease(data []byte, written time.Time) (WanLease, error) {
+	if len(data) < dhcpMessageOptions || binary.BigEndian.Uint32(data[236:240]) != dhcpMagicCookie {
+		return WanLease{}, errors.New("invalid DHCP message")
+	}
+	lease := WanLease{
+		Address: net.IPv4(data[16], data[17], data[18], data[19]),
+		Start:   written,
+	}
+	options := data[dhcpMessageOptions:]
+	for i := 0; i < len(options); {
+		code := options[i]
+		if code == dhcpOptionEnd {
+			break
+		}
+		if code == dhcpOptionPad {
+			i++
+			continue
+		}
+		if i+2 > len(options) || i+2+int(options[i+1]) > len(options) {
+			return lease, errors.New("truncated DHCP option")
+		}
+		value := options[i+2 : i+2+int(options[i+1])]
+		i += 2 + len(value)
+		switch code {
+		case dhcpOptionSubnetMask:
+			if len(value) == net.IPv4len {
+				lease.Netmask = net.IPMask(value)
+			}
+		case dhcpOptionRouter:
+			lease.Routers = ipv4List(value)
+		case dhcpOptionDns:
+			lease.Nameservers = ipStrings(ipv4List(value))
+		case dhcpOptionDomainName:
+			lease.DomainName = strings.TrimRight(string(value), "\x00")
+		case dhcpOptionServerId:
+			if ips := ipv4List(value); len(ips) > 0 {
+				lease.Server = ips[0]
+			}
+		case dhcpOptionLeaseTime:
+			if len(value) == 4 {
+				lease.Expire = written.Add(time.Duration(binary.BigEndian.Uint32(value)) * time.Second)
+			}
+		case dhcpOptionRenewTime:
+			if len(value) == 4 {
+				lease.Renew = written.Add(time.Duration(binary.BigEndian.Uint32(value)) * time.Second)
+			}
+		}
+	}
+	return lease, nil
+}
+
+// ParseNetworkdLease parses a systemd-networkd lease file. The lease
+// starts when the file was written.
+func ParseNetworkdLease(r io.Reader, written time.Time) (WanLease, error) {
+	lease := WanLease{Start: written}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		switch parts[0] {
+		case "ADDRESS":
+			lease.Address = net.ParseIP(parts[1])
+		case "NETMASK":
+			if mask := net.ParseIP(parts[1]).To4(); mask != nil {
+				lease.Netmask = net.IPMask(mask)
+			}
+		case "ROUTER":
+			lease.Routers = parseIpList(parts[1])
+		case "DNS":
+			lease.Nameservers = ipStrings(parseIpList(parts[1]))
+		case "DOMAINNAME":
+			lease.DomainName = parts[1]
+		case "SERVER_ADDRESS":
+			lease.Server = net.ParseIP(parts[1])
+		case "LIFETIME":
+			if seconds, err := strconv.Atoi(parts[1]); err == nil {
+				lease.Expire = written.Add(time.Duration(seconds) * time.Second)
+			}
+		case "T1":
+			if seconds, err := strconv.Atoi(parts[1]); err == nil {
+				lease.Renew = written.Add(time.Duration(seconds) * time.Second)
+			}
+		}
+	}
+	if lease.Address == nil {
+		return lease, errors.New("no address in lease")
+	}
+	return lease, scanner.Err()
+}
+
+func readWanLeaseFile(file string, iface string) ([]WanLease, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(file, ".lease") && len(data) >= dhcpMessageOptions && !strings.Contains(string(data[:dhcpMessageOptions]), "lease {") {
+		lease, err := ParseDhcpcdLease(data, info.ModTime())
+		if err != nil {
+			return nil, err
+		}
+		lease.Interface = iface
+		return []WanLease{lease}, nil
+	}
+	leases, err := ParseDhclientLeases(strings.NewReader(string(data)))
+	for i := range leases {
+		if len(leases[i].Interface) == 0 {
+			leases[i].Interface = iface
+		}
+	}
+	return leases, err
+}
+
+// ReadWanLease returns the most recent DHCP client lease of iface from the
+// DefaultWanLeaseFiles and the systemd-networkd leases.
+func ReadWanLease(iface string) (WanLease, error) {
+	var found *WanLease
+	keep := func(lease WanLease) {
+		if lease.Interface != iface || lease.Address == nil {
+			return
+		}
+		if found == nil || lease.Expire.After(found.Expire) {
+			found = &lease
+		}
+	}
+	for _, pattern := range DefaultWanLeaseFiles {
+		if strings.Contains(pattern, "%s") {
+			pattern = fmt.Sprintf(pattern, iface)
+		}
+		files, _ := filepath.Glob(pattern)
+		for _, file := range files {
+			leases, _ := readWanLeaseFile(file, iface)
+			for _, lease := range leases {
+				keep(lease)
+			}
+		}
+	}
+	if link, err := net.InterfaceByName(iface); err == nil {
+		file := path.Join(NetworkdLeases, strconv.Itoa(link.Index))
+		if info, err := os.Stat(file); err == nil {
+			if handle, err := os.Open(file); err == nil {
+				lease, err := ParseNetworkdLease(handle, info.ModTime())
+				_ = handle.Close()
+				if err == nil {
+					lease.Interface = iface
+					keep(lease)
+				}
+			}
+		}
+	}
+	if found == nil {
+		return WanLease{}, fmt.Errorf("no DHCP lease found for %s", iface)
+	}
+	return *found, nil
+}
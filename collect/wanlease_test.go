@@ -0,0 +1,209 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func openFixture(t *testing.T, name string) *os.File {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = f.Close()
+	})
+	return f
+}
+
+// dhcpMessage builds a DHCP ACK offering yiaddr with the given options.
+func dhcpMessage(yiaddr net.IP, options ...[]byte) []byte {
+	message := make([]byte, dhcpMessageOptions)
+	message[0] = 2
+	copy(message[16:20], yiaddr.To4())
+	binary.BigEndian.PutUint32(message[236:240], dhcpMagicCookie)
+	for _, option := range options {
+		message = append(message, option...)
+	}
+	return append(message, dhcpOptionEnd)
+}
+
+func dhcpOption(code byte, value ...byte) []byte {
+	return append([]byte{code, byte(len(value))}, value...)
+}
+
+func dhcpSeconds(code byte, seconds uint32) []byte {
+	value := make([]byte, 4)
+	binary.BigEndian.PutUint32(value, seconds)
+	return dhcpOption(code, value...)
+}
+
+func TestParseDhclientLeases(t *testing.T) {
+	leases, err := ParseDhclientLeases(openFixture(t, "dhclient.leases"))
+	if err != nil {
+		t.Fatalf("ParseDhclientLeases() = %v", err)
+	}
+	if len(leases) != 3 {
+		t.Fatalf("%d leases, want 3 complete ones", len(leases))
+	}
+	first := leases[0]
+	if first.Interface != "lo" || !first.Address.Equal(net.IPv4(198, 51, 100, 23)) {
+		t.Errorf("first lease = %+v", first)
+	}
+	got := first.Inform()
+	if got.Netmask != "255.255.255.0" || got.Gateway != "198.51.100.1" || got.Server != "198.51.100.1" || got.DomainName != "isp.example" {
+		t.Errorf("first lease = %+v", got)
+	}
+	if !reflect.DeepEqual(got.Nameservers, []string{"198.51.100.1", "9.9.9.9"}) {
+		t.Errorf("nameservers = %v", got.Nameservers)
+	}
+	expire := time.Date(2020, 7, 9, 12, 0, 0, 0, time.UTC)
+	if !first.Expire.Equal(expire) || !first.Start.Equal(expire.Add(-2*time.Hour)) || !first.Renew.Equal(expire.Add(-time.Hour)) {
+		t.Errorf("first lease times = %s %s %s", first.Start, first.Renew, first.Expire)
+	}
+	if leases[1].Interface != "eth1" || FormatMask(leases[1].Netmask) != "255.255.255.248" || !leases[1].Start.IsZero() {
+		t.Errorf("second lease = %+v", leases[1])
+	}
+}
+
+func TestParseDhcpcdLease(t *testing.T) {
+	written := time.Date(2020, 7, 10, 0, 0, 0, 0, time.UTC)
+	message := dhcpMessage(net.IPv4(203, 0, 113, 9),
+		dhcpOption(dhcpOptionSubnetMask, 255, 255, 255, 248),
+		[]byte{dhcpOptionPad, dhcpOptionPad},
+		dhcpOption(dhcpOptionRouter, 203, 0, 113, 14, 203, 0, 113, 13),
+		dhcpOption(dhcpOptionDns, 203, 0, 113, 53, 9, 9, 9, 9),
+		dhcpOption(dhcpOptionDomainName, []byte("isp.example\x00")...),
+		dhcpOption(dhcpOptionServerId, 203, 0, 113, 14),
+		dhcpSeconds(dhcpOptionLeaseTime, 86400),
+		dhcpSeconds(dhcpOptionRenewTime, 43200),
+	)
+	lease, err := ParseDhcpcdLease(message, written)
+	if err != nil {
+		t.Fatalf("ParseDhcpcdLease() = %v", err)
+	}
+	got := lease.Inform()
+	if got.Ip != "203.0.113.9" || got.Netmask != "255.255.255.248" || got.Gateway != "203.0.113.14" || got.Server != "203.0.113.14" || got.DomainName != "isp.example" {
+		t.Errorf("lease = %+v", got)
+	}
+	if !reflect.DeepEqual(got.Nameservers, []string{"203.0.113.53", "9.9.9.9"}) {
+		t.Errorf("nameservers = %v", got.Nameservers)
+	}
+	if !lease.Start.Equal(written) || !lease.Renew.Equal(written.Add(12*time.Hour)) || !lease.Expire.Equal(written.Add(24*time.Hour)) {
+		t.Errorf("lease times = %s %s %s", lease.Start, lease.Renew, lease.Expire)
+	}
+
+	invalid := map[string][]byte{
+		"short":     message[:100],
+		"no cookie": append(make([]byte, dhcpMessageOptions), dhcpOptionEnd),
+		"truncated": dhcpMessage(net.IPv4(203, 0, 113, 9), []byte{dhcpOptionRouter, 8, 203, 0})[:dhcpMessageOptions+4],
+	}
+	for name, data := range invalid {
+		if _, err := ParseDhcpcdLease(data, written); err == nil {
+			t.Errorf("%s message accepted", name)
+		}
+	}
+}
+
+func TestParseNetworkdLease(t *testing.T) {
+	written := time.Date(2020, 7, 9, 0, 0, 0, 0, time.UTC)
+	lease, err := ParseNetworkdLease(openFixture(t, "networkd.lease"), written)
+	if err != nil {
+		t.Fatalf("ParseNetworkdLease() = %v", err)
+	}
+	got := lease.Inform()
+	if got.Ip != "192.0.2.50" || got.Netmask != "255.255.255.0" || got.Gateway != "192.0.2.1" || got.Server != "192.0.2.1" || got.DomainName != "lan.example" {
+		t.Errorf("lease = %+v", got)
+	}
+	if !reflect.DeepEqual(got.Nameservers, []string{"192.0.2.1", "192.0.2.2"}) {
+		t.Errorf("nameservers = %v", got.Nameservers)
+	}
+	if !lease.Renew.Equal(written.Add(30*time.Minute)) || !lease.Expire.Equal(written.Add(time.Hour)) {
+		t.Errorf("lease times = %s %s", lease.Renew, lease.Expire)
+	}
+	if _, err := ParseNetworkdLease(strings.NewReader("# no lease yet\nNETMASK=255.255.255.0\ngarbage\n"), written); err == nil {
+		t.Error("lease without address accepted")
+	}
+}
+
+func TestReadWanLease(t *testing.T) {
+	loopback, err := net.InterfaceByName("lo")
+	if err != nil {
+		t.Skipf("no loopback interface: %v", err)
+	}
+	dir := t.TempDir()
+	writeFile := func(name string, data []byte, modified time.Time) {
+		t.Helper()
+		file := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(file, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(file, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dhclient, err := ioutil.ReadFile(filepath.Join("testdata", "dhclient.leases"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	networkd, err := ioutil.ReadFile(filepath.Join("testdata", "networkd.lease"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile("dhclient.leases.lo", dhclient, time.Now())
+	writeFile(filepath.Join("networkd", strconv.Itoa(loopback.Index)), networkd, time.Date(2020, 7, 9, 0, 0, 0, 0, time.UTC))
+	writeFile("dhcpcd/lo.lease", dhcpMessage(net.IPv4(203, 0, 113, 9), dhcpSeconds(dhcpOptionLeaseTime, 2*86400)), time.Date(2020, 7, 10, 0, 0, 0, 0, time.UTC))
+
+	defaultFiles, networkdLeases := DefaultWanLeaseFiles, NetworkdLeases
+	defer func() {
+		DefaultWanLeaseFiles, NetworkdLeases = defaultFiles, networkdLeases
+	}()
+	NetworkdLeases = filepath.Join(dir, "networkd")
+	tests := []struct {
+		name  string
+		files []string
+		want  string
+	}{
+		{"dhcpcd lease expires last", []string{filepath.Join(dir, "dhclient.leases.%s"), filepath.Join(dir, "dhcpcd", "%s.lease")}, "203.0.113.9"},
+		{"latest dhclient lease", []string{filepath.Join(dir, "dhclient.leases.%s")}, "198.51.100.24"},
+		{"networkd only", []string{filepath.Join(dir, "missing.%s")}, "192.0.2.50"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			DefaultWanLeaseFiles = test.files
+			lease, err := ReadWanLease("lo")
+			if err != nil {
+				t.Fatalf("ReadWanLease() = %v", err)
+			}
+			if lease.Interface != "lo" || lease.Address.String() != test.want {
+				t.Errorf("lease = %s on %s, want %s", lease.Address, lease.Interface, test.want)
+			}
+		})
+	}
+
+	NetworkdLeases = filepath.Join(dir, "missing")
+	DefaultWanLeaseFiles = []string{filepath.Join(dir, "dhclient.leases.%s")}
+	if lease, err := ReadWanLease("eth9"); err == nil {
+		t.Errorf("ReadWanLease(eth9) = %+v, want no lease", lease)
+	}
+}
@@ -0,0 +1,170 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const defaultWatchDebounce = 2 * time.Second
+
+type NetEventType int
+
+const (
+	NetEventLink NetEventType = iota
+	NetEventAddress
+	NetEventRoute
+	// NetEventOverrun tells that the system dropped events
+	NetEventOverrun
+)
+
+// NetEvent is a link, address or route change notified by the system.
+type NetEvent struct {
+	Type      NetEventType
+	Interface string
+	Deleted   bool
+	// Link events
+	Up bool
+	// Address events
+	Address net.IP
+	// Route events
+	Default bool
+	Gateway net.IP
+}
+
+// EventSource delivers network change events, from netlink on Linux.
+type EventSource interface {
+	Events() <-chan NetEvent
+	Close() error
+}
+
+// NetWatcher invalidates cached network data on system events and calls
+// OnTrigger, at most once per Debounce period, when a WAN link goes down,
+// an address changes or the default route moves. Without IsWan, the
+// interface holding the default route is the WAN.
+type NetWatcher struct {
+	Source    EventSource
+	Debounce  time.Duration
+	IsWan     func(iface string) bool
+	Links     *LinkTracker
+	OnTrigger func(reason string)
+
+	defaultRoute     string
+	defaultInterface string
+}
+
+func NewNetWatcher(source EventSource, debounce time.Duration) *NetWatcher {
+	if debounce <= 0 {
+		debounce = defaultWatchDebounce
+	}
+	return &NetWatcher{
+		Source:   source,
+		Debounce: debounce,
+	}
+}
+
+func (w *NetWatcher) isWan(iface string) bool {
+	if w.IsWan == nil {
+		return len(iface) > 0 && iface == w.defaultInterface
+	}
+	return w.IsWan(iface)
+}
+
+// reason returns why event must trigger an inform, if it must.
+func (w *NetWatcher) reason(event NetEvent) string {
+	switch event.Type {
+	case NetEventLink:
+		if w.Links != nil {
+			w.Links.Observe(event.Interface, event.Up && !event.Deleted, time.Now())
+		}
+		if w.isWan(event.Interface) && (!event.Up || event.Deleted) {
+			return "wan down " + event.Interface
+		}
+	case NetEventAddress:
+		if event.Address == nil || event.Address.IsLinkLocalUnicast() {
+			return ""
+		}
+		action := "added"
+		if event.Deleted {
+			action = "removed"
+		}
+		return fmt.Sprintf("address %s %s on %s", event.Address, action, event.Interface)
+	case NetEventRoute:
+		if !event.Default {
+			return ""
+		}
+		route := event.Interface + " " + event.Gateway.String()
+		if event.Deleted {
+			if route != w.defaultRoute {
+				return ""
+			}
+			w.defaultRoute = ""
+			w.defaultInterface = ""
+			return "default route removed from " + event.Interface
+		}
+		if route == w.defaultRoute {
+			return ""
+		}
+		w.defaultRoute = route
+		w.defaultInterface = event.Interface
+		return "default route via " + route
+	case NetEventOverrun:
+		return "network events lost"
+	}
+	return ""
+}
+
+// Run handles events until the source is closed. The network data is only
+// cached while a watcher runs.
+func (w *NetWatcher) Run() {
+	InvalidateCache()
+	atomic.AddInt32(&cacheWatchers, 1)
+	defer func() {
+		atomic.AddInt32(&cacheWatchers, -1)
+		InvalidateCache()
+	}()
+	var timer <-chan time.Time
+	reasons := make(map[string]bool)
+	events := w.Source.Events()
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			InvalidateCache()
+			if reason := w.reason(event); len(reason) > 0 {
+				reasons[reason] = true
+				if timer == nil {
+					timer = time.After(w.Debounce)
+				}
+			}
+		case <-timer:
+			timer = nil
+			list := make([]string, 0, len(reasons))
+			for reason := range reasons {
+				list = append(list, reason)
+			}
+			sort.Strings(list)
+			reasons = make(map[string]bool)
+			if w.OnTrigger != nil {
+				w.OnTrigger(strings.Join(list, ", "))
+			}
+		}
+	}
+}
+
+func (w *NetWatcher) Close() error {
+	return w.Source.Close()
+}
@@ -0,0 +1,141 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package collect
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeEventSource is an EventSource fed by hand.
+type fakeEventSource struct {
+	events chan NetEvent
+	once   sync.Once
+}
+
+func newFakeEventSource() *fakeEventSource {
+	return &fakeEventSource{events: make(chan NetEvent, 16)}
+}
+
+func (f *fakeEventSource) Send(event NetEvent) {
+	f.events <- event
+}
+
+func (f *fakeEventSource) Events() <-chan NetEvent {
+	return f.events
+}
+
+func (f *fakeEventSource) Close() error {
+	f.once.Do(func() {
+		close(f.events)
+	})
+	return nil
+}
+
+func startTestWatcher(t *testing.T, isWan func(string) bool) (*fakeEventSource, *NetWatcher, chan string) {
+	t.Helper()
+	source := newFakeEventSource()
+	watcher := NewNetWatcher(source, 50*time.Millisecond)
+	watcher.IsWan = isWan
+	watcher.Links = NewLinkTracker(0, 0)
+	triggers := make(chan string, 4)
+	watcher.OnTrigger = func(reason string) {
+		triggers <- reason
+	}
+	done := make(chan struct{})
+	go func() {
+		watcher.Run()
+		close(done)
+	}()
+	t.Cleanup(func() {
+		_ = watcher.Close()
+		<-done
+	})
+	return source, watcher, triggers
+}
+
+func expectTrigger(t *testing.T, triggers chan string, want string) {
+	t.Helper()
+	select {
+	case got := <-triggers:
+		if got != want {
+			t.Errorf("trigger reason = %q, want %q", got, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("no trigger, want %q", want)
+	}
+}
+
+func expectNoTrigger(t *testing.T, triggers chan string) {
+	t.Helper()
+	select {
+	case got := <-triggers:
+		t.Errorf("unexpected trigger %q", got)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestNetWatcherDefaultRoute(t *testing.T) {
+	source, _, triggers := startTestWatcher(t, nil)
+	gateway := net.IPv4(198, 51, 100, 1)
+
+	source.Send(NetEvent{Type: NetEventRoute, Interface: "eth0", Default: true, Gateway: gateway})
+	expectTrigger(t, triggers, "default route via eth0 198.51.100.1")
+
+	// The same route again, a non default route and a LAN link change are ignored
+	source.Send(NetEvent{Type: NetEventRoute, Interface: "eth0", Default: true, Gateway: gateway})
+	source.Send(NetEvent{Type: NetEventRoute, Interface: "eth1"})
+	source.Send(NetEvent{Type: NetEventLink, Interface: "eth1", Up: false})
+	expectNoTrigger(t, triggers)
+
+	// Without IsWan, the interface of the default route is the WAN
+	source.Send(NetEvent{Type: NetEventLink, Interface: "eth0", Up: false})
+	source.Send(NetEvent{Type: NetEventRoute, Interface: "eth0", Default: true, Gateway: gateway, Deleted: true})
+	expectTrigger(t, triggers, "default route removed from eth0, wan down eth0")
+}
+
+func TestNetWatcherAddressesAndLinks(t *testing.T) {
+	isWan := func(iface string) bool {
+		return iface == "eth0" || iface == "eth2"
+	}
+	source, watcher, triggers := startTestWatcher(t, isWan)
+
+	source.Send(NetEvent{Type: NetEventAddress, Interface: "eth1", Address: net.ParseIP("fe80::1")})
+	source.Send(NetEvent{Type: NetEventLink, Interface: "eth2", Up: true})
+	expectNoTrigger(t, triggers)
+
+	source.Send(NetEvent{Type: NetEventAddress, Interface: "eth0", Address: net.IPv4(203, 0, 113, 7)})
+	source.Send(NetEvent{Type: NetEventLink, Interface: "eth2", Deleted: true})
+	expectTrigger(t, triggers, "address 203.0.113.7 added on eth0, wan down eth2")
+
+	states := watcher.Links.States()
+	if state, ok := states["eth2"]; !ok || state.Up || state.Flaps != 1 {
+		t.Errorf("eth2 link state = %+v, want down after one flap", state)
+	}
+}
+
+func TestNetWatcherOverrun(t *testing.T) {
+	source, _, triggers := startTestWatcher(t, nil)
+	source.Send(NetEvent{Type: NetEventAddress, Interface: "eth0", Address: net.IPv4(203, 0, 113, 7)})
+	expectTrigger(t, triggers, "address 203.0.113.7 added on eth0")
+
+	gateway4Cache.lock.Lock()
+	gateway4Cache.ip = net.IPv4(198, 51, 100, 1)
+	gateway4Cache.expires = time.Now().Add(time.Hour)
+	gateway4Cache.lock.Unlock()
+	source.Send(NetEvent{Type: NetEventOverrun})
+	expectTrigger(t, triggers, "network events lost")
+	gateway4Cache.lock.Lock()
+	defer gateway4Cache.lock.Unlock()
+	if gateway4Cache.ip != nil {
+		t.Errorf("cached gateway = %s after lost events, want none", gateway4Cache.ip)
+	}
+}
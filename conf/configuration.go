@@ -9,6 +9,7 @@
 package conf
 
 import (
+	"bytes"
 	"encoding/json"
 	"encoding/xml"
 	"github.com/BurntSushi/toml"
@@ -24,11 +25,23 @@ import (
 const (
 	defaultInformUrl      = "http://unifi:8080/inform"
 	defaultInformInterval = 15
+
+	// keyRetryInforms is the number of informs sent with the previous
+	// key before trying the new one again during a key rotation.
+	keyRetryInforms = 10
 )
 
 type Config struct {
 	General           general                          `toml:"general" json:"general"`
 	Management        Management                       `toml:"mgmt_cfg" json:"mgmt_cfg"`
+	Discovery         Discovery                        `toml:"discovery" json:"discovery"`
+	Stun              Stun                             `toml:"stun" json:"stun"`
+	Notify            Notify                           `toml:"notify" json:"notify"`
+	Clock             Clock                            `toml:"clock" json:"clock"`
+	Status            Status                           `toml:"status" json:"status"`
+	Clients           Clients                          `toml:"clients" json:"clients"`
+	Uplink            Uplink                           `toml:"uplink" json:"uplink"`
+	Watch             Watch                            `toml:"watch" json:"watch"`
 	PfSenseInterfaces *collect.PfSenseTranslationTable `toml:"pfsense_interfaces" json:"pfsense_interfaces"`
 	path              string                           `toml:"-" json:"-"`
 	useJson           bool                             `toml:"-" json:"-"`
@@ -49,10 +62,64 @@ type general struct {
 	LogFileWriter  *os.File `toml:"-" json:"-"`
 }
 
+type Discovery struct {
+	Enable  bool   `toml:"enable" json:"enable"`
+	Address string `toml:"listen,omitempty" json:"listen,omitempty"`
+}
+
+type Stun struct {
+	Disable  bool `toml:"disable" json:"disable"`
+	Interval int  `toml:"interval,omitempty" json:"interval,omitempty"`
+}
+
+type Notify struct {
+	Disable     bool `toml:"disable" json:"disable"`
+	MinInterval int  `toml:"min_interval,omitempty" json:"min_interval,omitempty"`
+}
+
+type Clock struct {
+	MaxSkew     int  `toml:"max_skew,omitempty" json:"max_skew,omitempty"`
+	CorrectTime bool `toml:"correct_time" json:"correct_time"`
+}
+
+type Status struct {
+	Address string `toml:"listen,omitempty" json:"listen,omitempty"`
+}
+
+type Clients struct {
+	Disable    bool     `toml:"disable" json:"disable"`
+	LeaseFiles []string `toml:"lease_files,omitempty" json:"lease_files,omitempty"`
+	OuiFile    string   `toml:"oui_file,omitempty" json:"oui_file,omitempty"`
+}
+
+type Uplink struct {
+	Disable  bool     `toml:"disable" json:"disable"`
+	Targets  []string `toml:"targets,omitempty" json:"targets,omitempty"`
+	Interval int      `toml:"interval,omitempty" json:"interval,omitempty"`
+	Window   int      `toml:"window,omitempty" json:"window,omitempty"`
+	// dpinger sockets directory, pfSense mode only
+	DpingerDir     string `toml:"dpinger_dir,omitempty" json:"dpinger_dir,omitempty"`
+	DisableDpinger bool   `toml:"disable_dpinger" json:"disable_dpinger"`
+}
+
+type Watch struct {
+	Disable bool `toml:"disable" json:"disable"`
+	// Delay in milliseconds to coalesce network events
+	Debounce int `toml:"debounce,omitempty" json:"debounce,omitempty"`
+}
+
 type Management struct {
-	Version   string `toml:"configversion" json:"configversion"`
-	UseAesGcm bool   `toml:"use_aes_gcm" json:"use_aes_gcm"`
-	Key       string `toml:"authkey" json:"authkey"`
+	Version     string `toml:"configversion" json:"configversion"`
+	UseAesGcm   bool   `toml:"use_aes_gcm" json:"use_aes_gcm"`
+	Key         string `toml:"authkey" json:"authkey"`
+	PreviousKey string `toml:"previous_authkey,omitempty" json:"previous_authkey,omitempty"`
+	StunUrl     string `toml:"stun_url,omitempty" json:"stun_url,omitempty"`
+	// The fallback to the previous key is not persisted, only the previous
+	// key is: after a restart during a rotation, the first inform goes out
+	// with the new key and a rejection falls back to the previous one for
+	// the next inform.
+	usePrevious bool
+	fallbacks   int
 }
 
 func (c *Config) Read() error {
@@ -173,8 +240,15 @@ func (c *Config) check() (changed bool, err error) {
 		c.General.InformInterval = defaultInformInterval
 		changed = true
 	}
+	c.loadPfSense()
+
+	return changed, err
+}
+
+func (c *Config) loadPfSense() {
+	logger := c.Log.WithField("component", "config_checker")
 	if len(c.General.PfSenseXml) > 0 && fileExists(c.General.PfSenseXml) {
-		if len(c.PfSenseInterfaces.Wan) == 0 || len(c.PfSenseInterfaces.Lan) == 0 {
+		if c.PfSenseInterfaces == nil || len(c.PfSenseInterfaces.All()) == 0 {
 			logger.Warn("no interface translation table between pfSense and physical interfaces")
 		} else {
 			xmlFile, err := os.Open(c.General.PfSenseXml)
@@ -194,15 +268,21 @@ func (c *Config) check() (changed bool, err error) {
 				if err != nil {
 					logger.Errorf("cannot read pfSense configuration file %s: %s", c.General.PfSenseXml, err)
 				} else {
-					if err := xml.Unmarshal(byteValue, &c.PfSense); err != nil {
+					var pfsense *pfconf.Configuration
+					if err := xml.Unmarshal(byteValue, &pfsense); err != nil {
 						logger.Errorf("cannot parse pfSense configuration file %s: %s", c.General.PfSenseXml, err)
 					} else {
-						err := c.PfSense.Finalize()
+						err := pfsense.Finalize()
 						if err != nil {
 							logger.Errorf("cannot finalize pfSense configuration: %v", err)
 						}
-						c.PfSenseMode = true
-						logger.Info("pfSense configuration valid: entering pfSense mode")
+						if err := c.PfSenseInterfaces.Validate(*pfsense); err != nil {
+							logger.Errorf("invalid pfSense interface mappings, pfSense configuration not loaded: %v", err)
+						} else {
+							c.PfSense = pfsense
+							c.PfSenseMode = true
+							logger.Info("pfSense configuration valid: entering pfSense mode")
+						}
 					}
 				}
 			}
@@ -210,8 +290,25 @@ func (c *Config) check() (changed bool, err error) {
 	} else {
 		logger.Warn("no pfSense XML configuration file")
 	}
+}
 
-	return changed, err
+// Reload parses the pfSense configuration file again.
+func (c *Config) Reload() {
+	c.Lock.Lock()
+	defer c.Lock.Unlock()
+	c.loadPfSense()
+}
+
+// PfSenseConfiguration returns the pfSense configuration in use, nil outside
+// of pfSense mode. Reload replaces the configuration rather than changing it,
+// so the returned one can be read without the lock.
+func (c *Config) PfSenseConfiguration() *pfconf.Configuration {
+	c.Lock.Lock()
+	defer c.Lock.Unlock()
+	if !c.PfSenseMode {
+		return nil
+	}
+	return c.PfSense
 }
 
 func (m Management) GetKey() inform.Key {
@@ -225,6 +322,131 @@ func (m Management) GetKey() inform.Key {
 	return keyBytes
 }
 
+func (m Management) HasPreviousKey() bool {
+	return len(m.PreviousKey) > 0
+}
+
+func (m Management) GetPreviousKey() inform.Key {
+	if !m.HasPreviousKey() {
+		return nil
+	}
+	keyBytes, err := inform.KeyFromString(m.PreviousKey)
+	if err != nil {
+		return nil
+	}
+	return keyBytes
+}
+
+// RotateKey installs a new authentication key received from the controller.
+// The current key is kept as a fallback until the new one is confirmed by
+// a successful exchange.
+func (m *Management) RotateKey(key string) {
+	if key == m.Key {
+		return
+	}
+	if !m.HasPreviousKey() {
+		m.PreviousKey = m.GetKey().String()
+	}
+	m.Key = key
+	m.usePrevious = false
+	m.fallbacks = 0
+}
+
+// InformKey returns the key to use for the next inform.
+func (m Management) InformKey() inform.Key {
+	if m.usePrevious {
+		if previous := m.GetPreviousKey(); previous != nil {
+			return previous
+		}
+	}
+	return m.GetKey()
+}
+
+// GetKeys returns the keys accepted to decode a response to a packet
+// encrypted with key, starting with key itself.
+func (m Management) GetKeys(key inform.Key) []inform.Key {
+	keys := []inform.Key{key}
+	for _, candidate := range []inform.Key{m.GetKey(), m.GetPreviousKey()} {
+		if candidate != nil && !bytes.Equal(candidate, key) {
+			keys = append(keys, candidate)
+		}
+	}
+	return keys
+}
+
+// KeyFailed records a failed exchange with key: the other key is tried for
+// the next inform.
+func (m *Management) KeyFailed(key inform.Key) {
+	if !m.HasPreviousKey() {
+		return
+	}
+	m.usePrevious = bytes.Equal(key, m.GetKey())
+	m.fallbacks = 0
+}
+
+// KeySucceeded records a successful exchange with key. It returns true
+// when the previous key has been dropped and the configuration must be saved.
+// After keyRetryInforms successes with the previous key, the new key is
+// tried again.
+func (m *Management) KeySucceeded(key inform.Key) bool {
+	if !m.HasPreviousKey() {
+		return false
+	}
+	if bytes.Equal(key, m.GetKey()) {
+		m.PreviousKey = ""
+		m.usePrevious = false
+		m.fallbacks = 0
+		return true
+	}
+	m.fallbacks++
+	m.usePrevious = m.fallbacks < keyRetryInforms
+	if !m.usePrevious {
+		m.fallbacks = 0
+	}
+	return false
+}
+
+// The inform goroutine uses the key rotation while the configuration may be
+// written: the methods below hold the configuration lock.
+
+// RotateKey installs a new authentication key, see Management.RotateKey.
+func (c *Config) RotateKey(key string) {
+	c.Lock.Lock()
+	defer c.Lock.Unlock()
+	c.Management.RotateKey(key)
+}
+
+// InformKey returns the key to use for the next inform.
+func (c *Config) InformKey() inform.Key {
+	c.Lock.Lock()
+	defer c.Lock.Unlock()
+	return c.Management.InformKey()
+}
+
+// InformKeys returns the keys accepted to decode a response to a packet
+// encrypted with key.
+func (c *Config) InformKeys(key inform.Key) []inform.Key {
+	c.Lock.Lock()
+	defer c.Lock.Unlock()
+	return c.Management.GetKeys(key)
+}
+
+// KeyFailed records a failed exchange with key, see Management.KeyFailed.
+func (c *Config) KeyFailed(key inform.Key) {
+	c.Lock.Lock()
+	defer c.Lock.Unlock()
+	c.Management.KeyFailed(key)
+}
+
+// KeySucceeded records a successful exchange with key, see
+// Management.KeySucceeded. The configuration must be written when it
+// returns true.
+func (c *Config) KeySucceeded(key inform.Key) bool {
+	c.Lock.Lock()
+	defer c.Lock.Unlock()
+	return c.Management.KeySucceeded(key)
+}
+
 func (m Management) GetCryptoMode() int {
 	if m.UseAesGcm {
 		return inform.GCM
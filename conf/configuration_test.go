@@ -0,0 +1,159 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package conf
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/COSAE-FR/ripugw/inform"
+)
+
+const (
+	oldKey = "00112233445566778899aabbccddeeff"
+	newKey = "ffeeddccbbaa99887766554433221100"
+)
+
+func mustKey(t *testing.T, key string) inform.Key {
+	t.Helper()
+	k, err := inform.KeyFromString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func assertInformKey(t *testing.T, m Management, want string) {
+	t.Helper()
+	if key := m.InformKey(); key.String() != want {
+		t.Errorf("inform key = %s, want %s", key, want)
+	}
+}
+
+func TestRotateKeyPromote(t *testing.T) {
+	m := Management{Key: oldKey}
+	m.RotateKey(newKey)
+	if m.PreviousKey != oldKey || m.Key != newKey {
+		t.Fatalf("rotated keys = %s, %s", m.Key, m.PreviousKey)
+	}
+	assertInformKey(t, m, newKey)
+	if keys := m.GetKeys(m.InformKey()); len(keys) != 2 || !bytes.Equal(keys[1], mustKey(t, oldKey)) {
+		t.Errorf("keys = %v", keys)
+	}
+
+	if !m.KeySucceeded(mustKey(t, newKey)) {
+		t.Error("confirmation of the new key not reported")
+	}
+	if m.HasPreviousKey() {
+		t.Error("previous key kept after confirmation")
+	}
+	assertInformKey(t, m, newKey)
+	if m.KeySucceeded(mustKey(t, newKey)) {
+		t.Error("confirmation reported without rotation")
+	}
+}
+
+func TestRotateKeyFromDefault(t *testing.T) {
+	var m Management
+	m.RotateKey(newKey)
+	if m.PreviousKey != inform.DefaultKey.String() {
+		t.Errorf("previous key = %s, want the default key", m.PreviousKey)
+	}
+	m.RotateKey(newKey)
+	if m.PreviousKey != inform.DefaultKey.String() || m.Key != newKey {
+		t.Errorf("same key rotation changed the keys: %s, %s", m.Key, m.PreviousKey)
+	}
+}
+
+func TestRotateKeyFallback(t *testing.T) {
+	m := Management{Key: oldKey}
+	m.RotateKey(newKey)
+
+	m.KeyFailed(mustKey(t, newKey))
+	assertInformKey(t, m, oldKey)
+
+	// The controller still answers with the previous key: keep it for a
+	// while, then try the new key again
+	for i := 1; i < keyRetryInforms; i++ {
+		if m.KeySucceeded(mustKey(t, oldKey)) {
+			t.Fatal("previous key success reported as a confirmation")
+		}
+		assertInformKey(t, m, oldKey)
+	}
+	m.KeySucceeded(mustKey(t, oldKey))
+	assertInformKey(t, m, newKey)
+	if !m.HasPreviousKey() {
+		t.Fatal("previous key dropped without confirmation")
+	}
+
+	m.KeyFailed(mustKey(t, newKey))
+	assertInformKey(t, m, oldKey)
+	if !m.KeySucceeded(mustKey(t, newKey)) {
+		t.Error("late confirmation of the new key not reported")
+	}
+	assertInformKey(t, m, newKey)
+}
+
+func TestRotateKeyFailure(t *testing.T) {
+	m := Management{Key: oldKey}
+	m.RotateKey(newKey)
+
+	// Both keys rejected: alternate between them
+	for i := 0; i < 3; i++ {
+		m.KeyFailed(m.InformKey())
+		assertInformKey(t, m, oldKey)
+		m.KeyFailed(m.InformKey())
+		assertInformKey(t, m, newKey)
+	}
+	if !m.HasPreviousKey() || m.Key != newKey {
+		t.Errorf("keys changed by failures: %s, %s", m.Key, m.PreviousKey)
+	}
+
+	// Failures without rotation keep the current key
+	stable := Management{Key: oldKey}
+	stable.KeyFailed(stable.InformKey())
+	assertInformKey(t, stable, oldKey)
+}
+
+func TestKeyRotationRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ripugw.toml")
+	config := &Config{path: path}
+	config.Management.Key = oldKey
+	config.RotateKey(newKey)
+	config.KeyFailed(config.InformKey())
+	if key := config.InformKey(); key.String() != oldKey {
+		t.Fatalf("inform key after a failure = %s, want %s", key, oldKey)
+	}
+	if err := config.Write(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the keys survive a restart: the new key is tried first again
+	restarted := &Config{path: path}
+	if err := restarted.Read(); err != nil {
+		t.Fatal(err)
+	}
+	if restarted.Management.Key != newKey || restarted.Management.PreviousKey != oldKey {
+		t.Fatalf("restored keys = %s, %s", restarted.Management.Key, restarted.Management.PreviousKey)
+	}
+	if key := restarted.InformKey(); key.String() != newKey {
+		t.Errorf("inform key after a restart = %s, want %s", key, newKey)
+	}
+	restarted.KeyFailed(restarted.InformKey())
+	if key := restarted.InformKey(); key.String() != oldKey {
+		t.Errorf("inform key after a rejection = %s, want %s", key, oldKey)
+	}
+	if keys := restarted.InformKeys(restarted.InformKey()); len(keys) != 2 {
+		t.Errorf("accepted keys = %v, want both", keys)
+	}
+	if !restarted.KeySucceeded(mustKey(t, newKey)) || restarted.Management.HasPreviousKey() {
+		t.Error("confirmation of the new key not reported")
+	}
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package conf
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	log "github.com/sirupsen/logrus"
+)
+
+const pfSenseToml = `
+[general]
+pfsense_xml = "testdata/config.xml"
+
+[pfsense_interfaces]
+
+[[pfsense_interfaces.mappings]]
+pfsense = "wan"
+role = "wan"
+
+[[pfsense_interfaces.mappings]]
+pfsense = "lan"
+role = "%s"
+`
+
+func decodePfSenseConfig(t *testing.T, lanRole string) *Config {
+	t.Helper()
+	config := &Config{Log: log.NewEntry(log.StandardLogger())}
+	if _, err := toml.Decode(fmt.Sprintf(pfSenseToml, lanRole), config); err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+func TestPfSenseMappingsTags(t *testing.T) {
+	fromToml := decodePfSenseConfig(t, "lan")
+	if fromToml.PfSenseInterfaces == nil || len(fromToml.PfSenseInterfaces.Mappings) != 2 {
+		t.Fatalf("toml mappings = %+v", fromToml.PfSenseInterfaces)
+	}
+	body, err := json.Marshal(fromToml)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromJson := &Config{}
+	if err := json.Unmarshal(body, fromJson); err != nil {
+		t.Fatal(err)
+	}
+	if fromJson.PfSenseInterfaces == nil || len(fromJson.PfSenseInterfaces.Mappings) != 2 {
+		t.Errorf("json mappings = %+v in %s", fromJson.PfSenseInterfaces, body)
+	}
+}
+
+func TestLoadPfSense(t *testing.T) {
+	config := decodePfSenseConfig(t, "lan")
+	config.loadPfSense()
+	if !config.PfSenseMode || config.PfSenseConfiguration() == nil {
+		t.Fatal("valid pfSense configuration not loaded")
+	}
+	loaded := config.PfSense
+
+	// A reload with invalid mappings keeps the configuration in use
+	config.PfSenseInterfaces.Mappings[1].Role = "dmz"
+	config.Reload()
+	if !config.PfSenseMode || config.PfSense != loaded {
+		t.Error("valid pfSense configuration replaced by an invalid reload")
+	}
+
+	invalid := decodePfSenseConfig(t, "dmz")
+	invalid.loadPfSense()
+	if invalid.PfSenseMode || invalid.PfSenseConfiguration() != nil {
+		t.Error("pfSense mode entered with invalid mappings")
+	}
+
+	missing := decodePfSenseConfig(t, "lan")
+	missing.General.PfSenseXml = filepath.Join("testdata", "missing.xml")
+	missing.loadPfSense()
+	if missing.PfSenseMode {
+		t.Error("pfSense mode entered without configuration file")
+	}
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright (c) 2020 Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package discover
+
+import (
+	"encoding/binary"
+	"errors"
+	"github.com/COSAE-FR/ripugw/inform"
+	"net"
+)
+
+// Announce is the identity of the gateway sent in discovery responses.
+type Announce struct {
+	Mac          inform.HardwareAddr
+	Ip           net.IP
+	Model        string
+	ModelDisplay string
+	Version      string
+	Hostname     string
+	Uptime       uint32
+	Default      bool
+}
+
+func AnnounceFromInform(request inform.Inform) Announce {
+	return Announce{
+		Mac:          request.Mac,
+		Ip:           net.ParseIP(request.Ip),
+		Model:        request.Model,
+		ModelDisplay: request.ModelDisplay,
+		Version:      request.Version,
+		Hostname:     request.Hostname,
+		Uptime:       uint32(request.Uptime),
+		Default:      request.Default,
+	}
+}
+
+func (a Announce) Packet() Packet {
+	p := Packet{Version: Version1, Command: CmdResponse}
+	if a.Mac.IsValid() {
+		p.Add(TypeHardwareAddr, a.Mac)
+		if ip := a.Ip.To4(); ip != nil {
+			ipInfo := make([]byte, 0, 10)
+			ipInfo = append(ipInfo, a.Mac...)
+			ipInfo = append(ipInfo, ip...)
+			p.Add(TypeIpInfo, ipInfo)
+		}
+	}
+	p.AddString(TypeFirmware, a.Version)
+	p.AddUint32(TypeUptime, a.Uptime)
+	p.AddString(TypeHostname, a.Hostname)
+	p.AddString(TypePlatform, a.Model)
+	p.AddString(TypeModel, a.ModelDisplay)
+	p.AddBool(TypeDefault, a.Default)
+	return p
+}
+
+func AnnounceFromPacket(p Packet) (Announce, error) {
+	a := Announce{}
+	if p.IsRequest() {
+		return a, errors.New("not a discovery response")
+	}
+	for _, field := range p.Fields {
+		switch field.Type {
+		case TypeHardwareAddr:
+			if len(field.Value) == 6 {
+				a.Mac = inform.HardwareAddr(field.Value)
+			}
+		case TypeIpInfo:
+			if len(field.Value) == 10 {
+				if a.Mac == nil {
+					a.Mac = inform.HardwareAddr(field.Value[:6])
+				}
+				a.Ip = net.IP(field.Value[6:10])
+			}
+		case TypeFirmware:
+			a.Version = string(field.Value)
+		case TypeUptime:
+			if len(field.Value) == 4 {
+				a.Uptime = binary.BigEndian.Uint32(field.Value)
+			}
+		case TypeHostname:
+			a.Hostname = string(field.Value)
+		case TypePlatform:
+			a.Model = string(field.Value)
+		case TypeModel:
+			a.ModelDisplay = string(field.Value)
+		case TypeDefault:
+			a.Default = len(field.Value) > 0 && field.Value[0] != 0
+		}
+	}
+	return a, nil
+}
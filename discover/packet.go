@@ -0,0 +1,153 @@
+/*
+ * Copyright (c) 2020 Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package discover
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+const (
+	Version1 byte = 1
+	Version2 byte = 2
+
+	CmdRequest  byte = 0
+	CmdResponse byte = 0
+
+	headerLength = 4
+	fieldHeader  = 3
+)
+
+// Discovery TLV types
+const (
+	TypeHardwareAddr byte = 0x01
+	TypeIpInfo       byte = 0x02
+	TypeFirmware     byte = 0x03
+	TypeUptime       byte = 0x0a
+	TypeHostname     byte = 0x0b
+	TypePlatform     byte = 0x0c
+	TypeEssid        byte = 0x0d
+	TypeWirelessMode byte = 0x0e
+	TypeSequence     byte = 0x12
+	TypeSerial       byte = 0x13
+	TypeModel        byte = 0x14
+	TypeModelV2      byte = 0x15
+	TypeShortVersion byte = 0x16
+	TypeDefault      byte = 0x17
+	TypeLocating     byte = 0x18
+	TypeDhcpc        byte = 0x19
+	TypeDhcpcBound   byte = 0x1a
+	TypeRequiredFw   byte = 0x1b
+	TypeSshdPort     byte = 0x1c
+)
+
+var RequestPacket = []byte{Version1, CmdRequest, 0x00, 0x00}
+
+type Field struct {
+	Type  byte
+	Value []byte
+}
+
+type Packet struct {
+	Version byte
+	Command byte
+	Fields  []Field
+}
+
+func (p Packet) IsRequest() bool {
+	return len(p.Fields) == 0
+}
+
+func (p *Packet) Add(fieldType byte, value []byte) {
+	p.Fields = append(p.Fields, Field{Type: fieldType, Value: value})
+}
+
+func (p *Packet) AddString(fieldType byte, value string) {
+	p.Add(fieldType, []byte(value))
+}
+
+func (p *Packet) AddUint32(fieldType byte, value uint32) {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, value)
+	p.Add(fieldType, b)
+}
+
+func (p *Packet) AddBool(fieldType byte, value bool) {
+	if value {
+		p.Add(fieldType, []byte{1})
+	} else {
+		p.Add(fieldType, []byte{0})
+	}
+}
+
+func (p Packet) Marshal() ([]byte, error) {
+	length := 0
+	for _, field := range p.Fields {
+		if len(field.Value) > 0xffff {
+			return nil, errors.New("field too long")
+		}
+		length += fieldHeader + len(field.Value)
+	}
+	if length > 0xffff {
+		return nil, errors.New("packet too long")
+	}
+	b := make([]byte, headerLength, headerLength+length)
+	b[0] = p.Version
+	b[1] = p.Command
+	binary.BigEndian.PutUint16(b[2:], uint16(length))
+	for _, field := range p.Fields {
+		header := []byte{field.Type, 0, 0}
+		binary.BigEndian.PutUint16(header[1:], uint16(len(field.Value)))
+		b = append(b, header...)
+		b = append(b, field.Value...)
+	}
+	return b, nil
+}
+
+func (p *Packet) Unmarshal(data []byte) error {
+	if len(data) < headerLength {
+		return errors.New("invalid packet length")
+	}
+	p.Version = data[0]
+	p.Command = data[1]
+	if p.Version != Version1 && p.Version != Version2 {
+		return errors.New("unknown discovery version")
+	}
+	length := int(binary.BigEndian.Uint16(data[2:]))
+	if len(data) < headerLength+length {
+		return errors.New("invalid packet length")
+	}
+	p.Fields = nil
+	data = data[headerLength : headerLength+length]
+	for len(data) > 0 {
+		if len(data) < fieldHeader {
+			return errors.New("truncated field header")
+		}
+		fieldLength := int(binary.BigEndian.Uint16(data[1:]))
+		if len(data) < fieldHeader+fieldLength {
+			return errors.New("truncated field value")
+		}
+		p.Fields = append(p.Fields, Field{
+			Type:  data[0],
+			Value: data[fieldHeader : fieldHeader+fieldLength],
+		})
+		data = data[fieldHeader+fieldLength:]
+	}
+	return nil
+}
+
+// Get returns the value of the first field of the given type.
+func (p Packet) Get(fieldType byte) ([]byte, bool) {
+	for _, field := range p.Fields {
+		if field.Type == fieldType {
+			return field.Value, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,103 @@
+/*
+ * Copyright (c) 2020 Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package discover
+
+import (
+	log "github.com/sirupsen/logrus"
+	"net"
+)
+
+const DefaultAddress = ":10001"
+
+// Responder answers Ubiquiti discovery requests with the announce returned
+// by its Identity function.
+type Responder struct {
+	Identity func() (Announce, error)
+	Log      *log.Entry
+	conn     net.PacketConn
+}
+
+func NewResponder(address string, identity func() (Announce, error), logger *log.Entry) (*Responder, error) {
+	if len(address) == 0 {
+		address = DefaultAddress
+	}
+	conn, err := net.ListenPacket("udp4", address)
+	if err != nil {
+		return nil, err
+	}
+	return &Responder{
+		Identity: identity,
+		Log:      logger.WithField("component", "discovery"),
+		conn:     conn,
+	}, nil
+}
+
+func (r *Responder) Addr() net.Addr {
+	return r.conn.LocalAddr()
+}
+
+// Serve handles discovery requests until the responder is closed.
+func (r *Responder) Serve() error {
+	buffer := make([]byte, 1500)
+	for {
+		n, addr, err := r.conn.ReadFrom(buffer)
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+			return err
+		}
+		request := Packet{}
+		if err := request.Unmarshal(buffer[:n]); err != nil {
+			r.Log.Tracef("Ignoring invalid discovery packet from %s: %v", addr, err)
+			continue
+		}
+		if !request.IsRequest() {
+			continue
+		}
+		announce, err := r.Identity()
+		if err != nil {
+			r.Log.Errorf("Cannot prepare discovery response: %v", err)
+			continue
+		}
+		response, err := announce.Packet().Marshal()
+		if err != nil {
+			r.Log.Errorf("Cannot marshal discovery response: %v", err)
+			continue
+		}
+		r.Log.Debugf("Answering discovery request from %s", addr)
+		if _, err := r.conn.WriteTo(response, addr); err != nil {
+			r.Log.Errorf("Cannot send discovery response to %s: %v", addr, err)
+		}
+	}
+}
+
+func (r *Responder) Close() error {
+	return r.conn.Close()
+}
+
+// Discover sends a discovery request to address and decodes the first
+// response received before the connection deadline.
+func Discover(conn net.PacketConn, address net.Addr) (Announce, error) {
+	if _, err := conn.WriteTo(RequestPacket, address); err != nil {
+		return Announce{}, err
+	}
+	buffer := make([]byte, 1500)
+	for {
+		n, _, err := conn.ReadFrom(buffer)
+		if err != nil {
+			return Announce{}, err
+		}
+		response := Packet{}
+		if err := response.Unmarshal(buffer[:n]); err != nil || response.IsRequest() {
+			continue
+		}
+		return AnnounceFromPacket(response)
+	}
+}
@@ -0,0 +1,111 @@
+/*
+ * Copyright (c) 2020 Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package discover
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/COSAE-FR/ripugw/inform"
+	log "github.com/sirupsen/logrus"
+)
+
+func testResponder(t *testing.T, identity func() (Announce, error)) *Responder {
+	t.Helper()
+	logger := log.New()
+	logger.SetOutput(ioutil.Discard)
+	responder, err := NewResponder("127.0.0.1:0", identity, log.NewEntry(logger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = responder.Serve()
+	}()
+	t.Cleanup(func() {
+		_ = responder.Close()
+	})
+	return responder
+}
+
+func testClient(t *testing.T) net.PacketConn {
+	t.Helper()
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	return conn
+}
+
+func TestResponderLoopback(t *testing.T) {
+	want := Announce{
+		Mac:          inform.HardwareAddr{0x02, 0x00, 0x5e, 0x10, 0x00, 0x01},
+		Ip:           net.IPv4(192, 168, 1, 1).To4(),
+		Model:        "UGW3",
+		ModelDisplay: "UniFi-Gateway-3",
+		Version:      "4.4.51.5287926",
+		Hostname:     "gateway",
+		Uptime:       3600,
+		Default:      true,
+	}
+	var calls int32
+	responder := testResponder(t, func() (Announce, error) {
+		atomic.AddInt32(&calls, 1)
+		return want, nil
+	})
+	conn := testClient(t)
+	for i := 0; i < 2; i++ {
+		_ = conn.SetDeadline(time.Now().Add(2 * time.Second))
+		got, err := Discover(conn, responder.Addr())
+		if err != nil {
+			t.Fatalf("Discover() = %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Discover() = %+v, want %+v", got, want)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("identity called %d times, want 2", n)
+	}
+}
+
+func TestResponderIgnoresInvalidRequests(t *testing.T) {
+	var calls int32
+	responder := testResponder(t, func() (Announce, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return Announce{}, errors.New("not ready")
+		}
+		return Announce{Hostname: "gateway"}, nil
+	})
+	conn := testClient(t)
+	// Garbage, a response and a request the identity cannot answer get no reply
+	for _, packet := range [][]byte{{0xff}, {Version1, CmdResponse, 0x00, 0x03, TypeHostname, 0x00, 0x00}, RequestPacket} {
+		if _, err := conn.WriteTo(packet, responder.Addr()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = conn.SetDeadline(time.Now().Add(2 * time.Second))
+	got, err := Discover(conn, responder.Addr())
+	if err != nil {
+		t.Fatalf("Discover() = %v", err)
+	}
+	if got.Hostname != "gateway" {
+		t.Errorf("hostname = %q, want gateway", got.Hostname)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("identity called %d times, want 2", n)
+	}
+}
@@ -32,6 +32,11 @@ func (m HardwareAddr) HexString() string {
 	return hex.EncodeToString(m)
 }
 
+// Vendor returns the manufacturer registered for the address prefix.
+func (m HardwareAddr) Vendor() string {
+	return DefaultOuiDatabase().Lookup(m)
+}
+
 func (m HardwareAddr) MarshalJSON() ([]byte, error) {
 	return []byte(`"` + m.String() + `"`), nil
 }
@@ -10,7 +10,6 @@ package inform
 
 import (
 	"encoding/json"
-	"strconv"
 )
 
 type Noop struct {
@@ -22,12 +21,9 @@ type Noop struct {
 func (msg *Noop) unmarshalMap(data map[string]interface{}) (err error) {
 	serverTimeInterface, ok := data["server_time_in_utc"]
 	if ok {
-		serverTimeString, ok := serverTimeInterface.(string)
-		if ok {
-			serverTime, err := strconv.Atoi(serverTimeString)
-			if err == nil {
-				msg.ServerTime = serverTime
-			}
+		serverTime, err := ParseInt(serverTimeInterface)
+		if err == nil {
+			msg.ServerTime = serverTime
 		}
 	}
 	intervalInterface, ok := data["interval"]
@@ -0,0 +1,180 @@
+/*
+ * Copyright (c) 2020 Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package inform
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//go:generate go run ./ouigen -o oui.csv.gz
+
+// bundledOui is the compressed extract of the IEEE registries written by
+// ouigen: go generate downloads them again. clients.oui_file points to a
+// more recent registry CSV.
+//
+//go:embed oui.csv.gz
+var bundledOui []byte
+
+// IEEE assignment sizes in bits, longest first for the lookup: MA-S, MA-M
+// and MA-L
+var ouiPrefixBits = []uint{36, 28, 24}
+
+// OuiDatabase maps IEEE assigned MAC prefixes to organization names.
+type OuiDatabase struct {
+	prefixes map[uint]map[uint64]string
+}
+
+func NewOuiDatabase() *OuiDatabase {
+	db := &OuiDatabase{prefixes: make(map[uint]map[uint64]string)}
+	for _, bits := range ouiPrefixBits {
+		db.prefixes[bits] = make(map[uint64]string)
+	}
+	return db
+}
+
+// LoadOuiDatabase reads an IEEE registry CSV (oui.csv, mam.csv or oas.csv),
+// compressed with gzip or not.
+func LoadOuiDatabase(r io.Reader) (*OuiDatabase, error) {
+	db := NewOuiDatabase()
+	return db, db.Load(r)
+}
+
+func LoadOuiFile(path string) (*OuiDatabase, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadOuiDatabase(f)
+}
+
+// Load adds the entries of an IEEE registry CSV to the database.
+func (db *OuiDatabase) Load(r io.Reader) error {
+	reader := bufio.NewReader(r)
+	magic, _ := reader.Peek(2)
+	var source io.Reader = reader
+	if bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		source = gz
+	}
+	records := csv.NewReader(source)
+	records.FieldsPerRecord = -1
+	header := true
+	for {
+		record, err := records.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header {
+			header = false
+			if len(record) > 0 && record[0] == "Registry" {
+				continue
+			}
+		}
+		if len(record) < 3 {
+			continue
+		}
+		db.Add(record[1], strings.TrimSpace(record[2]))
+	}
+}
+
+// Add registers an assignment given as hexadecimal digits (6, 7 or 9 digits
+// for MA-L, MA-M and MA-S).
+func (db *OuiDatabase) Add(assignment string, organization string) {
+	assignment = strings.NewReplacer(":", "", "-", "", ".", "").Replace(assignment)
+	bits := uint(len(assignment) * 4)
+	table, ok := db.prefixes[bits]
+	if !ok {
+		return
+	}
+	prefix, err := strconv.ParseUint(assignment, 16, 64)
+	if err != nil {
+		return
+	}
+	table[prefix] = organization
+}
+
+func (db *OuiDatabase) Len() int {
+	count := 0
+	for _, table := range db.prefixes {
+		count += len(table)
+	}
+	return count
+}
+
+// Lookup returns the organization owning the longest matching prefix of mac.
+func (db *OuiDatabase) Lookup(mac HardwareAddr) string {
+	if db == nil || !mac.IsValid() {
+		return ""
+	}
+	var value uint64
+	for _, b := range mac {
+		value = value<<8 | uint64(b)
+	}
+	for _, bits := range ouiPrefixBits {
+		if organization, ok := db.prefixes[bits][value>>(48-bits)]; ok {
+			return organization
+		}
+	}
+	return ""
+}
+
+var (
+	ouiDatabase     *OuiDatabase
+	ouiDatabaseOnce sync.Once
+	ouiDatabaseLock sync.RWMutex
+)
+
+// DefaultOuiDatabase returns the database used by HardwareAddr.Vendor,
+// loading the bundled prefixes on first use.
+func DefaultOuiDatabase() *OuiDatabase {
+	ouiDatabaseOnce.Do(func() {
+		db, err := LoadOuiDatabase(bytes.NewReader(bundledOui))
+		if err != nil {
+			db = NewOuiDatabase()
+		}
+		ouiDatabaseLock.Lock()
+		if ouiDatabase == nil {
+			ouiDatabase = db
+		}
+		ouiDatabaseLock.Unlock()
+	})
+	ouiDatabaseLock.RLock()
+	defer ouiDatabaseLock.RUnlock()
+	return ouiDatabase
+}
+
+// SetOuiDatabase replaces the database used by HardwareAddr.Vendor.
+func SetOuiDatabase(db *OuiDatabase) error {
+	if db == nil {
+		return errors.New("nil OUI database")
+	}
+	ouiDatabaseOnce.Do(func() {})
+	ouiDatabaseLock.Lock()
+	defer ouiDatabaseLock.Unlock()
+	ouiDatabase = db
+	return nil
+}
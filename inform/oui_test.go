@@ -0,0 +1,104 @@
+/*
+ * Copyright (c) 2020 Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package inform
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net"
+	"strings"
+	"testing"
+)
+
+const ouiRegistry = `Registry,Assignment,Organization Name,Organization Address
+MA-L,245A4C,Ubiquiti Inc,"685 Third Avenue New York NY US 10017 "
+MA-L,70B3D5,IEEE Registration Authority,445 Hoes Lane Piscataway NJ US 08554
+MA-M,70B3D51,"Example Devices, Ltd.",
+MA-S,70B3D5123,  Tiny Sensors GmbH  ,
+MA-L,XYZ123,Invalid Hex,
+MA-L,12345,Too Short,
+MA-L,00163E
+`
+
+func mac(t *testing.T, s string) HardwareAddr {
+	t.Helper()
+	addr, err := net.ParseMAC(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return HardwareAddr(addr)
+}
+
+func TestLoadOuiDatabase(t *testing.T) {
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, _ = gz.Write([]byte(ouiRegistry))
+	_ = gz.Close()
+	for name, input := range map[string][]byte{"plain": []byte(ouiRegistry), "gzip": compressed.Bytes()} {
+		t.Run(name, func(t *testing.T) {
+			db, err := LoadOuiDatabase(bytes.NewReader(input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if db.Len() != 4 {
+				t.Errorf("len = %d, want 4", db.Len())
+			}
+		})
+	}
+	if _, err := LoadOuiDatabase(strings.NewReader("MA-L,\"245A4C,broken\n")); err == nil {
+		t.Error("malformed CSV accepted")
+	}
+	headless, err := LoadOuiDatabase(strings.NewReader("MA-L,24:5A:4C,Ubiquiti Inc\n"))
+	if err != nil || headless.Len() != 1 {
+		t.Errorf("registry without header: %d entries, %v", headless.Len(), err)
+	}
+}
+
+func TestOuiLookup(t *testing.T) {
+	db, err := LoadOuiDatabase(strings.NewReader(ouiRegistry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		mac    string
+		vendor string
+	}{
+		{"24:5a:4c:01:02:03", "Ubiquiti Inc"},
+		{"70:b3:d5:12:34:56", "Tiny Sensors GmbH"},
+		{"70:b3:d5:12:44:56", "Example Devices, Ltd."},
+		{"70:b3:d5:22:34:56", "IEEE Registration Authority"},
+		{"00:16:3e:00:00:01", ""},
+		{"02:00:00:00:00:01", ""},
+	}
+	for _, test := range tests {
+		if vendor := db.Lookup(mac(t, test.mac)); vendor != test.vendor {
+			t.Errorf("Lookup(%s) = %q, want %q", test.mac, vendor, test.vendor)
+		}
+	}
+	if vendor := db.Lookup(HardwareAddr{0x24, 0x5a, 0x4c, 0x01, 0x02}); vendor != "" {
+		t.Errorf("short address lookup = %q", vendor)
+	}
+	var empty *OuiDatabase
+	if vendor := empty.Lookup(mac(t, "24:5a:4c:01:02:03")); vendor != "" {
+		t.Errorf("nil database lookup = %q", vendor)
+	}
+}
+
+func TestBundledOuiDatabase(t *testing.T) {
+	db, err := LoadOuiDatabase(bytes.NewReader(bundledOui))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.Len() < 30000 {
+		t.Errorf("bundled database holds %d assignments only", db.Len())
+	}
+	if vendor := db.Lookup(mac(t, "24:5a:4c:01:02:03")); !strings.HasPrefix(vendor, "Ubiquiti") {
+		t.Errorf("bundled vendor of 24:5a:4c = %q", vendor)
+	}
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright (c) 2020 Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+// Command ouigen downloads the IEEE MAC address registries (MA-L, MA-M and
+// MA-S) and writes their registry, assignment and organization columns as
+// a single compressed CSV suitable for the inform OUI database.
+//
+// Registry CSV files given as arguments are read instead of the downloads:
+//
+//	ouigen -o oui.csv.gz oui.csv mam.csv oui36.csv
+package main
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+var registries = []string{
+	"https://standards-oui.ieee.org/oui/oui.csv",
+	"https://standards-oui.ieee.org/oui28/mam.csv",
+	"https://standards-oui.ieee.org/oui36/oui36.csv",
+}
+
+func main() {
+	output := flag.String("o", "oui.csv.gz", "output file")
+	flag.Parse()
+	sources := registries
+	if flag.NArg() > 0 {
+		sources = flag.Args()
+	}
+
+	f, err := os.Create(*output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot create %s: %v\n", *output, err)
+		os.Exit(1)
+	}
+	gz, _ := gzip.NewWriterLevel(f, gzip.BestCompression)
+	records := csv.NewWriter(gz)
+	_ = records.Write([]string{"Registry", "Assignment", "Organization Name"})
+	for _, source := range sources {
+		count, err := extract(records, source)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cannot read %s: %v\n", source, err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s: %d assignments\n", source, count)
+	}
+	records.Flush()
+	if err := records.Error(); err != nil {
+		fmt.Fprintf(os.Stderr, "cannot write %s: %v\n", *output, err)
+		os.Exit(1)
+	}
+	if err := gz.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "cannot compress %s: %v\n", *output, err)
+		os.Exit(1)
+	}
+	if err := f.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "cannot write %s: %v\n", *output, err)
+		os.Exit(1)
+	}
+}
+
+func open(source string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return os.Open(source)
+	}
+	resp, err := http.Get(source)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+// extract copies the assignments of a registry CSV to w, without the
+// header and the organization addresses.
+func extract(w *csv.Writer, source string) (int, error) {
+	r, err := open(source)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	records := csv.NewReader(r)
+	records.FieldsPerRecord = -1
+	count := 0
+	for {
+		record, err := records.Read()
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+		if len(record) < 3 || record[0] == "Registry" {
+			continue
+		}
+		if err := w.Write([]string{record[0], record[1], strings.TrimSpace(record[2])}); err != nil {
+			return count, err
+		}
+		count++
+	}
+}
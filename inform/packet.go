@@ -67,6 +67,10 @@ func (p Packet) IsGcmEncrypted() bool {
 	return p.IsEncrypted() && p.flags&GcmFlag == GcmFlag
 }
 
+func (p Packet) Key() Key {
+	return p.key
+}
+
 func (p Packet) IsZLib() bool {
 	return p.flags&ZlibFlag == ZlibFlag
 }
@@ -185,3 +189,29 @@ func (p *Packet) Unmarshal(data []byte, keyFetcher func(addr HardwareAddr) (Key,
 	p.Msg, err = Unmarshal(msg)
 	return err
 }
+
+// UnmarshalWithKeys decodes data like Unmarshal but tries every key returned
+// by keysFetcher, in order, until one of them decrypts the packet.
+func (p *Packet) UnmarshalWithKeys(data []byte, keysFetcher func(addr HardwareAddr) ([]Key, error)) (err error) {
+	var keys []Key
+	fetched := false
+	for i := 0; !fetched || i < len(keys); i++ {
+		err = p.Unmarshal(data, func(addr HardwareAddr) (Key, error) {
+			if !fetched {
+				fetched = true
+				keys, err = keysFetcher(addr)
+				if err != nil {
+					return nil, err
+				}
+			}
+			if len(keys) == 0 {
+				return nil, errors.New("No key available.")
+			}
+			return keys[i], nil
+		})
+		if err == nil || !fetched {
+			return err
+		}
+	}
+	return err
+}
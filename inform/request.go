@@ -34,6 +34,12 @@ type EthernetTableEntry struct {
 const NetworkConfigDisabled = "disabled"
 const NetworkConfigDhcp = "dhcp"
 const NetworkConfigStatic = "static"
+const NetworkConfigPppoe = "pppoe"
+
+// IPv6 network config types
+const NetworkConfigDhcpv6 = "dhcpv6"
+const NetworkConfigSlaac = "slaac"
+const NetworkConfigTrack = "track"
 
 type NetworkConfig struct {
 	Type    string `json:"type"`
@@ -43,17 +49,73 @@ type NetworkConfig struct {
 	Dns1    string `json:"dns1,omitempty"`
 	Dns2    string `json:"dns2,omitempty"`
 	IfName  string `json:"ifname,omitempty"`
+	// IPv6
+	Type6    string `json:"type6,omitempty"`
+	Ip6      string `json:"ip6,omitempty"`
+	Gateway6 string `json:"gateway6,omitempty"`
+	// Active lease of a DHCP WAN
+	Lease *DhcpLease `json:"dhcp_lease,omitempty"`
+	// PPPoE
+	Username string `json:"username,omitempty"`
+	Mtu      int    `json:"mtu,omitempty"`
+}
+
+type DhcpLease struct {
+	Ip          string   `json:"ip"`
+	Netmask     string   `json:"netmask,omitempty"`
+	Gateway     string   `json:"gateway,omitempty"`
+	Nameservers []string `json:"dns,omitempty"`
+	DomainName  string   `json:"domain_name,omitempty"`
+	Server      string   `json:"dhcp_server,omitempty"`
+	Start       int64    `json:"lease_start,omitempty"`
+	Expire      int64    `json:"lease_expire,omitempty"`
+}
+
+type networkConfigIpv6 struct {
+	Type6    string `json:"type6,omitempty"`
+	Ip6      string `json:"ip6,omitempty"`
+	Gateway6 string `json:"gateway6,omitempty"`
+}
+
+func (n NetworkConfig) ipv6() networkConfigIpv6 {
+	return networkConfigIpv6{
+		Type6:    n.Type6,
+		Ip6:      n.Ip6,
+		Gateway6: n.Gateway6,
+	}
 }
 
 func (n NetworkConfig) MarshalJSON() ([]byte, error) {
 	switch n.Type {
 	case NetworkConfigDhcp, NetworkConfigDisabled:
 		return json.Marshal(&struct {
-			Type   string `json:"type,omitempty"`
-			IfName string `json:"ifname,omitempty"`
+			Type   string     `json:"type,omitempty"`
+			IfName string     `json:"ifname,omitempty"`
+			Lease  *DhcpLease `json:"dhcp_lease,omitempty"`
+			networkConfigIpv6
+		}{
+			Type:              n.Type,
+			IfName:            n.IfName,
+			Lease:             n.Lease,
+			networkConfigIpv6: n.ipv6(),
+		})
+	case NetworkConfigPppoe:
+		return json.Marshal(&struct {
+			Type     string `json:"type"`
+			Username string `json:"username,omitempty"`
+			Mtu      int    `json:"mtu,omitempty"`
+			Ip       string `json:"ip,omitempty"`
+			Gateway  string `json:"gateway,omitempty"`
+			IfName   string `json:"ifname,omitempty"`
+			networkConfigIpv6
 		}{
-			Type:   n.Type,
-			IfName: n.IfName,
+			Type:              n.Type,
+			Username:          n.Username,
+			Mtu:               n.Mtu,
+			Ip:                n.Ip,
+			Gateway:           n.Gateway,
+			IfName:            n.IfName,
+			networkConfigIpv6: n.ipv6(),
 		})
 	case NetworkConfigStatic:
 		if len(n.Ip) == 0 {
@@ -68,8 +130,10 @@ func (n NetworkConfig) MarshalJSON() ([]byte, error) {
 	default:
 		return json.Marshal(&struct {
 			Type string `json:"type,omitempty"`
+			networkConfigIpv6
 		}{
-			Type: NetworkConfigDisabled,
+			Type:              NetworkConfigDisabled,
+			networkConfigIpv6: n.ipv6(),
 		})
 
 	}
@@ -81,41 +145,122 @@ func (n NetworkConfig) MarshalJSON() ([]byte, error) {
 		Dns1    string `json:"dns1,omitempty"`
 		Dns2    string `json:"dns2,omitempty"`
 		IfName  string `json:"ifname,omitempty"`
+		networkConfigIpv6
 	}{
-		Type:    n.Type,
-		Ip:      n.Ip,
-		Netmask: n.Netmask,
-		Gateway: n.Gateway,
-		Dns1:    n.Dns1,
-		Dns2:    n.Dns2,
-		IfName:  n.IfName,
+		Type:              n.Type,
+		Ip:                n.Ip,
+		Netmask:           n.Netmask,
+		Gateway:           n.Gateway,
+		Dns1:              n.Dns1,
+		Dns2:              n.Dns2,
+		IfName:            n.IfName,
+		networkConfigIpv6: n.ipv6(),
 	})
 }
 
 type Interface struct {
-	FullDuplex  bool         `json:"full_duplex"`
-	Ip          string       `json:"ip"`
-	Mac         HardwareAddr `json:"mac"`
-	Name        string       `json:"name"`
-	Netmask     string       `json:"netmask"`
-	NumPort     int          `json:"num_port"`
-	RxBytes     uint64       `json:"rx_bytes"`
-	RxDropped   uint64       `json:"rx_dropped"`
-	RxErrors    uint64       `json:"rx_errors"`
-	RxMulticast int          `json:"rx_multicast"`
-	RxPackets   uint64       `json:"rx_packets"`
-	Speed       uint64       `json:"speed"`
-	TxBytes     uint64       `json:"tx_bytes"`
-	TxDropped   uint64       `json:"tx_dropped"`
-	TxErrors    uint64       `json:"tx_errors"`
-	TxPackets   uint64       `json:"tx_packets"`
-	Up          bool         `json:"up"`
-	Enabled     bool         `json:"enabled"`
-	Drops       uint64       `json:"drops"`
-	Latency     uint64       `json:"latency"`
-	Uptime      uint64       `json:"uptime"`
-	Nameservers []string     `json:"namservers"`
-	Gateways    []string     `json:"gateways"`
+	FullDuplex    bool         `json:"full_duplex"`
+	Ip            string       `json:"ip"`
+	Mac           HardwareAddr `json:"mac"`
+	Name          string       `json:"name"`
+	Netmask       string       `json:"netmask"`
+	Ipv6          []string     `json:"ipv6,omitempty"`
+	Mtu           int          `json:"mtu,omitempty"`
+	NumPort       int          `json:"num_port"`
+	RxBytes       uint64       `json:"rx_bytes"`
+	RxDropped     uint64       `json:"rx_dropped"`
+	RxErrors      uint64       `json:"rx_errors"`
+	RxMulticast   int          `json:"rx_multicast"`
+	RxPackets     uint64       `json:"rx_packets"`
+	Speed         uint64       `json:"speed"`
+	TxBytes       uint64       `json:"tx_bytes"`
+	TxDropped     uint64       `json:"tx_dropped"`
+	TxErrors      uint64       `json:"tx_errors"`
+	TxPackets     uint64       `json:"tx_packets"`
+	RxBytesRate   float64      `json:"rx_bytes-r"`
+	TxBytesRate   float64      `json:"tx_bytes-r"`
+	RxPacketsRate float64      `json:"rx_packets-r"`
+	TxPacketsRate float64      `json:"tx_packets-r"`
+	Up            bool         `json:"up"`
+	Enabled       bool         `json:"enabled"`
+	Drops         uint64       `json:"drops"`
+	Latency       uint64       `json:"latency"`
+	Uptime        uint64       `json:"uptime"`
+	Nameservers   []string     `json:"namservers"`
+	SearchDomains []string     `json:"search_domains,omitempty"`
+	Gateways      []string     `json:"gateways"`
+}
+
+type Host struct {
+	Mac        HardwareAddr `json:"mac"`
+	Ip         string       `json:"ip,omitempty"`
+	Hostname   string       `json:"hostname,omitempty"`
+	Name       string       `json:"name,omitempty"`
+	Vendor     string       `json:"oui,omitempty"`
+	Note       string       `json:"note,omitempty"`
+	UseFixedIp bool         `json:"use_fixedip,omitempty"`
+	FixedIp    string       `json:"fixed_ip,omitempty"`
+	Age        uint64       `json:"age"`
+	Uptime     uint64       `json:"uptime"`
+	Authorized bool         `json:"authorized"`
+}
+
+type Network struct {
+	Name           string       `json:"name"`
+	Description    string       `json:"descr,omitempty"`
+	Mac            HardwareAddr `json:"mac"`
+	Ip             string       `json:"ip,omitempty"`
+	Netmask        string       `json:"netmask,omitempty"`
+	Address        string       `json:"address,omitempty"`
+	Up             bool         `json:"up"`
+	NumSta         int          `json:"num_sta"`
+	DhcpdEnabled   bool         `json:"dhcpd_enabled"`
+	DhcpdStart     string       `json:"dhcpd_start,omitempty"`
+	DhcpdStop      string       `json:"dhcpd_stop,omitempty"`
+	DhcpdLeaseTime int          `json:"dhcpd_leasetime,omitempty"`
+	DhcpdGateway   string       `json:"dhcpd_gateway,omitempty"`
+	DhcpdDns       []string     `json:"dhcpd_dns,omitempty"`
+	DhcpdStatic    int          `json:"dhcpd_num_static,omitempty"`
+	DomainName     string       `json:"domain_name,omitempty"`
+	VlanEnabled    bool         `json:"vlan_enabled,omitempty"`
+	Vlan           int          `json:"vlan,omitempty"`
+	Ipv6           []string     `json:"ipv6,omitempty"`
+	// pd, static or none
+	Ipv6Type       string `json:"ipv6_interface_type,omitempty"`
+	Ipv6PdIf       string `json:"ipv6_pd_interface,omitempty"`
+	Ipv6PdPrefixId string `json:"ipv6_pd_prefixid,omitempty"`
+	Ipv6Prefix     string `json:"ipv6_prefix,omitempty"`
+	HostTable      []Host `json:"host_table"`
+}
+
+type UptimeMonitor struct {
+	Availability   float64 `json:"availability"`
+	LatencyAverage float64 `json:"latency_average"`
+	JitterAverage  float64 `json:"jitter_average,omitempty"`
+	Target         string  `json:"target"`
+	Type           string  `json:"type"`
+}
+
+type UptimeStat struct {
+	Availability   float64         `json:"availability"`
+	LatencyAverage float64         `json:"latency_average"`
+	JitterAverage  float64         `json:"jitter_average,omitempty"`
+	TimePeriod     uint64          `json:"time_period"`
+	Monitors       []UptimeMonitor `json:"monitors,omitempty"`
+}
+
+// RouteNextHop type is the zebra route code: K kernel, C connected,
+// S static, followed by > when selected and * when in the FIB.
+type RouteNextHop struct {
+	Interface string `json:"intf,omitempty"`
+	Metric    string `json:"metric,omitempty"`
+	Type      string `json:"t"`
+	Via       string `json:"via,omitempty"`
+}
+
+type Route struct {
+	Prefix   string         `json:"pfx"`
+	NextHops []RouteNextHop `json:"nh"`
 }
 
 type Port struct {
@@ -157,20 +302,25 @@ type Inform struct {
 	Model        string       `json:"model"`
 	ModelDisplay string       `json:"model_display"`
 	Netmask      string       `json:"netmask"`
+	NetworkTable []Network    `json:"network_table,omitempty"`
 	QrId         string       `json:"qrid,omitempty"`
+	RouteTable   []Route      `json:"route_table,omitempty"`
 	//RadioTable           []Radio     `json:"radio_table"`
-	PortTable          []Port   `json:"config_port_table"`
-	RadiusCapabilities int32    `json:"radius_caps"`
-	RequiredVersion    string   `json:"required_version"`
-	SelfrunBeacon      bool     `json:"selfrun_beacon"`
-	Serial             string   `json:"serial"`
-	SpectrumScanning   bool     `json:"spectrum_scanning,omitempty"`
-	State              int      `json:"state"`
-	StreamToken        string   `json:"stream_token,omitempty"`
-	SysStats           SysStats `json:"system-stats"`
-	Time               int64    `json:"time"`
-	Uplink             string   `json:"uplink"`
-	Uptime             uint64   `json:"uptime"`
+	PortTable          []Port                `json:"config_port_table"`
+	RadiusCapabilities int32                 `json:"radius_caps"`
+	RequiredVersion    string                `json:"required_version"`
+	SelfrunBeacon      bool                  `json:"selfrun_beacon"`
+	Serial             string                `json:"serial"`
+	SpectrumScanning   bool                  `json:"spectrum_scanning,omitempty"`
+	State              int                   `json:"state"`
+	StreamToken        string                `json:"stream_token,omitempty"`
+	SysStats           SysStats              `json:"system-stats"`
+	Time               int64                 `json:"time"`
+	Uplink             string                `json:"uplink"`
+	ActiveWan          string                `json:"active_wan,omitempty"`
+	WanMode            string                `json:"wan_mode,omitempty"`
+	Uptime             uint64                `json:"uptime"`
+	UptimeStats        map[string]UptimeStat `json:"uptime_stats,omitempty"`
 	//VApTable             []VAp       `json:"vap_table"`
 	Version string `json:"version"`
 	//WifiCapabilities     int         `json:"wifi_caps"`
@@ -44,6 +44,20 @@ func (r httpResponse) String() string {
 	return fmt.Sprintf(`{"code":%d}`, r.code)
 }
 
+// ServerTime returns the server_time_in_utc carried by a controller message.
+func ServerTime(msg Message) (int64, bool) {
+	var serverTime int
+	switch m := msg.(type) {
+	case *Noop:
+		serverTime = m.ServerTime
+	case *SetParam:
+		serverTime = m.ServerTime
+	case *Cmd:
+		serverTime = m.ServerTime
+	}
+	return int64(serverTime), serverTime > 0
+}
+
 func ParseString(value interface{}) (string, error) {
 	result, ok := value.(string)
 	if ok {
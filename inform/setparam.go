@@ -37,8 +37,8 @@ func (msg *SetParam) unmarshalMap(data map[string]interface{}) (err error) {
 				if err != nil {
 					return err
 				}
-			} else if mgmt, ok := rawValue.(int); ok {
-				msg.ServerTime = mgmt
+			} else if serverTime, err := ParseInt(rawValue); err == nil {
+				msg.ServerTime = serverTime
 			}
 		}
 	}
@@ -10,21 +10,30 @@ package pfconf
 
 import (
 	"encoding/xml"
+	"fmt"
+	"net"
+	"strconv"
 	"strings"
 )
 
 type Configuration struct {
-	XMLName     xml.Name   `xml:"pfsense"`
-	FileVersion string     `xml:"version"`
-	System      System     `xml:"system"`
-	Interfaces  Interfaces `xml:"interfaces"`
-	Routes      []Route    `xml:"staticroutes>route"`
-	Syslog      Syslog     `xml:"syslog"`
-	Revision    Revision   `xml:"revision"`
-	Gateways    []Gateway  `xml:"gateways>gateway_item"`
-	GatewayIpv4 string     `xml:"gateways>defaultgw4"`
-	GatewayIpv6 string     `xml:"gateways>defaultgw6"`
-	SysCtls     []SysCtl   `xml:"sysctl>item"`
+	XMLName       xml.Name       `xml:"pfsense"`
+	FileVersion   string         `xml:"version"`
+	System        System         `xml:"system"`
+	Interfaces    Interfaces     `xml:"interfaces"`
+	Routes        []Route        `xml:"staticroutes>route"`
+	Syslog        Syslog         `xml:"syslog"`
+	Revision      Revision       `xml:"revision"`
+	Gateways      []Gateway      `xml:"gateways>gateway_item"`
+	GatewayGroups []GatewayGroup `xml:"gateways>gateway_group"`
+	GatewayIpv4   string         `xml:"gateways>defaultgw4"`
+	GatewayIpv6   string         `xml:"gateways>defaultgw6"`
+	SysCtls       []SysCtl       `xml:"sysctl>item"`
+	Dhcpd         Dhcpd          `xml:"dhcpd"`
+	Ppps          []Ppp          `xml:"ppps>ppp"`
+	Vlans         []Vlan         `xml:"vlans>vlan"`
+	Laggs         []Lagg         `xml:"laggs>lagg"`
+	Bridges       []Bridge       `xml:"bridges>bridged"`
 }
 
 func (c *Configuration) Finalize() error {
@@ -54,8 +63,17 @@ type Interfaces struct {
 	List []Interface `xml:",any"`
 }
 
+// BoolIfElementPresent is a pfSense flag, set by the presence of its
+// element: pfSense writes <enable></enable> or <enable/> and drops the
+// element when unset. The default bool decoding reads that empty content
+// as false, so flags are decoded from the element alone, whatever it holds.
 type BoolIfElementPresent bool
 
+func (b *BoolIfElementPresent) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*b = true
+	return d.Skip()
+}
+
 type Interface struct {
 	XMLName     xml.Name
 	If          string               `xml:"if"`
@@ -66,6 +84,12 @@ type Interface struct {
 	Ip          string               `xml:"ipaddr"`
 	Subnet      uint8                `xml:"subnet"`
 	Gateway     string               `xml:"gateway"`
+	// IPv6 settings: ipaddrv6 is a static address or dhcp6, slaac, track6, 6rd, 6to4
+	Ipv6           string `xml:"ipaddrv6"`
+	Subnetv6       uint8  `xml:"subnetv6"`
+	Gatewayv6      string `xml:"gatewayv6"`
+	Track6If       string `xml:"track6-interface"`
+	Track6PrefixId string `xml:"track6-prefix-id"`
 }
 
 type Route struct {
@@ -101,6 +125,51 @@ type Gateway struct {
 	Description    string               `xml:"descr"`
 	MonitorDisable BoolIfElementPresent `xml:"monitor_disable"`
 	ActionDisable  BoolIfElementPresent `xml:"action_disable"`
+	Monitor        string               `xml:"monitor"`
+	LatencyLow     string               `xml:"latencylow"`
+	LatencyHigh    string               `xml:"latencyhigh"`
+	LossLow        string               `xml:"losslow"`
+	LossHigh       string               `xml:"losshigh"`
+}
+
+type GatewayGroup struct {
+	Name        string   `xml:"name"`
+	Items       []string `xml:"item"`
+	Trigger     string   `xml:"trigger"`
+	Description string   `xml:"descr"`
+}
+
+type GatewayGroupMember struct {
+	Gateway string
+	Tier    int
+	Vip     string
+}
+
+// Members decodes the "gateway|tier|vip" items of the group.
+func (g GatewayGroup) Members() []GatewayGroupMember {
+	var members []GatewayGroupMember
+	for _, item := range g.Items {
+		parts := strings.Split(item, "|")
+		member := GatewayGroupMember{Gateway: parts[0]}
+		if len(parts) > 1 {
+			member.Tier, _ = strconv.Atoi(parts[1])
+		}
+		if len(parts) > 2 {
+			member.Vip = parts[2]
+		}
+		members = append(members, member)
+	}
+	return members
+}
+
+// Member returns the group member using the gateway name.
+func (g GatewayGroup) Member(name string) (GatewayGroupMember, bool) {
+	for _, member := range g.Members() {
+		if member.Gateway == name {
+			return member, true
+		}
+	}
+	return GatewayGroupMember{}, false
 }
 
 type SysCtl struct {
@@ -108,3 +177,169 @@ type SysCtl struct {
 	Value       string `xml:"value"`
 	Description string `xml:"descr"`
 }
+
+type Dhcpd struct {
+	List []DhcpServer `xml:",any"`
+}
+
+// Get returns the DHCP server settings of the pfSense interface name (lan, opt1...).
+func (d Dhcpd) Get(name string) (DhcpServer, bool) {
+	for _, server := range d.List {
+		if server.XMLName.Local == name {
+			return server, true
+		}
+	}
+	return DhcpServer{}, false
+}
+
+type DhcpServer struct {
+	XMLName          xml.Name
+	Enable           BoolIfElementPresent `xml:"enable"`
+	RangeFrom        string               `xml:"range>from"`
+	RangeTo          string               `xml:"range>to"`
+	DefaultLeaseTime string               `xml:"defaultleasetime"`
+	MaxLeaseTime     string               `xml:"maxleasetime"`
+	Domain           string               `xml:"domain"`
+	DnsServers       []string             `xml:"dnsserver"`
+	Gateway          string               `xml:"gateway"`
+	StaticMaps       []StaticMap          `xml:"staticmap"`
+}
+
+// StaticMaps returns the DHCP static mappings of every interface.
+func (d Dhcpd) StaticMaps() []StaticMap {
+	var maps []StaticMap
+	for _, server := range d.List {
+		maps = append(maps, server.StaticMaps...)
+	}
+	return maps
+}
+
+type StaticMap struct {
+	Mac         string `xml:"mac"`
+	ClientId    string `xml:"cid"`
+	Ip          string `xml:"ipaddr"`
+	Hostname    string `xml:"hostname"`
+	Description string `xml:"descr"`
+}
+
+func (m StaticMap) HardwareAddr() (net.HardwareAddr, error) {
+	return net.ParseMAC(strings.TrimSpace(m.Mac))
+}
+
+// Ppp is a PPP link (pppoe, pptp, l2tp, ppp) of the ppps section.
+type Ppp struct {
+	PtpId       string `xml:"ptpid"`
+	Type        string `xml:"type"`
+	If          string `xml:"if"`
+	PortList    string `xml:"ports"`
+	Username    string `xml:"username"`
+	Password    string `xml:"password"`
+	Provider    string `xml:"provider"`
+	Mtu         string `xml:"mtu"`
+	Mru         string `xml:"mru"`
+	Description string `xml:"descr"`
+}
+
+// Ports returns the physical interfaces the link runs over.
+func (p Ppp) Ports() []string {
+	return splitList(p.PortList)
+}
+
+// Ppp returns the PPP link of an interface, given either its pppN device
+// or one of its ports.
+func (c Configuration) Ppp(ifName string) (Ppp, bool) {
+	for _, ppp := range c.Ppps {
+		if ppp.If == ifName {
+			return ppp, true
+		}
+	}
+	for _, ppp := range c.Ppps {
+		for _, port := range ppp.Ports() {
+			if port == ifName {
+				return ppp, true
+			}
+		}
+	}
+	return Ppp{}, false
+}
+
+// Vlan is a tagged sub-interface of a parent interface.
+type Vlan struct {
+	If          string `xml:"if"`
+	Tag         int    `xml:"tag"`
+	Pcp         string `xml:"pcp"`
+	Description string `xml:"descr"`
+	VlanIf      string `xml:"vlanif"`
+}
+
+// Device returns the system name of the VLAN interface: vlanif, or
+// parent.tag on older configurations.
+func (v Vlan) Device() string {
+	if len(v.VlanIf) > 0 {
+		return v.VlanIf
+	}
+	return fmt.Sprintf("%s.%d", v.If, v.Tag)
+}
+
+// Vlan returns the VLAN defining a device.
+func (c Configuration) Vlan(device string) (Vlan, bool) {
+	for _, vlan := range c.Vlans {
+		if vlan.Device() == device {
+			return vlan, true
+		}
+	}
+	return Vlan{}, false
+}
+
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Lagg is a link aggregation of physical interfaces.
+type Lagg struct {
+	MemberList  string `xml:"members"`
+	Description string `xml:"descr"`
+	LaggIf      string `xml:"laggif"`
+	Proto       string `xml:"proto"`
+}
+
+// Members returns the member devices.
+func (l Lagg) Members() []string {
+	return splitList(l.MemberList)
+}
+
+// Bridge bridges pfSense interfaces together.
+type Bridge struct {
+	MemberList  string `xml:"members"`
+	Description string `xml:"descr"`
+	BridgeIf    string `xml:"bridgeif"`
+}
+
+// Members returns the pfSense names (lan, opt1...) of the member interfaces.
+func (b Bridge) Members() []string {
+	return splitList(b.MemberList)
+}
+
+func (c Configuration) Lagg(device string) (Lagg, bool) {
+	for _, lagg := range c.Laggs {
+		if lagg.LaggIf == device {
+			return lagg, true
+		}
+	}
+	return Lagg{}, false
+}
+
+func (c Configuration) Bridge(device string) (Bridge, bool) {
+	for _, bridge := range c.Bridges {
+		if bridge.BridgeIf == device {
+			return bridge, true
+		}
+	}
+	return Bridge{}, false
+}
@@ -0,0 +1,185 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package pfconf
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func loadFixture(t *testing.T) Configuration {
+	t.Helper()
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "config.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var configuration Configuration
+	if err := xml.Unmarshal(data, &configuration); err != nil {
+		t.Fatal(err)
+	}
+	if err := configuration.Finalize(); err != nil {
+		t.Fatal(err)
+	}
+	return configuration
+}
+
+func TestBoolIfElementPresent(t *testing.T) {
+	tests := []struct {
+		document string
+		want     bool
+	}{
+		{"<interface><enable></enable></interface>", true},
+		{"<interface><enable/></interface>", true},
+		{"<interface><enable>yes</enable></interface>", true},
+		{"<interface><enable>0</enable></interface>", true},
+		{"<interface></interface>", false},
+		{"<interface><blockbogons/></interface>", false},
+	}
+	for _, test := range tests {
+		var iface struct {
+			Enable BoolIfElementPresent `xml:"enable"`
+		}
+		if err := xml.Unmarshal([]byte(test.document), &iface); err != nil {
+			t.Errorf("Unmarshal(%s) = %v", test.document, err)
+			continue
+		}
+		if bool(iface.Enable) != test.want {
+			t.Errorf("Unmarshal(%s) = %v, want %v", test.document, iface.Enable, test.want)
+		}
+	}
+}
+
+func TestConfigurationInterfaces(t *testing.T) {
+	configuration := loadFixture(t)
+	tests := []struct {
+		name        string
+		device      string
+		enable      bool
+		blockBogons bool
+	}{
+		{"wan", "pppoe0", true, true},
+		{"lan", "lagg0", true, false},
+		{"opt1", "lagg0.10", false, false},
+		{"opt2", "bridge0", true, false},
+	}
+	if len(configuration.Interfaces.List) != len(tests) {
+		t.Fatalf("got %d interfaces, want %d", len(configuration.Interfaces.List), len(tests))
+	}
+	for i, test := range tests {
+		iface := configuration.Interfaces.List[i]
+		if iface.XMLName.Local != test.name || iface.If != test.device || bool(iface.Enable) != test.enable || bool(iface.BlockBogons) != test.blockBogons {
+			t.Errorf("interface %d = %s on %s enable %v bogons %v, want %+v", i, iface.XMLName.Local, iface.If, iface.Enable, iface.BlockBogons, test)
+		}
+	}
+	if !reflect.DeepEqual(configuration.System.DnsServers, []string{"192.0.2.53", "192.0.2.54"}) {
+		t.Errorf("DNS servers = %v", configuration.System.DnsServers)
+	}
+	if len(configuration.Gateways) != 1 || !bool(configuration.Gateways[0].MonitorDisable) || bool(configuration.Gateways[0].ActionDisable) {
+		t.Errorf("gateways = %+v, want WAN_PPPOE with the monitor disabled", configuration.Gateways)
+	}
+}
+
+func TestDhcpd(t *testing.T) {
+	configuration := loadFixture(t)
+	lan, ok := configuration.Dhcpd.Get("lan")
+	if !ok {
+		t.Fatal("no lan DHCP server")
+	}
+	if !bool(lan.Enable) || lan.RangeFrom != "192.168.1.100" || lan.RangeTo != "192.168.1.199" || lan.DefaultLeaseTime != "3600" {
+		t.Errorf("lan = %+v", lan)
+	}
+	if !reflect.DeepEqual(lan.DnsServers, []string{"192.168.1.2", "192.168.1.3"}) {
+		t.Errorf("lan DNS servers = %v", lan.DnsServers)
+	}
+	opt1, ok := configuration.Dhcpd.Get("opt1")
+	if !ok || bool(opt1.Enable) {
+		t.Errorf("opt1 = %+v, %v, want a disabled server", opt1, ok)
+	}
+	if _, ok := configuration.Dhcpd.Get("opt2"); ok {
+		t.Error("opt2 has a DHCP server")
+	}
+
+	maps := configuration.Dhcpd.StaticMaps()
+	if len(maps) != 2 {
+		t.Fatalf("got %d static maps, want 2", len(maps))
+	}
+	want := StaticMap{Mac: " 00:11:22:aa:bb:01 ", Ip: "192.168.1.10", Hostname: "nas", Description: "Storage"}
+	if maps[0] != want {
+		t.Errorf("static map = %+v, want %+v", maps[0], want)
+	}
+	if mac, err := maps[0].HardwareAddr(); err != nil || mac.String() != "00:11:22:aa:bb:01" {
+		t.Errorf("HardwareAddr() = %s, %v", mac, err)
+	}
+	if maps[1].ClientId != "client" {
+		t.Errorf("static map = %+v, want the client id", maps[1])
+	}
+	if _, err := maps[1].HardwareAddr(); err == nil {
+		t.Error("HardwareAddr() without a MAC address succeeded")
+	}
+}
+
+func TestPpps(t *testing.T) {
+	configuration := loadFixture(t)
+	for _, name := range []string{"pppoe0", "em0", "em1"} {
+		ppp, ok := configuration.Ppp(name)
+		if !ok || ppp.If != "pppoe0" {
+			t.Errorf("Ppp(%s) = %+v, %v, want pppoe0", name, ppp, ok)
+		}
+	}
+	ppp, _ := configuration.Ppp("pppoe0")
+	if ppp.Type != "pppoe" || ppp.Username != "user@isp.example" || ppp.Mtu != "1480,1500" || !reflect.DeepEqual(ppp.Ports(), []string{"em0", "em1"}) {
+		t.Errorf("pppoe0 = %+v", ppp)
+	}
+	if _, ok := configuration.Ppp("em2"); ok {
+		t.Error("Ppp(em2) found a link")
+	}
+}
+
+func TestVlans(t *testing.T) {
+	configuration := loadFixture(t)
+	tests := []struct {
+		device string
+		parent string
+		tag    int
+	}{
+		{"lagg0.10", "lagg0", 10},
+		// No vlanif on older configurations
+		{"em4.20", "em4", 20},
+	}
+	for _, test := range tests {
+		vlan, ok := configuration.Vlan(test.device)
+		if !ok || vlan.If != test.parent || vlan.Tag != test.tag {
+			t.Errorf("Vlan(%s) = %+v, %v, want %s tag %d", test.device, vlan, ok, test.parent, test.tag)
+		}
+	}
+	if _, ok := configuration.Vlan("em4.30"); ok {
+		t.Error("Vlan(em4.30) found a VLAN")
+	}
+}
+
+func TestLaggsAndBridges(t *testing.T) {
+	configuration := loadFixture(t)
+	lagg, ok := configuration.Lagg("lagg0")
+	if !ok || lagg.Proto != "lacp" || !reflect.DeepEqual(lagg.Members(), []string{"em2", "em3"}) {
+		t.Errorf("Lagg(lagg0) = %+v, %v", lagg, ok)
+	}
+	if _, ok := configuration.Lagg("lagg1"); ok {
+		t.Error("Lagg(lagg1) found a lagg")
+	}
+	bridge, ok := configuration.Bridge("bridge0")
+	if !ok || !reflect.DeepEqual(bridge.Members(), []string{"opt1", "lan"}) {
+		t.Errorf("Bridge(bridge0) = %+v, %v", bridge, ok)
+	}
+	if _, ok := configuration.Bridge("bridge1"); ok {
+		t.Error("Bridge(bridge1) found a bridge")
+	}
+}
@@ -0,0 +1,206 @@
+/*
+ * Copyright (c) 2020 Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package stun
+
+import (
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultPort     = "3478"
+	DefaultInterval = 30 * time.Second
+)
+
+// Client keeps a STUN binding open towards the controller and calls OnWake
+// when the controller sends an unsolicited request or indication.
+type Client struct {
+	Server   string
+	Interval time.Duration
+	OnWake   func()
+	Log      *log.Entry
+
+	conn    net.PacketConn
+	server  *net.UDPAddr
+	stop    chan bool
+	lock    sync.Mutex
+	pending TransactionID
+	mapped  *net.UDPAddr
+}
+
+// ServerFromUrl converts a stun:// URL as sent in mgmt_cfg to a host:port.
+func ServerFromUrl(stunUrl string) (string, error) {
+	u, err := url.Parse(stunUrl)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "stun" {
+		return "", errors.New("not a stun URL")
+	}
+	host := u.Host
+	if len(host) == 0 {
+		host = u.Opaque
+	}
+	if len(host) == 0 {
+		return "", errors.New("no host in stun URL")
+	}
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, DefaultPort)
+	}
+	return host, nil
+}
+
+func NewClient(stunUrl string, onWake func(), logger *log.Entry) (*Client, error) {
+	server, err := ServerFromUrl(stunUrl)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		Server:   server,
+		Interval: DefaultInterval,
+		OnWake:   onWake,
+		Log:      logger.WithField("component", "stun"),
+	}, nil
+}
+
+func (c *Client) Start() error {
+	server, err := net.ResolveUDPAddr("udp4", c.Server)
+	if err != nil {
+		return err
+	}
+	conn, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		return err
+	}
+	c.server = server
+	c.conn = conn
+	c.stop = make(chan bool)
+	go c.read()
+	go c.keepAlive()
+	return nil
+}
+
+func (c *Client) Stop() {
+	if c.conn == nil {
+		return
+	}
+	close(c.stop)
+	_ = c.conn.Close()
+}
+
+// MappedAddress returns the last reflexive address seen by the server.
+func (c *Client) MappedAddress() *net.UDPAddr {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.mapped
+}
+
+func (c *Client) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+func (c *Client) keepAlive() {
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+	c.bind()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			c.bind()
+		}
+	}
+}
+
+func (c *Client) bind() {
+	id, err := NewTransactionID()
+	if err != nil {
+		c.Log.Errorf("Cannot generate STUN transaction: %v", err)
+		return
+	}
+	c.lock.Lock()
+	c.pending = id
+	c.lock.Unlock()
+	request := Message{Type: BindingRequest, TransactionID: id}
+	if _, err := c.conn.WriteTo(request.Marshal(), c.server); err != nil {
+		c.Log.Errorf("Cannot send STUN binding request to %s: %v", c.server, err)
+	}
+}
+
+func (c *Client) read() {
+	buffer := make([]byte, 1500)
+	for {
+		n, addr, err := c.conn.ReadFrom(buffer)
+		if err != nil {
+			select {
+			case <-c.stop:
+				return
+			default:
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+			c.Log.Errorf("STUN socket error: %v", err)
+			return
+		}
+		if !c.fromServer(addr) {
+			c.Log.Tracef("Ignoring STUN packet from %s", addr)
+			continue
+		}
+		message := Message{}
+		if err := message.Unmarshal(buffer[:n]); err != nil {
+			c.Log.Tracef("Ignoring invalid STUN packet from %s: %v", addr, err)
+			continue
+		}
+		switch {
+		case message.IsSuccess():
+			c.lock.Lock()
+			if message.TransactionID == c.pending {
+				mapped, err := message.MappedAddress()
+				if err == nil {
+					if c.mapped == nil || c.mapped.String() != mapped.String() {
+						c.Log.Debugf("STUN mapped address: %s", mapped)
+					}
+					c.mapped = mapped
+				}
+			}
+			c.lock.Unlock()
+		case message.IsError():
+			c.Log.Debugf("STUN error response from %s", addr)
+		case message.IsRequest():
+			if udpAddr, ok := addr.(*net.UDPAddr); ok {
+				response := Message{Type: BindingSuccess, TransactionID: message.TransactionID}
+				response.AddXorMappedAddress(udpAddr)
+				_, _ = c.conn.WriteTo(response.Marshal(), addr)
+			}
+			c.wake(addr)
+		case message.IsIndication():
+			c.wake(addr)
+		}
+	}
+}
+
+// fromServer checks that a packet comes from the configured server: only
+// the controller may wake the gateway up.
+func (c *Client) fromServer(addr net.Addr) bool {
+	udpAddr, ok := addr.(*net.UDPAddr)
+	return ok && udpAddr.Port == c.server.Port && udpAddr.IP.Equal(c.server.IP)
+}
+
+func (c *Client) wake(addr net.Addr) {
+	c.Log.Debugf("STUN wake-up from %s", addr)
+	if c.OnWake != nil {
+		c.OnWake()
+	}
+}
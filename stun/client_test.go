@@ -0,0 +1,117 @@
+/*
+ * Copyright (c) 2020 Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package stun
+
+import (
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func TestServerFromUrl(t *testing.T) {
+	tests := []struct {
+		url     string
+		want    string
+		wantErr bool
+	}{
+		{"stun://controller.example:3478", "controller.example:3478", false},
+		{"stun://192.0.2.10", "192.0.2.10:3478", false},
+		{"stun:192.0.2.10:3479", "192.0.2.10:3479", false},
+		{"http://controller.example", "", true},
+		{"stun://", "", true},
+	}
+	for _, test := range tests {
+		got, err := ServerFromUrl(test.url)
+		if (err != nil) != test.wantErr || got != test.want {
+			t.Errorf("ServerFromUrl(%q) = %q, %v, want %q", test.url, got, err, test.want)
+		}
+	}
+}
+
+func startTestClient(t *testing.T) (*testServer, *Client, chan struct{}) {
+	t.Helper()
+	server, err := newTestServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = server.Serve()
+	}()
+	t.Cleanup(func() {
+		_ = server.Close()
+	})
+	logger := log.New()
+	logger.SetOutput(ioutil.Discard)
+	woken := make(chan struct{}, 4)
+	client, err := NewClient("stun://"+server.Addr().String(), func() {
+		woken <- struct{}{}
+	}, log.NewEntry(logger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Stop)
+	return server, client, woken
+}
+
+func waitMapped(t *testing.T, client *Client) *net.UDPAddr {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if mapped := client.MappedAddress(); mapped != nil {
+			return mapped
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("no mapped address")
+	return nil
+}
+
+func TestClientBinding(t *testing.T) {
+	server, client, woken := startTestClient(t)
+	mapped := waitMapped(t, client)
+	if local := client.LocalAddr().(*net.UDPAddr); mapped.Port != local.Port {
+		t.Errorf("mapped port = %d, want %d", mapped.Port, local.Port)
+	}
+	clients := server.Clients()
+	if len(clients) != 1 {
+		t.Fatalf("server saw %d clients, want 1", len(clients))
+	}
+	if err := server.Wake(clients[0]); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-woken:
+	case <-time.After(2 * time.Second):
+		t.Fatal("client not woken up")
+	}
+}
+
+func TestClientIgnoresOtherSources(t *testing.T) {
+	server, client, woken := startTestClient(t)
+	waitMapped(t, client)
+	other, err := newTestServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if err := other.Wake(server.Clients()[0]); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-woken:
+		t.Fatal("client woken up by another host")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
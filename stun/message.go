@@ -0,0 +1,225 @@
+/*
+ * Copyright (c) 2020 Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package stun
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"net"
+)
+
+const (
+	MagicCookie uint32 = 0x2112A442
+
+	headerLength        = 20
+	attributeHeaderSize = 4
+)
+
+// Message types (RFC 5389 section 6)
+const (
+	BindingRequest    uint16 = 0x0001
+	BindingIndication uint16 = 0x0011
+	BindingSuccess    uint16 = 0x0101
+	BindingError      uint16 = 0x0111
+)
+
+// Attribute types (RFC 5389 section 18.2)
+const (
+	AttrMappedAddress    uint16 = 0x0001
+	AttrErrorCode        uint16 = 0x0009
+	AttrXorMappedAddress uint16 = 0x0020
+	AttrSoftware         uint16 = 0x8022
+)
+
+const (
+	familyIpv4 byte = 0x01
+	familyIpv6 byte = 0x02
+)
+
+type TransactionID [12]byte
+
+func NewTransactionID() (TransactionID, error) {
+	var id TransactionID
+	_, err := rand.Read(id[:])
+	return id, err
+}
+
+type Attribute struct {
+	Type  uint16
+	Value []byte
+}
+
+type Message struct {
+	Type          uint16
+	TransactionID TransactionID
+	Attributes    []Attribute
+}
+
+func (m Message) IsRequest() bool {
+	return m.Type&0x0110 == 0x0000
+}
+
+func (m Message) IsIndication() bool {
+	return m.Type&0x0110 == 0x0010
+}
+
+func (m Message) IsSuccess() bool {
+	return m.Type&0x0110 == 0x0100
+}
+
+func (m Message) IsError() bool {
+	return m.Type&0x0110 == 0x0110
+}
+
+func (m *Message) Add(attrType uint16, value []byte) {
+	m.Attributes = append(m.Attributes, Attribute{Type: attrType, Value: value})
+}
+
+func (m Message) Get(attrType uint16) ([]byte, bool) {
+	for _, attr := range m.Attributes {
+		if attr.Type == attrType {
+			return attr.Value, true
+		}
+	}
+	return nil, false
+}
+
+func padding(length int) int {
+	return (4 - length%4) % 4
+}
+
+func (m Message) Marshal() []byte {
+	length := 0
+	for _, attr := range m.Attributes {
+		length += attributeHeaderSize + len(attr.Value) + padding(len(attr.Value))
+	}
+	b := make([]byte, headerLength, headerLength+length)
+	binary.BigEndian.PutUint16(b[0:], m.Type)
+	binary.BigEndian.PutUint16(b[2:], uint16(length))
+	binary.BigEndian.PutUint32(b[4:], MagicCookie)
+	copy(b[8:], m.TransactionID[:])
+	for _, attr := range m.Attributes {
+		header := make([]byte, attributeHeaderSize)
+		binary.BigEndian.PutUint16(header[0:], attr.Type)
+		binary.BigEndian.PutUint16(header[2:], uint16(len(attr.Value)))
+		b = append(b, header...)
+		b = append(b, attr.Value...)
+		b = append(b, make([]byte, padding(len(attr.Value)))...)
+	}
+	return b
+}
+
+// IsMessage checks the fixed parts of a STUN header.
+func IsMessage(data []byte) bool {
+	return len(data) >= headerLength && data[0]&0xc0 == 0 && binary.BigEndian.Uint32(data[4:]) == MagicCookie
+}
+
+func (m *Message) Unmarshal(data []byte) error {
+	if !IsMessage(data) {
+		return errors.New("not a STUN message")
+	}
+	length := int(binary.BigEndian.Uint16(data[2:]))
+	if length%4 != 0 || len(data) < headerLength+length {
+		return errors.New("invalid STUN message length")
+	}
+	m.Type = binary.BigEndian.Uint16(data[0:])
+	copy(m.TransactionID[:], data[8:headerLength])
+	m.Attributes = nil
+	data = data[headerLength : headerLength+length]
+	for len(data) > 0 {
+		if len(data) < attributeHeaderSize {
+			return errors.New("truncated STUN attribute")
+		}
+		attrLength := int(binary.BigEndian.Uint16(data[2:]))
+		if len(data) < attributeHeaderSize+attrLength {
+			return errors.New("truncated STUN attribute")
+		}
+		m.Attributes = append(m.Attributes, Attribute{
+			Type:  binary.BigEndian.Uint16(data[0:]),
+			Value: data[attributeHeaderSize : attributeHeaderSize+attrLength],
+		})
+		next := attributeHeaderSize + attrLength + padding(attrLength)
+		if next > len(data) {
+			next = len(data)
+		}
+		data = data[next:]
+	}
+	return nil
+}
+
+// xorKey returns the bytes used to obfuscate addresses in XOR-MAPPED-ADDRESS.
+func (m Message) xorKey() []byte {
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint32(key, MagicCookie)
+	copy(key[4:], m.TransactionID[:])
+	return key
+}
+
+func (m *Message) AddXorMappedAddress(addr *net.UDPAddr) {
+	ip := addr.IP.To4()
+	family := familyIpv4
+	if ip == nil {
+		ip = addr.IP.To16()
+		family = familyIpv6
+	}
+	value := make([]byte, 4+len(ip))
+	value[1] = family
+	binary.BigEndian.PutUint16(value[2:], uint16(addr.Port)^uint16(MagicCookie>>16))
+	key := m.xorKey()
+	for i := range ip {
+		value[4+i] = ip[i] ^ key[i]
+	}
+	m.Add(AttrXorMappedAddress, value)
+}
+
+// MappedAddress returns the reflexive address from XOR-MAPPED-ADDRESS or,
+// for old servers, from MAPPED-ADDRESS.
+func (m Message) MappedAddress() (*net.UDPAddr, error) {
+	if value, ok := m.Get(AttrXorMappedAddress); ok {
+		addr, err := parseAddress(value)
+		if err != nil {
+			return nil, err
+		}
+		addr.Port ^= int(MagicCookie >> 16)
+		key := m.xorKey()
+		for i := range addr.IP {
+			addr.IP[i] ^= key[i]
+		}
+		return addr, nil
+	}
+	if value, ok := m.Get(AttrMappedAddress); ok {
+		return parseAddress(value)
+	}
+	return nil, errors.New("no mapped address")
+}
+
+func parseAddress(value []byte) (*net.UDPAddr, error) {
+	if len(value) < 4 {
+		return nil, errors.New("invalid address attribute")
+	}
+	size := 0
+	switch value[1] {
+	case familyIpv4:
+		size = net.IPv4len
+	case familyIpv6:
+		size = net.IPv6len
+	default:
+		return nil, errors.New("unknown address family")
+	}
+	if len(value) < 4+size {
+		return nil, errors.New("invalid address attribute")
+	}
+	ip := make(net.IP, size)
+	copy(ip, value[4:4+size])
+	return &net.UDPAddr{
+		IP:   ip,
+		Port: int(binary.BigEndian.Uint16(value[2:])),
+	}, nil
+}
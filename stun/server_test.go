@@ -0,0 +1,88 @@
+/*
+ * Copyright (c) 2020 Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package stun
+
+import (
+	"net"
+	"sync"
+)
+
+// testServer is a minimal STUN server standing in for the controller: it
+// answers binding requests and can wake up the clients it has seen.
+type testServer struct {
+	conn    net.PacketConn
+	lock    sync.Mutex
+	clients map[string]net.Addr
+}
+
+func newTestServer(address string) (*testServer, error) {
+	conn, err := net.ListenPacket("udp4", address)
+	if err != nil {
+		return nil, err
+	}
+	return &testServer{
+		conn:    conn,
+		clients: make(map[string]net.Addr),
+	}, nil
+}
+
+func (s *testServer) Addr() net.Addr {
+	return s.conn.LocalAddr()
+}
+
+func (s *testServer) Serve() error {
+	buffer := make([]byte, 1500)
+	for {
+		n, addr, err := s.conn.ReadFrom(buffer)
+		if err != nil {
+			return err
+		}
+		message := Message{}
+		if err := message.Unmarshal(buffer[:n]); err != nil || message.Type != BindingRequest {
+			continue
+		}
+		udpAddr, ok := addr.(*net.UDPAddr)
+		if !ok {
+			continue
+		}
+		s.lock.Lock()
+		s.clients[addr.String()] = addr
+		s.lock.Unlock()
+		response := Message{Type: BindingSuccess, TransactionID: message.TransactionID}
+		response.AddXorMappedAddress(udpAddr)
+		_, _ = s.conn.WriteTo(response.Marshal(), addr)
+	}
+}
+
+// Clients returns the addresses that sent a binding request.
+func (s *testServer) Clients() []net.Addr {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	clients := make([]net.Addr, 0, len(s.clients))
+	for _, addr := range s.clients {
+		clients = append(clients, addr)
+	}
+	return clients
+}
+
+// Wake sends a binding indication to addr, like the controller does when it
+// has a command pending for the device.
+func (s *testServer) Wake(addr net.Addr) error {
+	id, err := NewTransactionID()
+	if err != nil {
+		return err
+	}
+	message := Message{Type: BindingIndication, TransactionID: id}
+	_, err = s.conn.WriteTo(message.Marshal(), addr)
+	return err
+}
+
+func (s *testServer) Close() error {
+	return s.conn.Close()
+}
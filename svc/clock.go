@@ -0,0 +1,97 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package main
+
+import (
+	log "github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxClockSkew = 30
+	clockSkewSmoothing  = 0.3
+)
+
+// ClockSkew tracks the difference between the controller clock and the
+// local clock, as seen in the server_time_in_utc of inform responses.
+type ClockSkew struct {
+	lock    sync.Mutex
+	skew    time.Duration
+	samples uint64
+	updated time.Time
+}
+
+type ClockSkewStatus struct {
+	Skew    float64 `json:"skew_seconds"`
+	Samples uint64  `json:"samples"`
+	Updated int64   `json:"updated"`
+}
+
+// Update records a server time, in milliseconds since the epoch, received
+// in a response to a request sent at sent and answered at received. The
+// server time is compared to the middle of the exchange.
+func (c *ClockSkew) Update(serverTime int64, sent time.Time, received time.Time) time.Duration {
+	local := sent.Add(received.Sub(sent) / 2)
+	sample := time.Unix(0, serverTime*int64(time.Millisecond)).Sub(local)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.samples == 0 {
+		c.skew = sample
+	} else {
+		c.skew += time.Duration(clockSkewSmoothing * float64(sample-c.skew))
+	}
+	c.samples++
+	c.updated = received
+	return c.skew
+}
+
+// Skew returns the controller time minus the local time.
+func (c *ClockSkew) Skew() time.Duration {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.skew
+}
+
+// Now returns the local time corrected with the controller skew.
+func (c *ClockSkew) Now() time.Time {
+	return time.Now().Add(c.Skew())
+}
+
+func (c *ClockSkew) Status() ClockSkewStatus {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	status := ClockSkewStatus{
+		Skew:    c.skew.Seconds(),
+		Samples: c.samples,
+	}
+	if !c.updated.IsZero() {
+		status.Updated = c.updated.Unix()
+	}
+	return status
+}
+
+func updateClockSkew(svc *Service, serverTime int64, sent time.Time, received time.Time, logger *log.Entry) {
+	skew := svc.Clock.Update(serverTime, sent, received)
+	maxSkew := time.Duration(svc.Config.Clock.MaxSkew) * time.Second
+	if maxSkew <= 0 {
+		maxSkew = defaultMaxClockSkew * time.Second
+	}
+	if skew > maxSkew || skew < -maxSkew {
+		logger.Warnf("Local clock is %s away from the controller clock", skew.Round(time.Second))
+	} else {
+		logger.Tracef("Controller clock skew: %s", skew)
+	}
+	svc.Status.Set("clock", svc.Clock.Status())
+	svc.Status.SetMetric(Metric{
+		Name:  "ripugw_clock_skew_seconds",
+		Help:  "Controller clock minus local clock.",
+		Value: skew.Seconds(),
+	})
+}
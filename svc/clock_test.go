@@ -0,0 +1,51 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClockSkewUpdate(t *testing.T) {
+	// 2020-07-09T10:00:00Z
+	sent := time.Unix(1594288800, 0)
+	tests := []struct {
+		name       string
+		serverTime int64
+		roundTrip  time.Duration
+		want       time.Duration
+	}{
+		{"in sync", 1594288800500, time.Second, 0},
+		{"controller ahead", 1594288845000, 0, 45 * time.Second},
+		{"controller behind", 1594288790250, 500 * time.Millisecond, -10 * time.Second},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clock := &ClockSkew{}
+			got := clock.Update(test.serverTime, sent, sent.Add(test.roundTrip))
+			if got != test.want {
+				t.Errorf("Update(%d) = %s, want %s", test.serverTime, got, test.want)
+			}
+		})
+	}
+}
+
+func TestClockSkewSmoothing(t *testing.T) {
+	sent := time.Unix(1594288800, 0)
+	clock := &ClockSkew{}
+	clock.Update(1594288810000, sent, sent)
+	got := clock.Update(1594288800000, sent, sent)
+	if want := 7 * time.Second; got != want {
+		t.Errorf("smoothed skew = %s, want %s", got, want)
+	}
+	if status := clock.Status(); status.Samples != 2 {
+		t.Errorf("samples = %d, want 2", status.Samples)
+	}
+}
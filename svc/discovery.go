@@ -0,0 +1,65 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/COSAE-FR/ripugw/discover"
+	"github.com/COSAE-FR/ripugw/inform"
+)
+
+// announceCache holds the discovery identity taken from the last inform, so
+// discovery requests neither collect the system state again nor advance
+// the rate and link trackers.
+type announceCache struct {
+	lock     sync.Mutex
+	announce discover.Announce
+	updated  time.Time
+}
+
+func (c *announceCache) Set(request inform.Inform) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.announce = discover.AnnounceFromInform(request)
+	c.updated = time.Now()
+}
+
+// Get returns the cached announce, with its uptime brought up to date, if it
+// is younger than maxAge.
+func (c *announceCache) Get(maxAge time.Duration) (discover.Announce, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	age := time.Since(c.updated)
+	if c.updated.IsZero() || age > maxAge {
+		return discover.Announce{}, false
+	}
+	announce := c.announce
+	announce.Uptime += uint32(age / time.Second)
+	return announce, true
+}
+
+// discoveryIdentity answers with the identity of the last inform. Before the
+// first inform, or when informs stall, the identity is collected without
+// the trackers and cached as if it came from an inform.
+func discoveryIdentity(svc *Service) func() (discover.Announce, error) {
+	return func() (discover.Announce, error) {
+		maxAge := 2 * time.Duration(svc.General.InformInterval) * time.Second
+		if announce, ok := svc.announce.Get(maxAge); ok {
+			return announce, nil
+		}
+		informPacket, err := collectInform(svc, informOptions(svc))
+		if err != nil {
+			return discover.Announce{}, err
+		}
+		svc.announce.Set(informPacket)
+		return discover.AnnounceFromInform(informPacket), nil
+	}
+}
@@ -0,0 +1,49 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/COSAE-FR/ripugw/conf"
+	"github.com/COSAE-FR/ripugw/inform"
+)
+
+func TestAnnounceCache(t *testing.T) {
+	cache := &announceCache{}
+	if _, ok := cache.Get(time.Minute); ok {
+		t.Fatal("empty cache returned an announce")
+	}
+	cache.Set(inform.Inform{Hostname: "gateway", Uptime: 100})
+	cache.updated = cache.updated.Add(-5 * time.Second)
+	announce, ok := cache.Get(time.Minute)
+	if !ok {
+		t.Fatal("fresh announce not returned")
+	}
+	if announce.Hostname != "gateway" || announce.Uptime != 105 {
+		t.Errorf("announce = %+v, want gateway up for 105s", announce)
+	}
+	if _, ok := cache.Get(time.Second); ok {
+		t.Error("stale announce returned")
+	}
+}
+
+func TestDiscoveryIdentityUsesLastInform(t *testing.T) {
+	svc := &Service{Config: &conf.Config{}, announce: &announceCache{}}
+	svc.General.InformInterval = 15
+	svc.announce.Set(inform.Inform{Hostname: "gateway", Model: "UGW3"})
+	announce, err := discoveryIdentity(svc)()
+	if err != nil {
+		t.Fatalf("discoveryIdentity() = %v", err)
+	}
+	if announce.Hostname != "gateway" || announce.Model != "UGW3" {
+		t.Errorf("announce = %+v, want the cached one", announce)
+	}
+}
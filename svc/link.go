@@ -0,0 +1,70 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package main
+
+import (
+	"fmt"
+	"github.com/COSAE-FR/ripugw/collect"
+)
+
+func (s *Service) startLinks() {
+	logger := s.Log.WithField("component", "link_tracker")
+	s.Links = collect.NewLinkTracker(0, 0)
+	s.Links.OnChange = func(transition collect.LinkTransition) {
+		state := "down"
+		if transition.Up {
+			state = "up"
+		}
+		logger.Infof("Link %s is %s", transition.Interface, state)
+		s.Events.Publish(Event{
+			Reason:  EventLinkChange,
+			Payload: fmt.Sprintf("%s %s", transition.Interface, state),
+		})
+	}
+	s.Links.Start()
+}
+
+// linkStatus exposes the link states. The flap counters of the interfaces
+// no longer tracked are removed.
+func linkStatus(svc *Service) {
+	states := svc.Links.States()
+	svc.Status.Set("links", states)
+	flaps := make([]Metric, 0, len(states))
+	for name, state := range states {
+		flaps = append(flaps, Metric{
+			Help:   "Number of times the link went down.",
+			Type:   "counter",
+			Labels: map[string]string{"interface": name},
+			Value:  float64(state.Flaps),
+		})
+	}
+	svc.Status.SetMetrics("ripugw_link_flaps_total", flaps)
+}
+
+// aggregateStatus exposes the laggs and bridges with their members. The
+// metrics of the members no longer in an aggregate are removed.
+func aggregateStatus(svc *Service) {
+	aggregates := svc.Aggregates.All()
+	svc.Status.Set("aggregates", aggregates)
+	var members []Metric
+	for _, aggregate := range aggregates {
+		for _, member := range aggregate.Members {
+			up := 0.0
+			if member.Up {
+				up = 1
+			}
+			members = append(members, Metric{
+				Help:   "Whether a lagg or bridge member link is up.",
+				Labels: map[string]string{"interface": aggregate.Name, "member": member.Name},
+				Value:  up,
+			})
+		}
+	}
+	svc.Status.SetMetrics("ripugw_aggregate_member_up", members)
+}
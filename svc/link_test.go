@@ -0,0 +1,43 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/COSAE-FR/ripugw/collect"
+)
+
+func TestLinkStatus(t *testing.T) {
+	svc := &Service{Status: NewStatusRegistry(), Links: collect.NewLinkTracker(0, 0)}
+	now := time.Now()
+	svc.Links.Observe("eth0", true, now)
+	svc.Links.Observe("ppp0", true, now)
+	svc.Links.Observe("ppp0", false, now.Add(time.Second))
+	linkStatus(svc)
+	metrics := scrapeMetrics(t, svc.Status)
+	for _, line := range []string{
+		"# TYPE ripugw_link_flaps_total counter\n",
+		`ripugw_link_flaps_total{interface="eth0"} 0` + "\n",
+		`ripugw_link_flaps_total{interface="ppp0"} 1` + "\n",
+	} {
+		if !strings.Contains(metrics, line) {
+			t.Errorf("missing %q in\n%s", line, metrics)
+		}
+	}
+
+	svc.Links = collect.NewLinkTracker(0, 0)
+	svc.Links.Observe("eth0", true, now)
+	linkStatus(svc)
+	if metrics := scrapeMetrics(t, svc.Status); strings.Contains(metrics, "ppp0") {
+		t.Errorf("flaps of a vanished interface still exposed:\n%s", metrics)
+	}
+}
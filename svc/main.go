@@ -15,6 +15,7 @@ import (
 	"fmt"
 	"github.com/COSAE-FR/ripugw/collect"
 	"github.com/COSAE-FR/ripugw/conf"
+	"github.com/COSAE-FR/ripugw/discover"
 	"github.com/COSAE-FR/ripugw/inform"
 	"github.com/JulesMike/speedtest"
 	log "github.com/sirupsen/logrus"
@@ -38,11 +39,12 @@ var httpClient = &http.Client{
 }
 
 func SendInform(message *inform.Inform, config *conf.Config) (inform.Message, error) {
-	key := config.Management.GetKey()
+	key := config.InformKey()
 	logger := config.Log.WithFields(log.Fields{
 		"component":   "send_inform",
 		"use_aes_gcm": fmt.Sprintf("%v", config.Management.UseAesGcm),
 		"default_key": fmt.Sprintf("%v", key.IsDefault()),
+		"rotating":    fmt.Sprintf("%v", config.Management.HasPreviousKey()),
 		"mac":         message.Mac.String(),
 	})
 	mode := config.Management.GetCryptoMode()
@@ -81,6 +83,9 @@ func SendInform(message *inform.Inform, config *conf.Config) (inform.Message, er
 
 	if resp.StatusCode != 200 || resp.Header.Get("Content-Type") != "application/x-binary" {
 		logger.Errorf("Received status code: %d with CT: %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+		if resp.StatusCode != 200 {
+			config.KeyFailed(key)
+		}
 		return inform.ResponseFromHttpCode(resp.StatusCode), nil
 	}
 
@@ -92,13 +97,20 @@ func SendInform(message *inform.Inform, config *conf.Config) (inform.Message, er
 
 	rPacket := &inform.Packet{}
 
-	err = rPacket.Unmarshal(data, func(ap inform.HardwareAddr) (inform.Key, error) {
-		return key, nil
+	err = rPacket.UnmarshalWithKeys(data, func(ap inform.HardwareAddr) ([]inform.Key, error) {
+		return config.InformKeys(key), nil
 	})
 	if err != nil {
 		logger.Errorf("Error unmarshalling response: %+v", err)
+		config.KeyFailed(key)
 		return nil, err
 	}
+	if rPacket.IsEncrypted() && config.KeySucceeded(rPacket.Key()) {
+		logger.Info("New authentication key confirmed by the controller")
+		if err := config.Write(); err != nil {
+			logger.Errorf("cannot write configuration: %v", err)
+		}
+	}
 
 	informResp, ok := rPacket.Msg.(inform.InformResponse)
 	if !ok {
@@ -155,6 +167,7 @@ func SpeedTest(svc *Service) {
 	} else {
 		logger.Debugf("Last speed test written ton configuration file")
 	}
+	svc.Events.Publish(Event{Reason: EventSpeedTest})
 
 }
 
@@ -169,12 +182,33 @@ type Service struct {
 	*conf.Config
 	InformTicker *time.Ticker
 	InformStop   chan bool
+	InformNow    chan bool
+	Discovery    *discover.Responder
+	Events       *EventBus
+	Clock        *ClockSkew
+	Status       *StatusRegistry
+	Uplinks      *collect.UplinkRegistry
+	Rates        *collect.RateTracker
+	Links        *collect.LinkTracker
+	Watcher      *collect.NetWatcher
+	Aggregates   *collect.AggregateTable
+	wan          wanState
+	announce     *announceCache
+	stun         *stunState
 }
 
 func New(cfg ServiceConfig) (*Service, error) {
 	var err error
 	configuration, err := conf.New(cfg.File, cfg.Json)
-	svc := Service{Config: configuration}
+	svc := Service{
+		Config:     configuration,
+		Clock:      &ClockSkew{},
+		Status:     NewStatusRegistry(),
+		Rates:      collect.NewRateTracker(),
+		Aggregates: collect.NewAggregateTable(),
+		announce:   &announceCache{},
+		stun:       &stunState{},
+	}
 	svc.Log.WithFields(log.Fields{
 		"component": "daemon_creator",
 		"version":   Version,
@@ -182,6 +216,41 @@ func New(cfg ServiceConfig) (*Service, error) {
 	return &svc, err
 }
 
+// informOptions returns the collector options without the trackers.
+func informOptions(svc *Service) collect.Options {
+	options := collect.Options{
+		DisableClients: svc.Config.Clients.Disable,
+		LeaseFiles:     svc.Config.Clients.LeaseFiles,
+	}
+	if !svc.Config.Uplink.DisableDpinger {
+		options.DpingerDir = collect.DefaultDpingerDir
+		if len(svc.Config.Uplink.DpingerDir) > 0 {
+			options.DpingerDir = svc.Config.Uplink.DpingerDir
+		}
+	}
+	return options
+}
+
+func prepareInform(svc *Service) (inform.Inform, error) {
+	options := informOptions(svc)
+	options.Uplinks = svc.Uplinks
+	options.Rates = svc.Rates
+	options.Links = svc.Links
+	options.Aggregates = svc.Aggregates
+	return collectInform(svc, options)
+}
+
+func collectInform(svc *Service, options collect.Options) (inform.Inform, error) {
+	configVersion := "0123456789abcdef"
+	if len(svc.Management.Version) > 0 {
+		configVersion = svc.Management.Version
+	}
+	if pfsense := svc.Config.PfSenseConfiguration(); pfsense != nil {
+		return collect.RequestFromPfsense(svc.General.Url, configVersion, *pfsense, *svc.PfSenseInterfaces, svc.SpeedTest, options)
+	}
+	return collect.Request(svc.General.Url, configVersion, options)
+}
+
 func informTick(svc *Service) {
 	logger := svc.Log.WithField("component", "inform")
 	logger.Info("Launching Inform handler")
@@ -192,77 +261,115 @@ func informTick(svc *Service) {
 			return
 		case <-svc.InformTicker.C:
 			logger.Debug("Inform tick")
-			configVersion := "0123456789abcdef"
-			if len(svc.Management.Version) > 0 {
-				configVersion = svc.Management.Version
-			}
-
-			var informPacket inform.Inform
-			var err error
-			if svc.PfSenseMode {
-				informPacket, err = collect.RequestFromPfsense(svc.General.Url, configVersion, *svc.PfSense, *svc.PfSenseInterfaces, svc.SpeedTest)
-				if err != nil {
-					logger.Errorf("Cannot prepare pfSense inform packet: %s", err)
-					continue
-				}
-			} else {
-				informPacket, err = collect.Request(svc.General.Url, configVersion)
-				if err != nil {
-					logger.Errorf("Cannot prepare inform packet: %s", err)
-					continue
-				}
-			}
-			if svc.General.LogLevel == "trace" {
-				packet, err := json.MarshalIndent(informPacket, "", "\t")
-				if err != nil {
-					logger.Tracef("Cannot marshal Inform packet: %+v", err)
-				} else {
-					logger.Tracef("Packet to send: \n %s", packet)
-				}
-			}
-			resp, err := SendInform(&informPacket, svc.Config)
-			if err != nil {
-				logger.Errorf("Cannot send inform packet: %s", err)
-				continue
-			}
-			r, _ := json.Marshal(resp)
-			logger.Tracef("Received: %s", r)
-			switch response := resp.(type) {
-			case *inform.SetParam:
-				keyChange, versionChange, cryptoModeChange := false, false, false
-
-				key, keyChange := response.ManagementConfig["authkey"]
-				if keyChange {
-					logger.Debug("Key changed!")
-					svc.Config.Management.Key = key
-				}
-				version, versionChange := response.ManagementConfig["cfgversion"]
-				if versionChange {
-					logger.Debug("Version changed!")
-					svc.Config.Management.Version = version
-				}
-				cryptoMode, cryptoModeChange := response.ManagementConfig["use_aes_gcm"]
-				if cryptoModeChange {
-					logger.Debug("AES mode changed!")
-					svc.Config.Management.UseAesGcm = cryptoMode == "true"
-				}
-				if keyChange || versionChange || cryptoModeChange {
-					logger.Debugf("Decoded response authkey: %s, default: %v", svc.Config.Management.Key, svc.Config.Management.GetKey().IsDefault())
-					_ = svc.Config.Write()
-				}
-			case *inform.Noop:
-				logger.Debugf("Received Noop message")
-			case *inform.Cmd:
-				logger.Debugf("Received Cmd message")
-				switch response.Command {
-				case "speed-test":
-					logger.Debugf("Command type: %s", response.Command)
-					go SpeedTest(svc)
-				default:
-					logger.Debugf("Unknown command: %s", response.Command)
+			doInform(svc, logger)
+		case <-svc.InformNow:
+			logger.Debug("Immediate inform")
+			doInform(svc, logger)
+		}
+	}
+}
+
+// TriggerInform asks the inform handler to send an inform as soon as
+// possible. Requests arriving while one is already pending are merged.
+func (s *Service) TriggerInform() {
+	select {
+	case s.InformNow <- true:
+	default:
+	}
+}
+
+func doInform(svc *Service, logger *log.Entry) {
+	informPacket, err := prepareInform(svc)
+	if err != nil {
+		logger.Errorf("Cannot prepare inform packet: %s", err)
+		return
+	}
+	svc.announce.Set(informPacket)
+	for _, event := range svc.wan.events(informPacket) {
+		svc.Events.Publish(event)
+	}
+	svc.Status.Set("clients", clientStatus(informPacket))
+	uplinkStatus(svc)
+	interfaceStatus(svc, informPacket)
+	wanStatus(svc, informPacket)
+	linkStatus(svc)
+	aggregateStatus(svc)
+	events := svc.Events.Take()
+	ApplyEvents(&informPacket, events)
+	if svc.Config.Clock.CorrectTime {
+		informPacket.Time = svc.Clock.Now().Unix()
+	}
+	if svc.General.LogLevel == "trace" {
+		packet, err := json.MarshalIndent(informPacket, "", "\t")
+		if err != nil {
+			logger.Tracef("Cannot marshal Inform packet: %+v", err)
+		} else {
+			logger.Tracef("Packet to send: \n %s", packet)
+		}
+	}
+	sent := time.Now()
+	resp, err := SendInform(&informPacket, svc.Config)
+	if err != nil {
+		logger.Errorf("Cannot send inform packet: %s", err)
+		svc.Events.Requeue(events)
+		return
+	}
+	if response, ok := resp.(inform.InformResponse); ok && !response.IsSuccess() {
+		logger.Errorf("Inform rejected by the controller: HTTP %d", response.HttpCode())
+		svc.Events.Requeue(events)
+		return
+	}
+	svc.Events.Delivered()
+	if serverTime, ok := inform.ServerTime(resp); ok {
+		updateClockSkew(svc, serverTime, sent, time.Now(), logger)
+	}
+	r, _ := json.Marshal(resp)
+	logger.Tracef("Received: %s", r)
+	switch response := resp.(type) {
+	case *inform.SetParam:
+		keyChange, versionChange, cryptoModeChange := false, false, false
+
+		key, keyChange := response.ManagementConfig["authkey"]
+		if keyChange {
+			logger.Debug("Key changed!")
+			svc.Config.RotateKey(key)
+		}
+		version, versionChange := response.ManagementConfig["cfgversion"]
+		if versionChange {
+			logger.Debug("Version changed!")
+			svc.Config.Management.Version = version
+		}
+		cryptoMode, cryptoModeChange := response.ManagementConfig["use_aes_gcm"]
+		if cryptoModeChange {
+			logger.Debug("AES mode changed!")
+			svc.Config.Management.UseAesGcm = cryptoMode == "true"
+		}
+		stunUrl, stunChange := response.ManagementConfig["stun_url"]
+		if stunChange && stunUrl != svc.Config.Management.StunUrl {
+			logger.Debug("STUN URL changed!")
+			svc.Config.Management.StunUrl = stunUrl
+			svc.restartStun()
+		} else {
+			stunChange = false
+		}
+		if keyChange || versionChange || cryptoModeChange || stunChange {
+			logger.Debugf("Decoded response authkey: %s, default: %v", svc.Config.Management.Key, svc.Config.Management.GetKey().IsDefault())
+			_ = svc.Config.Write()
+		}
+	case *inform.Noop:
+		logger.Debugf("Received Noop message")
+	case *inform.Cmd:
+		logger.Debugf("Received Cmd message")
+		switch response.Command {
+		case "speed-test":
+			logger.Debugf("Command type: %s", response.Command)
+			go func(cmdId string) {
+				SpeedTest(svc)
+				svc.Events.Publish(Event{Reason: EventCmdDone, Payload: cmdId})
+			}(response.CmdId)
+		default:
+			logger.Debugf("Unknown command: %s", response.Command)
 
-				}
-			}
 		}
 	}
 }
@@ -276,12 +383,53 @@ func (s *Service) Start() error {
 	logger.Debug("Creating Inform handler")
 	s.InformTicker = time.NewTicker(informDuration)
 	s.InformStop = make(chan bool)
+	s.InformNow = make(chan bool, 1)
+	if len(s.Config.Status.Address) > 0 {
+		logger.Debugf("Starting status listener on %s", s.Config.Status.Address)
+		if err := s.Status.Listen(s.Config.Status.Address); err != nil {
+			logger.Errorf("Cannot start status listener: %v", err)
+		}
+	}
+	s.Status.Set("version", Version)
+
+	if len(s.Config.Clients.OuiFile) > 0 {
+		db, err := inform.LoadOuiFile(s.Config.Clients.OuiFile)
+		if err != nil {
+			logger.Errorf("Cannot load OUI database %s: %v", s.Config.Clients.OuiFile, err)
+		} else {
+			logger.Debugf("Loaded %d OUI entries from %s", db.Len(), s.Config.Clients.OuiFile)
+			_ = inform.SetOuiDatabase(db)
+		}
+	}
+
+	s.Events = NewEventBus(time.Duration(s.Config.Notify.MinInterval)*time.Second, informDuration, s.TriggerInform, s.Config.Notify.Disable)
+	go reloadHandler(s)
 
 	if s.SpeedTest == nil || s.SpeedTest.RunTime == 0 {
 		logger.Debug("Starting speed_test")
 		go SpeedTest(s)
 	}
 
+	if s.Config.Discovery.Enable {
+		logger.Debug("Starting discovery responder")
+		responder, err := discover.NewResponder(s.Config.Discovery.Address, discoveryIdentity(s), s.Log)
+		if err != nil {
+			logger.Errorf("Cannot start discovery responder: %v", err)
+		} else {
+			s.Discovery = responder
+			go func() {
+				if err := responder.Serve(); err != nil {
+					logger.Debugf("Discovery responder stopped: %v", err)
+				}
+			}()
+		}
+	}
+
+	s.restartStun()
+	s.startUplinks()
+	s.startLinks()
+	s.startWatcher()
+
 	logger.Debug("Starting Inform handler")
 	go informTick(s)
 	return nil
@@ -293,6 +441,21 @@ func (s Service) Stop() error {
 	logger.Debug("Stopping inform")
 	s.InformTicker.Stop()
 	s.InformStop <- true
+	if s.stun.Stop() {
+		logger.Debug("Stopped STUN client")
+	}
+	if s.Discovery != nil {
+		logger.Debug("Stopping discovery responder")
+		_ = s.Discovery.Close()
+	}
+	if s.Watcher != nil {
+		logger.Debug("Stopping network watcher")
+		_ = s.Watcher.Close()
+	}
+	s.Events.Stop()
+	s.Uplinks.Stop()
+	s.Links.Stop()
+	_ = s.Status.Close()
 	return nil
 }
 
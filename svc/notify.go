@@ -0,0 +1,286 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"github.com/COSAE-FR/ripugw/inform"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const defaultNotifyInterval = 5 * time.Second
+
+// Notify reasons
+const (
+	EventSpeedTest    = "speedtest"
+	EventWanDown      = "wan_down"
+	EventWanUp        = "wan_up"
+	EventWanIpChange  = "wan_ip_change"
+	EventConfigReload = "config_reload"
+	EventCmdDone      = "cmd_done"
+	EventLinkChange   = "link_change"
+)
+
+type Event struct {
+	Reason  string
+	Payload string
+}
+
+// EventBus collects local events and asks for an out-of-band notify inform.
+// Events published while a notify is pending, or before MinInterval has
+// elapsed since the last one, are merged into a single inform; a regular
+// inform sent in the meantime carries them as well. Events with the same
+// reason are merged into one, their payloads listed in order. After a
+// failed delivery the interval doubles, up to MaxInterval.
+type EventBus struct {
+	MinInterval time.Duration
+	MaxInterval time.Duration
+	Trigger     func()
+	lock        sync.Mutex
+	pending     []Event
+	lastSent    time.Time
+	timer       *time.Timer
+	failures    int
+	disabled    bool
+	stopped     bool
+}
+
+func NewEventBus(minInterval time.Duration, maxInterval time.Duration, trigger func(), disabled bool) *EventBus {
+	if minInterval <= 0 {
+		minInterval = defaultNotifyInterval
+	}
+	if maxInterval < minInterval {
+		maxInterval = minInterval
+	}
+	return &EventBus{
+		MinInterval: minInterval,
+		MaxInterval: maxInterval,
+		Trigger:     trigger,
+		disabled:    disabled,
+	}
+}
+
+func (b *EventBus) Publish(event Event) {
+	if b == nil || b.disabled {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.stopped {
+		return
+	}
+	b.pending = mergeEvent(b.pending, event)
+	b.schedule()
+}
+
+// mergeEvent adds event to events, merging its payload into the event with
+// the same reason if there is one.
+func mergeEvent(events []Event, event Event) []Event {
+	for i, pending := range events {
+		if pending.Reason == event.Reason {
+			events[i].Payload = mergePayloads(pending.Payload, event.Payload)
+			return events
+		}
+	}
+	return append(events, event)
+}
+
+// mergePayloads appends the payload b to the comma separated list a,
+// unless it is already listed.
+func mergePayloads(a string, b string) string {
+	if len(a) == 0 {
+		return b
+	}
+	if len(b) == 0 {
+		return a
+	}
+	for _, payload := range strings.Split(a, ",") {
+		if payload == b {
+			return a
+		}
+	}
+	return a + "," + b
+}
+
+// interval returns the delay between notifies, backed off after failures.
+// It must be called with the lock held.
+func (b *EventBus) interval() time.Duration {
+	interval := b.MinInterval
+	for i := 0; i < b.failures && interval < b.MaxInterval; i++ {
+		interval *= 2
+	}
+	if interval > b.MaxInterval {
+		interval = b.MaxInterval
+	}
+	return interval
+}
+
+// schedule must be called with the lock held.
+func (b *EventBus) schedule() {
+	if b.timer != nil {
+		return
+	}
+	wait := b.interval() - time.Since(b.lastSent)
+	if wait <= 0 {
+		b.Trigger()
+		return
+	}
+	b.timer = time.AfterFunc(wait, func() {
+		b.lock.Lock()
+		b.timer = nil
+		pending := len(b.pending) > 0 && !b.stopped
+		b.lock.Unlock()
+		if pending {
+			b.Trigger()
+		}
+	})
+}
+
+// Take returns and clears the pending events.
+func (b *EventBus) Take() []Event {
+	if b == nil {
+		return nil
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	events := b.pending
+	b.pending = nil
+	if len(events) > 0 {
+		b.lastSent = time.Now()
+	}
+	return events
+}
+
+// Requeue puts back events whose notify could not be delivered, before the
+// events published since, and backs off the next notify.
+func (b *EventBus) Requeue(events []Event) {
+	if b == nil || b.disabled || len(events) == 0 {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.stopped {
+		return
+	}
+	b.failures++
+	published := b.pending
+	b.pending = nil
+	for _, event := range events {
+		b.pending = mergeEvent(b.pending, event)
+	}
+	for _, event := range published {
+		b.pending = mergeEvent(b.pending, event)
+	}
+	b.schedule()
+}
+
+// Delivered resets the back off once an inform went through.
+func (b *EventBus) Delivered() {
+	if b == nil {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.failures = 0
+}
+
+// Stop cancels the pending notify. Events published afterwards are dropped.
+func (b *EventBus) Stop() {
+	if b == nil {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.stopped = true
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+}
+
+// ApplyEvents turns an inform into a notify inform for events.
+func ApplyEvents(request *inform.Inform, events []Event) {
+	if len(events) == 0 {
+		return
+	}
+	reasons := make([]string, 0, len(events))
+	payloads := make(map[string]string, len(events))
+	for _, event := range events {
+		reasons = append(reasons, event.Reason)
+		payloads[event.Reason] = event.Payload
+	}
+	request.InformAsNotify = true
+	request.NotifyReason = strings.Join(reasons, ",")
+	if len(events) == 1 {
+		request.NotifyPayload = events[0].Payload
+		return
+	}
+	payload, err := json.Marshal(payloads)
+	if err == nil {
+		request.NotifyPayload = string(payload)
+	}
+}
+
+type wanLink struct {
+	up bool
+	ip string
+}
+
+// wanState keeps the state of each WAN interface, so a switch of the
+// active uplink is not reported as a change.
+type wanState struct {
+	links map[string]wanLink
+}
+
+// events compares the WAN interfaces of request with their previous state.
+func (w *wanState) events(request inform.Inform) []Event {
+	wans := make(map[string]bool)
+	for _, port := range request.PortTable {
+		if port.Name == "wan" || port.Name == "wan2" {
+			wans[port.IfName] = true
+		}
+	}
+	links := make(map[string]wanLink, len(wans))
+	var events []Event
+	for _, wan := range request.IntfTable {
+		if !wans[wan.Name] {
+			continue
+		}
+		if previous, known := w.links[wan.Name]; known {
+			if previous.up && !wan.Up {
+				events = append(events, Event{Reason: EventWanDown, Payload: wan.Name})
+			} else if !previous.up && wan.Up {
+				events = append(events, Event{Reason: EventWanUp, Payload: wan.Name})
+			}
+			if previous.ip != wan.Ip {
+				events = append(events, Event{Reason: EventWanIpChange, Payload: wan.Ip})
+			}
+		}
+		links[wan.Name] = wanLink{up: wan.Up, ip: wan.Ip}
+	}
+	w.links = links
+	return events
+}
+
+// reloadHandler re-reads the pfSense configuration on SIGHUP.
+func reloadHandler(svc *Service) {
+	logger := svc.Log.WithField("component", "reload_handler")
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	for range signals {
+		logger.Info("Reloading pfSense configuration")
+		svc.Config.Reload()
+		svc.Events.Publish(Event{Reason: EventConfigReload})
+	}
+}
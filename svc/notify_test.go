@@ -0,0 +1,163 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/COSAE-FR/ripugw/inform"
+)
+
+func TestEventBusMergesPayloads(t *testing.T) {
+	bus := NewEventBus(time.Hour, time.Hour, func() {}, false)
+	bus.Publish(Event{Reason: EventCmdDone, Payload: "cmd1"})
+	bus.Publish(Event{Reason: EventWanDown, Payload: "eth1 down"})
+	bus.Publish(Event{Reason: EventCmdDone, Payload: "cmd2"})
+	bus.Publish(Event{Reason: EventCmdDone, Payload: "cmd1"})
+	bus.Publish(Event{Reason: EventConfigReload})
+	bus.Publish(Event{Reason: EventConfigReload})
+	want := []Event{
+		{Reason: EventCmdDone, Payload: "cmd1,cmd2"},
+		{Reason: EventWanDown, Payload: "eth1 down"},
+		{Reason: EventConfigReload},
+	}
+	if got := bus.Take(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Take() = %+v, want %+v", got, want)
+	}
+	if got := bus.Take(); len(got) != 0 {
+		t.Errorf("second Take() = %+v, want nothing", got)
+	}
+}
+
+func TestEventBusRequeue(t *testing.T) {
+	bus := NewEventBus(time.Hour, time.Hour, func() {}, false)
+	bus.Publish(Event{Reason: EventCmdDone, Payload: "cmd1"})
+	bus.Publish(Event{Reason: EventWanDown, Payload: "eth0"})
+	failed := bus.Take()
+	bus.Publish(Event{Reason: EventCmdDone, Payload: "cmd2"})
+	bus.Requeue(failed)
+	want := []Event{
+		{Reason: EventCmdDone, Payload: "cmd1,cmd2"},
+		{Reason: EventWanDown, Payload: "eth0"},
+	}
+	if got := bus.Take(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Take() after Requeue = %+v, want %+v", got, want)
+	}
+}
+
+func TestApplyEvents(t *testing.T) {
+	tests := []struct {
+		name    string
+		events  []Event
+		reason  string
+		payload string
+	}{
+		{"none", nil, "", ""},
+		{"single", []Event{{Reason: EventCmdDone, Payload: "cmd1,cmd2"}}, "cmd_done", "cmd1,cmd2"},
+		{
+			"several",
+			[]Event{{Reason: EventWanDown, Payload: "eth0"}, {Reason: EventSpeedTest}},
+			"wan_down,speedtest",
+			`{"speedtest":"","wan_down":"eth0"}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := inform.Inform{}
+			ApplyEvents(&request, test.events)
+			if request.InformAsNotify != (len(test.events) > 0) {
+				t.Errorf("InformAsNotify = %v", request.InformAsNotify)
+			}
+			if request.NotifyReason != test.reason || request.NotifyPayload != test.payload {
+				t.Errorf("notify = %q %q, want %q %q", request.NotifyReason, request.NotifyPayload, test.reason, test.payload)
+			}
+		})
+	}
+}
+
+func TestEventBusBackoff(t *testing.T) {
+	bus := NewEventBus(5*time.Second, 15*time.Second, func() {}, false)
+	for _, want := range []time.Duration{5 * time.Second, 10 * time.Second, 15 * time.Second, 15 * time.Second} {
+		bus.lock.Lock()
+		got := bus.interval()
+		bus.lock.Unlock()
+		if got != want {
+			t.Errorf("interval after %d failures = %s, want %s", bus.failures, got, want)
+		}
+		bus.Requeue([]Event{{Reason: EventCmdDone, Payload: "cmd1"}})
+		bus.Take()
+	}
+	bus.Delivered()
+	bus.lock.Lock()
+	defer bus.lock.Unlock()
+	if got := bus.interval(); got != 5*time.Second {
+		t.Errorf("interval after delivery = %s, want 5s", got)
+	}
+}
+
+func TestEventBusStop(t *testing.T) {
+	triggered := make(chan bool, 1)
+	bus := NewEventBus(50*time.Millisecond, time.Second, func() { triggered <- true }, false)
+	// A first notify goes out at once, the next one waits for the timer
+	bus.Publish(Event{Reason: EventCmdDone, Payload: "cmd0"})
+	<-triggered
+	bus.Take()
+	bus.Publish(Event{Reason: EventCmdDone, Payload: "cmd1"})
+	bus.Stop()
+	bus.Publish(Event{Reason: EventCmdDone, Payload: "cmd2"})
+	bus.Requeue([]Event{{Reason: EventWanDown, Payload: "eth0"}})
+	select {
+	case <-triggered:
+		t.Error("notify triggered after Stop")
+	case <-time.After(150 * time.Millisecond):
+	}
+	if got := bus.Take(); !reflect.DeepEqual(got, []Event{{Reason: EventCmdDone, Payload: "cmd1"}}) {
+		t.Errorf("events after Stop = %+v", got)
+	}
+}
+
+func dualWanInform(wan inform.Interface, wan2 inform.Interface, uplink string) inform.Inform {
+	return inform.Inform{
+		Uplink:    uplink,
+		IntfTable: []inform.Interface{wan, wan2, {Name: "eth1", Up: true, Ip: "192.168.1.1"}},
+		PortTable: []inform.Port{{IfName: wan.Name, Name: "wan"}, {IfName: wan2.Name, Name: "wan2"}, {IfName: "eth1", Name: "lan"}},
+	}
+}
+
+func TestWanStateEvents(t *testing.T) {
+	up := inform.Interface{Name: "eth0", Up: true, Ip: "203.0.113.10"}
+	down := inform.Interface{Name: "eth0", Ip: "203.0.113.10"}
+	renumbered := inform.Interface{Name: "eth0", Up: true, Ip: "203.0.113.20"}
+	up2 := inform.Interface{Name: "eth2", Up: true, Ip: "198.51.100.10"}
+	down2 := inform.Interface{Name: "eth2", Ip: "198.51.100.10"}
+	steps := []struct {
+		name    string
+		request inform.Inform
+		events  []Event
+	}{
+		{"first inform", dualWanInform(up, up2, "eth0"), nil},
+		{"uplink switch", dualWanInform(up, up2, "eth2"), nil},
+		{"wan down", dualWanInform(down, up2, "eth2"), []Event{{Reason: EventWanDown, Payload: "eth0"}}},
+		{"wan back up renumbered", dualWanInform(renumbered, up2, "eth0"), []Event{
+			{Reason: EventWanUp, Payload: "eth0"},
+			{Reason: EventWanIpChange, Payload: "203.0.113.20"},
+		}},
+		{"wan2 down", dualWanInform(renumbered, down2, "eth0"), []Event{{Reason: EventWanDown, Payload: "eth2"}}},
+		{"lan only", inform.Inform{IntfTable: []inform.Interface{{Name: "eth1", Up: true}}}, nil},
+		{"wan back after a gap", dualWanInform(up, up2, "eth0"), nil},
+	}
+	var state wanState
+	for _, step := range steps {
+		if events := state.events(step.request); !reflect.DeepEqual(events, step.events) {
+			t.Errorf("%s: events = %+v, want %+v", step.name, events, step.events)
+		}
+	}
+}
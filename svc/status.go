@@ -0,0 +1,288 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/COSAE-FR/ripugw/inform"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type Metric struct {
+	Name string
+	Help string
+	// Type is the Prometheus metric type, gauge when empty
+	Type   string
+	Labels map[string]string
+	Value  float64
+}
+
+func (m Metric) key() string {
+	return m.Name + m.labelString()
+}
+
+func (m Metric) labelString() string {
+	if len(m.Labels) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(m.Labels))
+	for name := range m.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	labels := make([]string, 0, len(names))
+	for _, name := range names {
+		labels = append(labels, fmt.Sprintf("%s=%q", name, m.Labels[name]))
+	}
+	return "{" + strings.Join(labels, ",") + "}"
+}
+
+// StatusRegistry holds the local status sections and metrics exposed on the
+// status listener.
+type StatusRegistry struct {
+	lock     sync.Mutex
+	sections map[string]interface{}
+	metrics  map[string]Metric
+	server   *http.Server
+}
+
+func NewStatusRegistry() *StatusRegistry {
+	return &StatusRegistry{
+		sections: make(map[string]interface{}),
+		metrics:  make(map[string]Metric),
+	}
+}
+
+// Set replaces a status section. The value must be JSON serializable.
+func (r *StatusRegistry) Set(section string, value interface{}) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.sections[section] = value
+}
+
+func (r *StatusRegistry) SetMetric(metric Metric) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.metrics[metric.key()] = metric
+}
+
+// SetMetrics replaces all the metrics with the given name, so the label
+// sets missing from metrics are no longer exposed.
+func (r *StatusRegistry) SetMetrics(name string, metrics []Metric) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for key, metric := range r.metrics {
+		if metric.Name == name {
+			delete(r.metrics, key)
+		}
+	}
+	for _, metric := range metrics {
+		metric.Name = name
+		r.metrics[metric.key()] = metric
+	}
+}
+
+// ResetMetrics removes all the metrics with the given name.
+func (r *StatusRegistry) ResetMetrics(name string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for key, metric := range r.metrics {
+		if metric.Name == name {
+			delete(r.metrics, key)
+		}
+	}
+}
+
+func (r *StatusRegistry) MarshalJSON() ([]byte, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return json.Marshal(r.sections)
+}
+
+func (r *StatusRegistry) writeMetrics(w http.ResponseWriter) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	keys := make([]string, 0, len(r.metrics))
+	for key := range r.metrics {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	described := make(map[string]bool)
+	for _, key := range keys {
+		metric := r.metrics[key]
+		if !described[metric.Name] {
+			described[metric.Name] = true
+			if len(metric.Help) > 0 {
+				_, _ = fmt.Fprintf(w, "# HELP %s %s\n", metric.Name, metric.Help)
+			}
+			kind := metric.Type
+			if len(kind) == 0 {
+				kind = "gauge"
+			}
+			_, _ = fmt.Fprintf(w, "# TYPE %s %s\n", metric.Name, kind)
+		}
+		_, _ = fmt.Fprintf(w, "%s%s %g\n", metric.Name, metric.labelString(), metric.Value)
+	}
+}
+
+func (r *StatusRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.URL.Path {
+	case "/", "/status":
+		body, err := json.MarshalIndent(r, "", "\t")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	case "/metrics":
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		r.writeMetrics(w)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+// Listen starts the status HTTP server on address.
+func (r *StatusRegistry) Listen(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	r.server = &http.Server{Handler: r}
+	go func() {
+		_ = r.server.Serve(listener)
+	}()
+	return nil
+}
+
+func (r *StatusRegistry) Close() error {
+	if r.server == nil {
+		return nil
+	}
+	return r.server.Close()
+}
+
+type ClientStatus struct {
+	Network  string `json:"network"`
+	Mac      string `json:"mac"`
+	Ip       string `json:"ip,omitempty"`
+	Hostname string `json:"hostname,omitempty"`
+	Vendor   string `json:"vendor,omitempty"`
+	FixedIp  bool   `json:"fixed_ip,omitempty"`
+}
+
+func clientStatus(request inform.Inform) []ClientStatus {
+	clients := []ClientStatus{}
+	for _, network := range request.NetworkTable {
+		for _, host := range network.HostTable {
+			clients = append(clients, ClientStatus{
+				Network:  network.Name,
+				Mac:      host.Mac.String(),
+				Ip:       host.Ip,
+				Hostname: host.Hostname,
+				Vendor:   host.Vendor,
+				FixedIp:  host.UseFixedIp,
+			})
+		}
+	}
+	return clients
+}
+
+// interfaceRateMetrics are the names of the rx bytes, tx bytes, rx packets
+// and tx packets rate metrics.
+var interfaceRateMetrics = []string{
+	"ripugw_interface_rx_bytes_per_second",
+	"ripugw_interface_tx_bytes_per_second",
+	"ripugw_interface_rx_packets_per_second",
+	"ripugw_interface_tx_packets_per_second",
+}
+
+type InterfaceStatus struct {
+	Name          string  `json:"name"`
+	Up            bool    `json:"up"`
+	Speed         uint64  `json:"speed"`
+	RxBytes       uint64  `json:"rx_bytes"`
+	TxBytes       uint64  `json:"tx_bytes"`
+	RxBytesRate   float64 `json:"rx_bytes_rate"`
+	TxBytesRate   float64 `json:"tx_bytes_rate"`
+	RxPacketsRate float64 `json:"rx_packets_rate"`
+	TxPacketsRate float64 `json:"tx_packets_rate"`
+}
+
+// interfaceStatus publishes the interface counters and rates. The rate
+// metrics of the interfaces no longer in request are removed.
+func interfaceStatus(svc *Service, request inform.Inform) {
+	interfaces := make([]InterfaceStatus, 0, len(request.IntfTable))
+	rates := make(map[string][]Metric)
+	for _, iface := range request.IntfTable {
+		interfaces = append(interfaces, InterfaceStatus{
+			Name:          iface.Name,
+			Up:            iface.Up,
+			Speed:         iface.Speed,
+			RxBytes:       iface.RxBytes,
+			TxBytes:       iface.TxBytes,
+			RxBytesRate:   iface.RxBytesRate,
+			TxBytesRate:   iface.TxBytesRate,
+			RxPacketsRate: iface.RxPacketsRate,
+			TxPacketsRate: iface.TxPacketsRate,
+		})
+		labels := map[string]string{"interface": iface.Name}
+		for i, value := range []float64{iface.RxBytesRate, iface.TxBytesRate, iface.RxPacketsRate, iface.TxPacketsRate} {
+			name := interfaceRateMetrics[i]
+			rates[name] = append(rates[name], Metric{Name: name, Labels: labels, Value: value})
+		}
+	}
+	for _, name := range interfaceRateMetrics {
+		svc.Status.SetMetrics(name, rates[name])
+	}
+	svc.Status.Set("interfaces", interfaces)
+}
+
+type WanStatus struct {
+	Type   string            `json:"type"`
+	IfName string            `json:"ifname,omitempty"`
+	Ip     string            `json:"ip,omitempty"`
+	Type6  string            `json:"type6,omitempty"`
+	Ip6    string            `json:"ip6,omitempty"`
+	Lease  *inform.DhcpLease `json:"dhcp_lease,omitempty"`
+}
+
+// wanStatus publishes the WAN network configs, with their DHCP lease.
+func wanStatus(svc *Service, request inform.Inform) {
+	wans := make(map[string]WanStatus)
+	for name, config := range map[string]inform.NetworkConfig{"wan": request.ConfigNetworkWan, "wan2": request.ConfigNetworkWan2} {
+		if config.Type == inform.NetworkConfigDisabled {
+			continue
+		}
+		wans[name] = WanStatus{
+			Type:   config.Type,
+			IfName: config.IfName,
+			Ip:     config.Ip,
+			Type6:  config.Type6,
+			Ip6:    config.Ip6,
+			Lease:  config.Lease,
+		}
+		if config.Lease == nil || config.Lease.Expire == 0 {
+			continue
+		}
+		svc.Status.SetMetric(Metric{
+			Name:   "ripugw_wan_dhcp_lease_expiry_seconds",
+			Help:   "Unix time at which the WAN DHCP lease expires.",
+			Labels: map[string]string{"wan": name},
+			Value:  float64(config.Lease.Expire),
+		})
+	}
+	svc.Status.Set("wans", wans)
+}
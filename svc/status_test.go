@@ -0,0 +1,54 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/COSAE-FR/ripugw/inform"
+)
+
+func scrapeMetrics(t *testing.T, registry *StatusRegistry) string {
+	recorder := httptest.NewRecorder()
+	registry.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	return recorder.Body.String()
+}
+
+func TestInterfaceStatusRemovesVanishedInterfaces(t *testing.T) {
+	svc := &Service{Status: NewStatusRegistry()}
+	interfaceStatus(svc, inform.Inform{IntfTable: []inform.Interface{
+		{Name: "eth0", RxBytesRate: 100},
+		{Name: "eth1", TxPacketsRate: 5},
+	}})
+	metrics := scrapeMetrics(t, svc.Status)
+	for _, line := range []string{
+		`ripugw_interface_rx_bytes_per_second{interface="eth0"} 100`,
+		`ripugw_interface_tx_packets_per_second{interface="eth1"} 5`,
+	} {
+		if !strings.Contains(metrics, line+"\n") {
+			t.Errorf("missing %s in\n%s", line, metrics)
+		}
+	}
+
+	interfaceStatus(svc, inform.Inform{IntfTable: []inform.Interface{{Name: "eth0", RxBytesRate: 200}}})
+	metrics = scrapeMetrics(t, svc.Status)
+	if strings.Contains(metrics, `interface="eth1"`) {
+		t.Errorf("vanished interface still exposed:\n%s", metrics)
+	}
+	if !strings.Contains(metrics, `ripugw_interface_rx_bytes_per_second{interface="eth0"} 200`+"\n") {
+		t.Errorf("eth0 rate not updated:\n%s", metrics)
+	}
+
+	interfaceStatus(svc, inform.Inform{})
+	if metrics = scrapeMetrics(t, svc.Status); len(metrics) > 0 {
+		t.Errorf("metrics left without interfaces:\n%s", metrics)
+	}
+}
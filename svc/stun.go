@@ -0,0 +1,116 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package main
+
+import (
+	"github.com/COSAE-FR/ripugw/stun"
+	log "github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+// A STUN server that cannot be resolved or reached at start is retried,
+// the delay doubling from stunRetryMin up to stunRetryMax.
+const (
+	stunRetryMin = 5 * time.Second
+	stunRetryMax = 5 * time.Minute
+)
+
+// stunState holds the STUN client. The inform goroutine replaces it when the
+// controller changes the stun_url, while Stop may run at the same time.
+type stunState struct {
+	lock    sync.Mutex
+	client  *stun.Client
+	stopped bool
+	// Pending start after a failure, and the delay of the next one
+	retry      *time.Timer
+	retryDelay time.Duration
+}
+
+// stop must be called with the lock held.
+func (s *stunState) stop() bool {
+	if s.retry != nil {
+		s.retry.Stop()
+		s.retry = nil
+	}
+	if s.client == nil {
+		return false
+	}
+	s.client.Stop()
+	s.client = nil
+	return true
+}
+
+// Stop stops the STUN client, if any, for good.
+func (s *stunState) Stop() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.stopped = true
+	return s.stop()
+}
+
+// restartStun (re)creates the STUN client for the controller stun_url.
+func (s *Service) restartStun() {
+	logger := s.Log.WithField("component", "stun_handler")
+	s.stun.lock.Lock()
+	defer s.stun.lock.Unlock()
+	if s.stun.stop() {
+		logger.Debug("Stopped STUN client")
+	}
+	s.stun.retryDelay = 0
+	s.startStun(logger)
+}
+
+// startStun must be called with the lock held.
+func (s *Service) startStun(logger *log.Entry) {
+	if s.stun.stopped || s.Config.Stun.Disable || len(s.Management.StunUrl) == 0 {
+		return
+	}
+	client, err := stun.NewClient(s.Management.StunUrl, s.TriggerInform, s.Log)
+	if err != nil {
+		logger.Errorf("Invalid STUN URL %s: %v", s.Management.StunUrl, err)
+		return
+	}
+	if s.Config.Stun.Interval > 0 {
+		client.Interval = time.Duration(s.Config.Stun.Interval) * time.Second
+	}
+	if err := client.Start(); err != nil {
+		delay := s.scheduleStunRetry(logger)
+		logger.Errorf("Cannot start STUN client for %s, retrying in %s: %v", client.Server, delay, err)
+		return
+	}
+	logger.Debugf("STUN client started for %s", client.Server)
+	s.stun.client = client
+	s.stun.retryDelay = 0
+}
+
+// scheduleStunRetry must be called with the lock held. A retry replaced or
+// cancelled in the meantime does nothing.
+func (s *Service) scheduleStunRetry(logger *log.Entry) time.Duration {
+	delay := s.stun.retryDelay
+	if delay < stunRetryMin {
+		delay = stunRetryMin
+	}
+	s.stun.retryDelay = delay * 2
+	if s.stun.retryDelay > stunRetryMax {
+		s.stun.retryDelay = stunRetryMax
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		s.stun.lock.Lock()
+		defer s.stun.lock.Unlock()
+		if s.stun.retry != timer {
+			return
+		}
+		s.stun.retry = nil
+		s.startStun(logger)
+	})
+	s.stun.retry = timer
+	return delay
+}
@@ -0,0 +1,72 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package main
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/COSAE-FR/ripugw/conf"
+	log "github.com/sirupsen/logrus"
+)
+
+func stunService() *Service {
+	logger := log.New()
+	logger.Out = ioutil.Discard
+	svc := &Service{Config: &conf.Config{Log: log.NewEntry(logger)}, stun: &stunState{}}
+	svc.InformNow = make(chan bool, 1)
+	return svc
+}
+
+func TestRestartStunRetries(t *testing.T) {
+	svc := stunService()
+	// An IPv6 server cannot be resolved for the udp4 client
+	svc.Config.Management.StunUrl = "stun:[::1]:3478"
+	svc.restartStun()
+	svc.stun.lock.Lock()
+	if svc.stun.client != nil || svc.stun.retry == nil || svc.stun.retryDelay != 2*stunRetryMin {
+		t.Errorf("after a failed start: client %v, retry %v, next delay %s, want a retry in %s", svc.stun.client, svc.stun.retry, svc.stun.retryDelay, stunRetryMin)
+	}
+	logger := svc.Log
+	for i := 0; i < 10; i++ {
+		svc.scheduleStunRetry(logger)
+	}
+	if svc.stun.retryDelay != stunRetryMax {
+		t.Errorf("next delay = %s, want at most %s", svc.stun.retryDelay, stunRetryMax)
+	}
+	svc.stun.lock.Unlock()
+
+	// A new URL starts over, without the pending retry
+	svc.Config.Management.StunUrl = "stun:127.0.0.1:3478"
+	svc.restartStun()
+	svc.stun.lock.Lock()
+	if svc.stun.client == nil || svc.stun.retry != nil || svc.stun.retryDelay != 0 {
+		t.Errorf("after a start: client %v, retry %v, next delay %s, want a client", svc.stun.client, svc.stun.retry, svc.stun.retryDelay)
+	}
+	svc.stun.lock.Unlock()
+	if !svc.stun.Stop() {
+		t.Error("Stop() found no client")
+	}
+}
+
+func TestStunStopCancelsRetry(t *testing.T) {
+	svc := stunService()
+	svc.Config.Management.StunUrl = "stun:[::1]:3478"
+	svc.restartStun()
+	if svc.stun.Stop() {
+		t.Error("Stop() found a client")
+	}
+	if svc.stun.retry != nil {
+		t.Error("Stop() left the retry pending")
+	}
+	svc.restartStun()
+	if svc.stun.retry != nil || svc.stun.client != nil {
+		t.Error("restartStun() after Stop() started again")
+	}
+}
@@ -0,0 +1,87 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package main
+
+import (
+	"github.com/COSAE-FR/ripugw/collect"
+	"time"
+)
+
+func (s *Service) startUplinks() {
+	logger := s.Log.WithField("component", "uplink_monitor")
+	if s.Config.Uplink.Disable {
+		return
+	}
+	targets := s.Config.Uplink.Targets
+	if len(targets) == 0 {
+		targets = collect.DefaultProbeTargets
+	}
+	var probes []collect.ProbeTarget
+	for _, target := range targets {
+		probe, err := collect.ParseProbeTarget(target)
+		if err != nil {
+			logger.Errorf("Ignoring uplink probe: %v", err)
+			continue
+		}
+		probes = append(probes, probe)
+	}
+	if len(probes) == 0 {
+		logger.Warn("No valid uplink probe target: uplink monitoring disabled")
+		return
+	}
+	interval := time.Duration(s.Config.Uplink.Interval) * time.Second
+	s.Uplinks = collect.NewUplinkRegistry(probes, interval, s.Config.Uplink.Window)
+}
+
+type UplinkStatus struct {
+	Up           bool                  `json:"up"`
+	Latency      float64               `json:"latency_ms"`
+	Jitter       float64               `json:"jitter_ms"`
+	Loss         float64               `json:"loss_percent"`
+	Availability float64               `json:"availability"`
+	Monitors     []collect.TargetStats `json:"monitors"`
+}
+
+// uplinkMetrics are the names and help of the latency, jitter, loss and
+// availability metrics of the uplink monitors.
+var uplinkMetrics = [][2]string{
+	{"ripugw_uplink_latency_milliseconds", "Average latency to the uplink probe targets."},
+	{"ripugw_uplink_jitter_milliseconds", "Mean variation of the uplink probe round trip time."},
+	{"ripugw_uplink_loss_percent", "Share of lost uplink probes."},
+	{"ripugw_uplink_availability_percent", "Share of probe rounds with at least one answer."},
+}
+
+// uplinkStatus publishes the statistics of the uplink monitors, by
+// interface. The metrics of the reaped monitors are removed.
+func uplinkStatus(svc *Service) {
+	if svc.Uplinks == nil {
+		return
+	}
+	uplinks := make(map[string]UplinkStatus)
+	metrics := make([][]Metric, len(uplinkMetrics))
+	for iface, stats := range svc.Uplinks.All() {
+		status := UplinkStatus{
+			Up:           stats.Up,
+			Latency:      float64(stats.Latency) / float64(time.Millisecond),
+			Jitter:       float64(stats.Jitter) / float64(time.Millisecond),
+			Loss:         stats.Loss,
+			Availability: stats.Availability,
+			Monitors:     stats.Targets,
+		}
+		uplinks[iface] = status
+		labels := map[string]string{"interface": iface}
+		for i, value := range []float64{status.Latency, status.Jitter, status.Loss, status.Availability} {
+			metrics[i] = append(metrics[i], Metric{Help: uplinkMetrics[i][1], Labels: labels, Value: value})
+		}
+	}
+	for i, metric := range uplinkMetrics {
+		svc.Status.SetMetrics(metric[0], metrics[i])
+	}
+	svc.Status.Set("uplinks", uplinks)
+}
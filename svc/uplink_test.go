@@ -0,0 +1,73 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package main
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/COSAE-FR/ripugw/collect"
+)
+
+func TestUplinkStatusRemovesReapedMonitors(t *testing.T) {
+	var loopback string
+	interfaces, _ := net.Interfaces()
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			loopback = iface.Name
+		}
+	}
+	if len(loopback) == 0 {
+		t.Skip("no loopback interface")
+	}
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	target := collect.ProbeTarget{Method: collect.ProbeTcp, Address: listener.Addr().String()}
+	svc := &Service{Status: NewStatusRegistry(), Uplinks: collect.NewUplinkRegistry([]collect.ProbeTarget{target}, time.Hour, 10)}
+	defer svc.Uplinks.Stop()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := svc.Uplinks.Stats(loopback); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no uplink probe result")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	uplinkStatus(svc)
+	metrics := scrapeMetrics(t, svc.Status)
+	for _, name := range []string{"latency_milliseconds", "jitter_milliseconds", "loss_percent", "availability_percent"} {
+		if series := "ripugw_uplink_" + name + `{interface="` + loopback + `"}`; !strings.Contains(metrics, series) {
+			t.Errorf("missing %s in\n%s", series, metrics)
+		}
+	}
+
+	svc.Uplinks.Stop()
+	uplinkStatus(svc)
+	if metrics = scrapeMetrics(t, svc.Status); len(metrics) > 0 {
+		t.Errorf("metrics left without monitors:\n%s", metrics)
+	}
+}
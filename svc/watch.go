@@ -0,0 +1,57 @@
+/*
+ * Copyright (c) 2020. Gaetan Crahay
+ *
+ * Use of this source code is governed by an MIT-style
+ * license that can be found in the LICENSE file or at
+ * https://opensource.org/licenses/MIT.
+ */
+
+package main
+
+import (
+	"github.com/COSAE-FR/ripugw/collect"
+	"time"
+)
+
+func (s *Service) startWatcher() {
+	logger := s.Log.WithField("component", "net_watcher")
+	if s.Config.Watch.Disable {
+		return
+	}
+	source, err := collect.NewSystemEventSource()
+	if err != nil {
+		logger.Infof("Network events not available: %v", err)
+		return
+	}
+	debounce := time.Duration(s.Config.Watch.Debounce) * time.Millisecond
+	s.Watcher = collect.NewNetWatcher(source, debounce)
+	s.Watcher.Links = s.Links
+	if s.Config.PfSenseConfiguration() != nil {
+		s.Watcher.IsWan = s.isPfSenseWan
+	}
+	s.Watcher.OnTrigger = func(reason string) {
+		logger.Infof("Network change (%s): sending inform", reason)
+		s.TriggerInform()
+	}
+	go s.Watcher.Run()
+}
+
+// isPfSenseWan tells if iface is the physical interface of a translated WAN.
+func (s *Service) isPfSenseWan(iface string) bool {
+	s.Config.Lock.Lock()
+	defer s.Config.Lock.Unlock()
+	if s.Config.PfSense == nil || s.Config.PfSenseInterfaces == nil {
+		return false
+	}
+	for _, pfInterface := range s.Config.PfSense.Interfaces.List {
+		name := pfInterface.XMLName.Local
+		if pfInterface.If != iface || len(name) == 0 {
+			continue
+		}
+		switch s.Config.PfSenseInterfaces.Role(name) {
+		case collect.RoleWan, collect.RoleWan2:
+			return true
+		}
+	}
+	return false
+}